@@ -158,6 +158,18 @@ func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Addres
 	return val[:], nil
 }
 
+// HeaderByNumber returns a block header from the current canonical chain. If
+// number is nil, the latest known header is returned.
+func (b *SimulatedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if number == nil {
+		return b.blockchain.CurrentHeader(), nil
+	}
+	return b.blockchain.GetHeaderByNumber(number.Uint64()), nil
+}
+
 // TransactionReceipt returns the receipt of a transaction.
 func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
 	receipt, _, _, _ := rawdb.ReadReceipt(b.database, txHash)
@@ -325,10 +337,19 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 // TODO(karalabe): Deprecate when the subscription one can return past data too.
 func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
 	var filter *filters.Filter
-	if query.BlockHash != nil {
+	switch {
+	case query.BlockHash != nil:
 		// Block filter requested, construct a single-shot filter
 		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain}, *query.BlockHash, query.Addresses, query.Topics)
-	} else {
+	case len(query.BlockHashes) > 0:
+		filter = filters.NewBlocksFilter(&filterBackend{b.database, b.blockchain}, query.BlockHashes, query.Addresses, query.Topics)
+	case len(query.TxHashes) > 0 && query.FromBlock == nil && query.ToBlock == nil:
+		// Only transactions requested, search the blocks containing them
+		var err error
+		if filter, err = filters.NewTxFilter(&filterBackend{b.database, b.blockchain}, query.TxHashes, query.Addresses, query.Topics); err != nil {
+			return nil, err
+		}
+	default:
 		// Initialize unset filter boundaried to run from genesis to chain head
 		from := int64(0)
 		if query.FromBlock != nil {
@@ -341,6 +362,9 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 		// Construct the range filter
 		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain}, from, to, query.Addresses, query.Topics)
 	}
+	if len(query.TxHashes) > 0 {
+		filter.SetTxHashes(query.TxHashes)
+	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
 	if err != nil {
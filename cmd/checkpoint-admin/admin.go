@@ -0,0 +1,198 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of github.com/go-ethereum-analysis.
+//
+// github.com/go-ethereum-analysis is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// github.com/go-ethereum-analysis is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with github.com/go-ethereum-analysis. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/go-ethereum-analysis/accounts/abi/bind"
+	"github.com/go-ethereum-analysis/cmd/utils"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/common/hexutil"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle/contract"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/ethclient"
+	"github.com/go-ethereum-analysis/light"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandStatus = cli.Command{
+	Name:  "status",
+	Usage: "Fetches the admins and the latest checkpoint of the oracle",
+	Flags: []cli.Flag{
+		oracleFlag,
+	},
+	Action: utils.MigrateFlags(status),
+}
+
+var commandDeploy = cli.Command{
+	Name:  "deploy",
+	Usage: "Deploys a new checkpoint oracle contract",
+	Flags: []cli.Flag{
+		keyFileFlag,
+		passphraseFlag,
+		signersFlag,
+		thresholdFlag,
+	},
+	Action: utils.MigrateFlags(deploy),
+}
+
+var commandSign = cli.Command{
+	Name:  "sign",
+	Usage: "Signs a checkpoint generated by the les server",
+	Flags: []cli.Flag{
+		oracleFlag,
+		keyFileFlag,
+		passphraseFlag,
+		indexFlag,
+	},
+	Action: utils.MigrateFlags(sign),
+}
+
+var commandPublish = cli.Command{
+	Name:  "publish",
+	Usage: "Publishes a checkpoint with enough signatures to the oracle",
+	Flags: []cli.Flag{
+		oracleFlag,
+		keyFileFlag,
+		passphraseFlag,
+		indexFlag,
+		signaturesFlag,
+	},
+	Action: utils.MigrateFlags(publish),
+}
+
+// status fetches the admin list and the latest registered checkpoint of the
+// checkpoint oracle.
+func status(ctx *cli.Context) error {
+	client := newRPCClient(ctx)
+	oracle := newOracle(client, getContractAddr(ctx, client))
+
+	admins, err := oracle.Contract().GetAllAdmin(nil)
+	if err != nil {
+		return err
+	}
+	for i, admin := range admins {
+		fmt.Printf("Admin %d => %s\n", i+1, admin.Hex())
+	}
+	index, hash, height, err := oracle.LatestCheckpoint(nil)
+	if err != nil {
+		return err
+	}
+	if height.Sign() == 0 {
+		fmt.Println("No checkpoint registered yet")
+		return nil
+	}
+	fmt.Printf("Checkpoint #%d => %s (registered at block %d)\n", index, hash.Hex(), height)
+	return nil
+}
+
+// deploy deploys a new checkpoint oracle contract with the given trusted signers
+// and threshold.
+func deploy(ctx *cli.Context) error {
+	var signers []common.Address
+	for _, signer := range splitList(ctx.String(signersFlag.Name)) {
+		if !common.IsHexAddress(signer) {
+			utils.Fatalf("Invalid signer address %q", signer)
+		}
+		signers = append(signers, common.HexToAddress(signer))
+	}
+	threshold := ctx.Uint64(thresholdFlag.Name)
+	if threshold == 0 || threshold > uint64(len(signers)) {
+		utils.Fatalf("Invalid signature threshold %d for %d signers", threshold, len(signers))
+	}
+	// The contract requires its admins to be sorted for the signature checks
+	sortAddresses(signers)
+
+	client := ethclient.NewClient(newRPCClient(ctx))
+	auth := bind.NewKeyedTransactor(getKey(ctx))
+
+	addr, tx, _, err := contract.DeployCheckpointOracle(auth, client, signers, big.NewInt(light.CHTFrequencyClient), big.NewInt(light.HelperTrieProcessConfirmations), new(big.Int).SetUint64(threshold))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sent deployment transaction %s\n", tx.Hash().Hex())
+	if _, err := bind.WaitDeployed(context.Background(), client, tx); err != nil {
+		return err
+	}
+	fmt.Printf("Deployed checkpoint oracle at %s\n", addr.Hex())
+	return nil
+}
+
+// sign signs the checkpoint of the les server for the oracle with the given key.
+func sign(ctx *cli.Context) error {
+	client := newRPCClient(ctx)
+	addr := getContractAddr(ctx, client)
+	checkpoint := getCheckpoint(ctx, client)
+	key := getKey(ctx)
+
+	sig, err := crypto.Sign(checkpointoracle.SignatureHash(addr, checkpoint.SectionIdx, checkpoint.Hash()).Bytes(), key)
+	if err != nil {
+		return err
+	}
+	sig[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+
+	fmt.Printf("Oracle     => %s\n", addr.Hex())
+	fmt.Printf("Index      => %d\n", checkpoint.SectionIdx)
+	fmt.Printf("Hash       => %s\n", checkpoint.Hash().Hex())
+	fmt.Printf("Signer     => %s\n", crypto.PubkeyToAddress(key.PublicKey).Hex())
+	fmt.Printf("Signature  => %s\n", hexutil.Encode(sig))
+	return nil
+}
+
+// publish registers the checkpoint of the les server in the oracle, along with
+// the signatures collected from the trusted signers.
+func publish(ctx *cli.Context) error {
+	rpcClient := newRPCClient(ctx)
+	client := ethclient.NewClient(rpcClient)
+
+	oracle := newOracle(rpcClient, getContractAddr(ctx, rpcClient))
+	checkpoint := getCheckpoint(ctx, rpcClient)
+
+	var sigs [][]byte
+	for _, sig := range splitList(ctx.String(signaturesFlag.Name)) {
+		blob, err := hexutil.Decode(sig)
+		if err != nil {
+			utils.Fatalf("Invalid signature %q: %v", sig, err)
+		}
+		sigs = append(sigs, blob)
+	}
+	// Reference a recent block to make the registration fork specific
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	tx, err := oracle.RegisterCheckpoint(bind.NewKeyedTransactor(getKey(ctx)), checkpoint.SectionIdx, checkpoint.Hash(), head.Number, head.Hash(), sigs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sent registration transaction %s\n", tx.Hash().Hex())
+
+	receipt, err := bind.WaitMined(context.Background(), client, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		utils.Fatalf("Checkpoint registration failed")
+	}
+	fmt.Printf("Published checkpoint #%d => %s\n", checkpoint.SectionIdx, checkpoint.Hash().Hex())
+	return nil
+}
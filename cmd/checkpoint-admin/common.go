@@ -0,0 +1,140 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of github.com/go-ethereum-analysis.
+//
+// github.com/go-ethereum-analysis is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// github.com/go-ethereum-analysis is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with github.com/go-ethereum-analysis. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-ethereum-analysis/accounts/keystore"
+	"github.com/go-ethereum-analysis/cmd/utils"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/console"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle"
+	"github.com/go-ethereum-analysis/ethclient"
+	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// newRPCClient creates a client connected to the configured rpc endpoint.
+func newRPCClient(ctx *cli.Context) *rpc.Client {
+	client, err := rpc.Dial(ctx.GlobalString(rpcFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to connect to %s: %v", ctx.GlobalString(rpcFlag.Name), err)
+	}
+	return client
+}
+
+// getContractAddr returns the address of the checkpoint oracle, either as given
+// on the command line or as configured on the les server.
+func getContractAddr(ctx *cli.Context, client *rpc.Client) common.Address {
+	if ctx.IsSet(oracleFlag.Name) {
+		if !common.IsHexAddress(ctx.String(oracleFlag.Name)) {
+			utils.Fatalf("Invalid oracle address %q", ctx.String(oracleFlag.Name))
+		}
+		return common.HexToAddress(ctx.String(oracleFlag.Name))
+	}
+	var addr common.Address
+	if err := client.Call(&addr, "les_getCheckpointContractAddress"); err != nil {
+		utils.Fatalf("Failed to retrieve oracle address: %v", err)
+	}
+	return addr
+}
+
+// getCheckpoint retrieves the checkpoint of the requested section generated by
+// the les server, defaulting to the latest one.
+func getCheckpoint(ctx *cli.Context, client *rpc.Client) *light.TrustedCheckpoint {
+	var (
+		checkpoint = new(light.TrustedCheckpoint)
+		err        error
+	)
+	if index := ctx.Int64(indexFlag.Name); index < 0 {
+		err = client.Call(checkpoint, "les_latestCheckpoint")
+	} else {
+		err = client.Call(checkpoint, "les_getCheckpoint", uint64(index))
+	}
+	if err != nil {
+		utils.Fatalf("Failed to retrieve checkpoint: %v", err)
+	}
+	return checkpoint
+}
+
+// newOracle binds the checkpoint oracle contract through the given client.
+func newOracle(client *rpc.Client, addr common.Address) *checkpointoracle.CheckpointOracle {
+	oracle, err := checkpointoracle.NewCheckpointOracle(addr, ethclient.NewClient(client))
+	if err != nil {
+		utils.Fatalf("Failed to bind checkpoint oracle: %v", err)
+	}
+	return oracle
+}
+
+// getKey loads the private key from the keyfile given on the command line.
+func getKey(ctx *cli.Context) *ecdsa.PrivateKey {
+	keyfile := ctx.String(keyFileFlag.Name)
+	if keyfile == "" {
+		utils.Fatalf("No keyfile specified")
+	}
+	keyjson, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		utils.Fatalf("Failed to read the keyfile at '%s': %v", keyfile, err)
+	}
+	key, err := keystore.DecryptKey(keyjson, getPassphrase(ctx))
+	if err != nil {
+		utils.Fatalf("Failed to decrypt the keyfile: %v", err)
+	}
+	return key.PrivateKey
+}
+
+// getPassphrase obtains a passphrase given by the user. It first checks the
+// --passwordfile command line flag and ultimately prompts the user for a
+// passphrase.
+func getPassphrase(ctx *cli.Context) string {
+	if file := ctx.String(passphraseFlag.Name); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read passphrase file '%s': %v", file, err)
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	passphrase, err := console.Stdin.PromptPassword("Passphrase: ")
+	if err != nil {
+		utils.Fatalf("Failed to read passphrase: %v", err)
+	}
+	return passphrase
+}
+
+// splitList splits a comma separated command line list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sortAddresses sorts a list of addresses in ascending order.
+func sortAddresses(addrs []common.Address) {
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+}
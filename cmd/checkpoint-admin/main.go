@@ -0,0 +1,90 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of github.com/go-ethereum-analysis.
+//
+// github.com/go-ethereum-analysis is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// github.com/go-ethereum-analysis is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with github.com/go-ethereum-analysis. If not, see <http://www.gnu.org/licenses/>.
+
+// checkpoint-admin is a utility that can be used to deploy the checkpoint oracle
+// contract, and to sign and publish the checkpoints generated by a les server.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-ethereum-analysis/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "an Ethereum checkpoint oracle manager")
+	app.Commands = []cli.Command{
+		commandStatus,
+		commandDeploy,
+		commandSign,
+		commandPublish,
+	}
+	app.Flags = []cli.Flag{
+		rpcFlag,
+	}
+}
+
+// Commonly used command line flags.
+var (
+	rpcFlag = cli.StringFlag{
+		Name:  "rpc",
+		Value: "http://localhost:8545",
+		Usage: "the rpc endpoint of a les server (or a node running the oracle)",
+	}
+	oracleFlag = cli.StringFlag{
+		Name:  "oracle",
+		Usage: "address of the checkpoint oracle contract (defaults to the one of the les server)",
+	}
+	keyFileFlag = cli.StringFlag{
+		Name:  "keyfile",
+		Usage: "the keyfile of the account to sign or send transactions with",
+	}
+	passphraseFlag = cli.StringFlag{
+		Name:  "passwordfile",
+		Usage: "the file that contains the passphrase for the keyfile",
+	}
+	signersFlag = cli.StringFlag{
+		Name:  "signers",
+		Usage: "comma separated list of the trusted signer addresses",
+	}
+	thresholdFlag = cli.Uint64Flag{
+		Name:  "threshold",
+		Usage: "minimal number of signatures required to register a checkpoint",
+	}
+	indexFlag = cli.Int64Flag{
+		Name:  "index",
+		Value: -1,
+		Usage: "section index of the checkpoint (defaults to the latest one)",
+	}
+	signaturesFlag = cli.StringFlag{
+		Name:  "signatures",
+		Usage: "comma separated list of the checkpoint signatures",
+	}
+)
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of github.com/go-ethereum-analysis.
+//
+// github.com/go-ethereum-analysis is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// github.com/go-ethereum-analysis is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with github.com/go-ethereum-analysis. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-ethereum-analysis/cmd/utils"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/rawdb"
+	"github.com/go-ethereum-analysis/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			dbInspectCommand,
+		},
+	}
+	dbInspectCommand = cli.Command{
+		Action:    utils.MigrateFlags(inspect),
+		Name:      "inspect",
+		Usage:     "Inspect the storage size for each type of data in the database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.DatabaseEngineFlag,
+			utils.SyncModeFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Category: "DATABASE COMMANDS",
+		Description: `
+The inspect command iterates over the entire chain database and reports the
+number and total size of the entries of each data type (headers, bodies,
+receipts, trie nodes, indexes, ...). Keys not matching any known schema
+prefix are reported as unaccounted.`,
+	}
+)
+
+// inspect iterates over the chain database and prints its space usage per
+// data category.
+func inspect(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	start := time.Now()
+	stats, err := rawdb.InspectDatabase(db)
+	if err != nil {
+		utils.Fatalf("Inspection failed: %v", err)
+	}
+	log.Info("Inspected database", "elapsed", common.PrettyDuration(time.Since(start)))
+
+	rawdb.WriteDatabaseStats(os.Stdout, stats)
+	return nil
+}
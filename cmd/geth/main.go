@@ -60,6 +60,7 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.DatabaseEngineFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -85,8 +86,15 @@ var (
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.TxLookupLimitFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.CheckpointOracleFlag,
+		utils.CheckpointOracleSignersFlag,
+		utils.CheckpointOracleThresholdFlag,
+		utils.ULCModeConfigFlag,
+		utils.ULCTrustedNodesFlag,
+		utils.ULCMinTrustedFractionFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
@@ -95,6 +103,9 @@ var (
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.DialRatioFlag,
+		utils.MaxPeersPerIPFlag,
+		utils.MaxPeersPerSubnetFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerLegacyThreadsFlag,
@@ -136,11 +147,24 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCMethodsFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
+		utils.WSMethodsFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.AuthRPCEnabledFlag,
+		utils.AuthRPCListenAddrFlag,
+		utils.AuthRPCPortFlag,
+		utils.AuthRPCVirtualHostsFlag,
+		utils.AuthRPCApiFlag,
+		utils.AuthRPCMethodsFlag,
+		utils.JWTSecretFlag,
+		utils.RPCSlowThresholdFlag,
+		utils.RPCBatchLimitFlag,
+		utils.RPCResponseLimitFlag,
+		utils.RPCExecTimeoutFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -177,6 +201,9 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		setHeadCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -56,6 +57,7 @@ import (
 	"github.com/go-ethereum-analysis/p2p/nat"
 	"github.com/go-ethereum-analysis/p2p/netutil"
 	"github.com/go-ethereum-analysis/params"
+	"github.com/go-ethereum-analysis/rpc"
 	whisper "github.com/go-ethereum-analysis/whisper/whisperv6"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -119,6 +121,11 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	DatabaseEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use (" + strings.Join(ethdb.Engines(), ", ") + ")",
+		Value: ethdb.DefaultEngine,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -168,6 +175,11 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to keep transaction lookups for (0 = all blocks)",
+		Value: eth.DefaultConfig.TxLookupLimit,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -178,6 +190,31 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: eth.DefaultConfig.LightPeers,
 	}
+	CheckpointOracleFlag = cli.StringFlag{
+		Name:  "checkpoint.oracle",
+		Usage: "Address of the on-chain checkpoint oracle contract",
+	}
+	CheckpointOracleSignersFlag = cli.StringFlag{
+		Name:  "checkpoint.signers",
+		Usage: "List of trusted checkpoint oracle signers (comma separated addresses)",
+	}
+	CheckpointOracleThresholdFlag = cli.Uint64Flag{
+		Name:  "checkpoint.threshold",
+		Usage: "Number of signatures required to approve an oracle checkpoint (0 = all signers)",
+	}
+	ULCModeConfigFlag = cli.StringFlag{
+		Name:  "ulc.config",
+		Usage: "JSON config file of the ultra light client mode",
+	}
+	ULCTrustedNodesFlag = cli.StringFlag{
+		Name:  "ulc.trusted",
+		Usage: "List of trusted ultra light servers (comma separated enode URLs)",
+	}
+	ULCMinTrustedFractionFlag = cli.IntFlag{
+		Name:  "ulc.fraction",
+		Usage: "Minimum percentage of trusted ultra light servers required to announce a new head",
+		Value: eth.DefaultULCMinTrustedFraction,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -428,6 +465,11 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCMethodsFlag = cli.StringFlag{
+		Name:  "rpcmethods",
+		Usage: "Comma separated method patterns restricting the HTTP-RPC API's (e.g. \"eth_*,!eth_sign*\")",
+		Value: "",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -447,7 +489,7 @@ var (
 	}
 	WSPortFlag = cli.IntFlag{
 		Name:  "wsport",
-		Usage: "WS-RPC server listening port",
+		Usage: "WS-RPC server listening port (may equal --rpcport to share the HTTP listener)",
 		Value: node.DefaultWSPort,
 	}
 	WSApiFlag = cli.StringFlag{
@@ -455,11 +497,67 @@ var (
 		Usage: "API's offered over the WS-RPC interface",
 		Value: "",
 	}
+	WSMethodsFlag = cli.StringFlag{
+		Name:  "wsmethods",
+		Usage: "Comma separated method patterns restricting the WS-RPC API's (e.g. \"eth_*,!eth_sign*\")",
+		Value: "",
+	}
 	WSAllowedOriginsFlag = cli.StringFlag{
 		Name:  "wsorigins",
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	AuthRPCEnabledFlag = cli.BoolFlag{
+		Name:  "authrpc",
+		Usage: "Enable the JWT authenticated HTTP and WS-RPC server",
+	}
+	AuthRPCListenAddrFlag = cli.StringFlag{
+		Name:  "authrpcaddr",
+		Usage: "Authenticated RPC server listening interface",
+		Value: node.DefaultAuthHost,
+	}
+	AuthRPCPortFlag = cli.IntFlag{
+		Name:  "authrpcport",
+		Usage: "Authenticated RPC server listening port",
+		Value: node.DefaultAuthPort,
+	}
+	AuthRPCVirtualHostsFlag = cli.StringFlag{
+		Name:  "authrpcvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept authenticated requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.AuthVirtualHosts, ","),
+	}
+	AuthRPCApiFlag = cli.StringFlag{
+		Name:  "authrpcapi",
+		Usage: "API's offered over the authenticated RPC interface",
+		Value: "",
+	}
+	AuthRPCMethodsFlag = cli.StringFlag{
+		Name:  "authrpcmethods",
+		Usage: "Comma separated method patterns restricting the authenticated RPC API's (e.g. \"eth_*,!eth_sign*\")",
+		Value: "",
+	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "jwtsecret",
+		Usage: "Path to the hex encoded secret authenticated RPC requests must be signed with (default = generated in the datadir)",
+		Value: "",
+	}
+	RPCSlowThresholdFlag = cli.DurationFlag{
+		Name:  "rpcslowthreshold",
+		Usage: "Log RPC method calls taking longer than this duration (0 = disabled)",
+		Value: 0,
+	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpcbatchlimit",
+		Usage: "Maximum number of requests in a batch on the HTTP, WS and authenticated RPC servers (0 = unlimited)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpcresponselimit",
+		Usage: "Maximum number of bytes returned by a (batch) request on the HTTP, WS and authenticated RPC servers (0 = unlimited)",
+	}
+	RPCExecTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcexectimeout",
+		Usage: "Maximum execution time of a method call on the HTTP, WS and authenticated RPC servers (0 = unlimited)",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -480,6 +578,21 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: 0,
 	}
+	DialRatioFlag = cli.IntFlag{
+		Name:  "dialratio",
+		Usage: "Ratio of peer slots to dialed connections, e.g. 3 dials 1/3 of the peers (defaults used if set to 0)",
+		Value: 0,
+	}
+	MaxPeersPerIPFlag = cli.IntFlag{
+		Name:  "maxpeersperip",
+		Usage: "Maximum number of inbound peers from the same IP address (no limit if set to 0)",
+		Value: 0,
+	}
+	MaxPeersPerSubnetFlag = cli.IntFlag{
+		Name:  "maxpeerspersubnet",
+		Usage: "Maximum number of inbound peers from the same /24 (IPv4) or /64 (IPv6) subnet (no limit if set to 0)",
+		Value: 0,
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -787,6 +900,9 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCApiFlag.Name) {
 		cfg.HTTPModules = splitAndTrim(ctx.GlobalString(RPCApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(RPCMethodsFlag.Name) {
+		cfg.HTTPMethods = splitAndTrim(ctx.GlobalString(RPCMethodsFlag.Name))
+	}
 	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	}
@@ -811,6 +927,52 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(WSApiFlag.Name) {
 		cfg.WSModules = splitAndTrim(ctx.GlobalString(WSApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(WSMethodsFlag.Name) {
+		cfg.WSMethods = splitAndTrim(ctx.GlobalString(WSMethodsFlag.Name))
+	}
+}
+
+// setAuthRPC creates the authenticated RPC listener interface string from the
+// set command line flags, returning empty if the endpoint is disabled.
+func setAuthRPC(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(AuthRPCEnabledFlag.Name) && cfg.AuthHost == "" {
+		cfg.AuthHost = "127.0.0.1"
+		if ctx.GlobalIsSet(AuthRPCListenAddrFlag.Name) {
+			cfg.AuthHost = ctx.GlobalString(AuthRPCListenAddrFlag.Name)
+		}
+	}
+
+	if ctx.GlobalIsSet(AuthRPCPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthRPCPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCVirtualHostsFlag.Name) {
+		cfg.AuthVirtualHosts = splitAndTrim(ctx.GlobalString(AuthRPCVirtualHostsFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRPCApiFlag.Name) {
+		cfg.AuthModules = splitAndTrim(ctx.GlobalString(AuthRPCApiFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRPCMethodsFlag.Name) {
+		cfg.AuthMethods = splitAndTrim(ctx.GlobalString(AuthRPCMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
+}
+
+// setRPCLimits applies the request limits set on the command line to all network
+// facing RPC servers.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	for _, limits := range []*rpc.Limits{&cfg.HTTPLimits, &cfg.WSLimits, &cfg.AuthLimits} {
+		if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+			limits.BatchItems = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+		}
+		if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+			limits.ResponseBytes = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+		}
+		if ctx.GlobalIsSet(RPCExecTimeoutFlag.Name) {
+			limits.ExecutionTimeout = ctx.GlobalDuration(RPCExecTimeoutFlag.Name)
+		}
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -966,6 +1128,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(DialRatioFlag.Name) {
+		cfg.DialRatio = ctx.GlobalInt(DialRatioFlag.Name)
+	}
+	if ctx.GlobalIsSet(MaxPeersPerIPFlag.Name) {
+		cfg.MaxInboundPerIP = ctx.GlobalInt(MaxPeersPerIPFlag.Name)
+	}
+	if ctx.GlobalIsSet(MaxPeersPerSubnetFlag.Name) {
+		cfg.MaxInboundPerSubnet = ctx.GlobalInt(MaxPeersPerSubnetFlag.Name)
+	}
 	// Name: "nodiscover"
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
@@ -1013,6 +1184,14 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	// 设置 WS 通信配置
 	setWS(ctx, cfg)
+	// 设置 JWT 认证的 RPC 通信配置
+	setAuthRPC(ctx, cfg)
+	// 设置 RPC 请求的资源限制
+	setRPCLimits(ctx, cfg)
+	// 设置 慢 RPC 请求的日志阈值
+	if ctx.GlobalIsSet(RPCSlowThresholdFlag.Name) {
+		cfg.RPCSlowThreshold = ctx.GlobalDuration(RPCSlowThresholdFlag.Name)
+	}
 	// 设置 节点身份标识 配置
 	setNodeUserIdent(ctx, cfg)
 
@@ -1031,6 +1210,10 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalBool(RinkebyFlag.Name):
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
 	}
+	// Name: "db.engine"
+	if ctx.GlobalIsSet(DatabaseEngineFlag.Name) {
+		cfg.DatabaseEngine = ctx.GlobalString(DatabaseEngineFlag.Name)
+	}
 	// Name: "keystore"
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
@@ -1097,6 +1280,69 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	}
 }
 
+// setULC configures the ultra light client mode from the config file and the
+// command line flags, the latter taking precedence.
+func setULC(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalIsSet(ULCModeConfigFlag.Name) && !ctx.GlobalIsSet(ULCTrustedNodesFlag.Name) {
+		return
+	}
+	cfg.ULC = &eth.ULCConfig{MinTrustedFraction: eth.DefaultULCMinTrustedFraction}
+
+	if path := ctx.GlobalString(ULCModeConfigFlag.Name); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			Fatalf("Failed to read ULC config file: %v", err)
+		}
+		if err := json.Unmarshal(data, cfg.ULC); err != nil {
+			Fatalf("Failed to parse ULC config file: %v", err)
+		}
+	}
+	if ctx.GlobalIsSet(ULCTrustedNodesFlag.Name) {
+		cfg.ULC.TrustedServers = strings.Split(ctx.GlobalString(ULCTrustedNodesFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(ULCMinTrustedFractionFlag.Name) {
+		cfg.ULC.MinTrustedFraction = ctx.GlobalInt(ULCMinTrustedFractionFlag.Name)
+	}
+	if cfg.ULC.MinTrustedFraction <= 0 || cfg.ULC.MinTrustedFraction > 100 {
+		log.Error("Invalid ULC minimum trusted fraction", "have", cfg.ULC.MinTrustedFraction, "want", "1-100")
+		cfg.ULC.MinTrustedFraction = eth.DefaultULCMinTrustedFraction
+	}
+	// Ultra light clients are light clients which don't verify the headers
+	cfg.SyncMode = downloader.LightSync
+}
+
+// setCheckpointOracle configures the on-chain checkpoint oracle from the command
+// line flags.
+func setCheckpointOracle(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalIsSet(CheckpointOracleFlag.Name) {
+		return
+	}
+	addr := ctx.GlobalString(CheckpointOracleFlag.Name)
+	if !common.IsHexAddress(addr) {
+		Fatalf("Invalid checkpoint oracle address %q", addr)
+	}
+	cfg.CheckpointOracle = &params.CheckpointOracleConfig{Address: common.HexToAddress(addr)}
+
+	if signers := ctx.GlobalString(CheckpointOracleSignersFlag.Name); signers != "" {
+		for _, signer := range strings.Split(signers, ",") {
+			if signer = strings.TrimSpace(signer); !common.IsHexAddress(signer) {
+				Fatalf("Invalid checkpoint oracle signer %q", signer)
+			}
+			cfg.CheckpointOracle.Signers = append(cfg.CheckpointOracle.Signers, common.HexToAddress(signer))
+		}
+	}
+	if len(cfg.CheckpointOracle.Signers) == 0 {
+		Fatalf("Checkpoint oracle requires at least one signer (--%s)", CheckpointOracleSignersFlag.Name)
+	}
+	cfg.CheckpointOracle.Threshold = ctx.GlobalUint64(CheckpointOracleThresholdFlag.Name)
+	if cfg.CheckpointOracle.Threshold == 0 {
+		cfg.CheckpointOracle.Threshold = uint64(len(cfg.CheckpointOracle.Signers))
+	}
+	if cfg.CheckpointOracle.Threshold > uint64(len(cfg.CheckpointOracle.Signers)) {
+		Fatalf("Checkpoint oracle threshold %d exceeds the number of signers %d", cfg.CheckpointOracle.Threshold, len(cfg.CheckpointOracle.Signers))
+	}
+}
+
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashCacheDirFlag.Name) {
 		cfg.Ethash.CacheDir = ctx.GlobalString(EthashCacheDirFlag.Name)
@@ -1215,6 +1461,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	setULC(ctx, cfg)
+	setCheckpointOracle(ctx, cfg)
+
 	// 设置 网络ID
 	// Name: "networkid"
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
@@ -1234,6 +1483,10 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
+		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	}
+
 	// Name: "cache" || Name: "cache.gc"
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		// TrieCache ...
@@ -1531,6 +1784,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		TxLookupLimit: ctx.GlobalUint64(TxLookupLimitFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -0,0 +1,87 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build none
+
+// This program generates contract/oracle.go, which contains the binding of the
+// checkpoint oracle assembled from contract/oracle_init.evm and contract/oracle.evm.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/go-ethereum-analysis/accounts/abi/bind"
+	"github.com/go-ethereum-analysis/core/asm"
+)
+
+// assemble compiles an easm source into its hex encoded bytecode.
+func assemble(name string, source []byte) string {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(name, source, false))
+
+	bin, errs := compiler.Compile()
+	if len(errs) != 0 {
+		panic(fmt.Sprintf("%s: %v", name, errs))
+	}
+	return bin
+}
+
+func main() {
+	abi, err := ioutil.ReadFile("contract/oracle.abi")
+	if err != nil {
+		panic(err)
+	}
+	runtimeSrc, err := ioutil.ReadFile("contract/oracle.evm")
+	if err != nil {
+		panic(err)
+	}
+	initSrc, err := ioutil.ReadFile("contract/oracle_init.evm")
+	if err != nil {
+		panic(err)
+	}
+	initTmpl := template.Must(template.New("init").Parse(string(initSrc)))
+	runtime := assemble("oracle.evm", runtimeSrc)
+
+	// The deployment code embeds its own size, iterate until it settles.
+	var init string
+	for size := 0; ; {
+		sizes := map[string]int{
+			"CodeSize":    size + len(runtime)/2,
+			"InitSize":    size,
+			"RuntimeSize": len(runtime) / 2,
+		}
+		buf := new(bytes.Buffer)
+		if err := initTmpl.Execute(buf, sizes); err != nil {
+			panic(err)
+		}
+		init = assemble("oracle_init.evm", buf.Bytes())
+		if len(init)/2 == size {
+			break
+		}
+		size = len(init) / 2
+	}
+	code, err := bind.Bind([]string{"CheckpointOracle"}, []string{strings.TrimSpace(string(abi))}, []string{init + runtime}, "contract", bind.LangGo)
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile("contract/oracle.go", []byte(code), 0644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package checkpointoracle is an on-chain light client checkpoint oracle.
+package checkpointoracle
+
+//go:generate go run ./gencode.go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/go-ethereum-analysis/accounts/abi/bind"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle/contract"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/params"
+)
+
+// errInvalidSignature is returned if a checkpoint signature is malformed.
+var errInvalidSignature = errors.New("invalid signature")
+
+// CheckpointOracle is a Go wrapper around an on-chain checkpoint oracle contract.
+type CheckpointOracle struct {
+	address  common.Address
+	contract *contract.CheckpointOracle
+}
+
+// NewCheckpointOracle binds the checkpoint oracle contract at the given address.
+func NewCheckpointOracle(contractAddr common.Address, backend bind.ContractBackend) (*CheckpointOracle, error) {
+	c, err := contract.NewCheckpointOracle(contractAddr, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckpointOracle{address: contractAddr, contract: c}, nil
+}
+
+// ContractAddr returns the address of the contract.
+func (oracle *CheckpointOracle) ContractAddr() common.Address {
+	return oracle.address
+}
+
+// Contract returns the underlying contract instance.
+func (oracle *CheckpointOracle) Contract() *contract.CheckpointOracle {
+	return oracle.contract
+}
+
+// LatestCheckpoint returns the index, hash and registration height of the latest
+// checkpoint registered in the contract.
+func (oracle *CheckpointOracle) LatestCheckpoint(opts *bind.CallOpts) (uint64, common.Hash, *big.Int, error) {
+	index, hash, height, err := oracle.contract.GetLatestCheckpoint(opts)
+	if err != nil {
+		return 0, common.Hash{}, nil, err
+	}
+	return index, common.Hash(hash), height, nil
+}
+
+// CheckpointSignatures retrieves the signatures approving the given checkpoint
+// from the votes logged in the block it was registered at. The signatures are
+// returned in the 65 byte [R || S || V] format with V being 27 or 28.
+func (oracle *CheckpointOracle) CheckpointSignatures(opts *bind.FilterOpts, index uint64, hash common.Hash) ([][]byte, error) {
+	it, err := oracle.contract.FilterNewCheckpointVote(opts, []uint64{index})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var sigs [][]byte
+	for it.Next() {
+		if common.Hash(it.Event.CheckpointHash) != hash {
+			continue
+		}
+		sig := make([]byte, 65)
+		copy(sig, it.Event.R[:])
+		copy(sig[32:], it.Event.S[:])
+		sig[64] = it.Event.V
+		sigs = append(sigs, sig)
+	}
+	return sigs, it.Error()
+}
+
+// RegisterCheckpoint registers the checkpoint with a batch of associated signatures
+// that are collected off-chain. The signatures are reordered by their signer as
+// the contract requires, so they can be given in any order.
+//
+// The signatures are expected in the 65 byte [R || S || V] format with V being
+// 27 or 28 as per the yellow paper.
+func (oracle *CheckpointOracle) RegisterCheckpoint(opts *bind.TransactOpts, index uint64, hash common.Hash, rnum *big.Int, rhash common.Hash, sigs [][]byte) (*types.Transaction, error) {
+	signers := make([]common.Address, len(sigs))
+	for i, sig := range sigs {
+		signer, err := RecoverSigner(oracle.address, index, hash, sig)
+		if err != nil {
+			return nil, err
+		}
+		signers[i] = signer
+	}
+	sort.Sort(bySigner{sigs, signers})
+
+	var (
+		v = make([]uint8, len(sigs))
+		r = make([][32]byte, len(sigs))
+		s = make([][32]byte, len(sigs))
+	)
+	for i, sig := range sigs {
+		copy(r[i][:], sig[:32])
+		copy(s[i][:], sig[32:64])
+		v[i] = sig[64]
+	}
+	return oracle.contract.SetCheckpoint(opts, rnum, rhash, hash, index, v, r, s)
+}
+
+// bySigner sorts a batch of signatures by the address of their signers.
+type bySigner struct {
+	sigs    [][]byte
+	signers []common.Address
+}
+
+func (s bySigner) Len() int { return len(s.sigs) }
+func (s bySigner) Less(i, j int) bool {
+	return bytes.Compare(s.signers[i][:], s.signers[j][:]) < 0
+}
+func (s bySigner) Swap(i, j int) {
+	s.sigs[i], s.sigs[j] = s.sigs[j], s.sigs[i]
+	s.signers[i], s.signers[j] = s.signers[j], s.signers[i]
+}
+
+// SignatureHash returns the hash signers need to sign to approve a checkpoint,
+// following EIP-191 with intended validator:
+//
+//   keccak256(0x19 || 0x00 || contract address || section index || checkpoint hash)
+func SignatureHash(contractAddr common.Address, index uint64, hash common.Hash) common.Hash {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, index)
+	return crypto.Keccak256Hash([]byte{0x19, 0x00}, contractAddr.Bytes(), buf, hash.Bytes())
+}
+
+// RecoverSigner retrieves the address of the signer of a checkpoint signature.
+func RecoverSigner(contractAddr common.Address, index uint64, hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != 65 || (sig[64] != 27 && sig[64] != 28) {
+		return common.Address{}, errInvalidSignature
+	}
+	// Transform V from 27/28 to 0/1 for the recovery
+	plain := make([]byte, 65)
+	copy(plain, sig)
+	plain[64] -= 27
+
+	pubkey, err := crypto.SigToPub(SignatureHash(contractAddr, index, hash).Bytes(), plain)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// VerifySigners checks that enough trusted signers approved a checkpoint,
+// returning the distinct trusted signers found among the signatures.
+func VerifySigners(config *params.CheckpointOracleConfig, index uint64, hash common.Hash, sigs [][]byte) (bool, []common.Address) {
+	trusted := make(map[common.Address]bool)
+	for _, signer := range config.Signers {
+		trusted[signer] = true
+	}
+	var (
+		signers []common.Address
+		checked = make(map[common.Address]bool)
+	)
+	for _, sig := range sigs {
+		signer, err := RecoverSigner(config.Address, index, hash, sig)
+		if err != nil || !trusted[signer] || checked[signer] {
+			continue
+		}
+		checked[signer] = true
+		signers = append(signers, signer)
+	}
+	if uint64(len(signers)) < config.Threshold || len(signers) == 0 {
+		return false, nil
+	}
+	return true, signers
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package checkpointoracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/go-ethereum-analysis/accounts/abi/bind"
+	"github.com/go-ethereum-analysis/accounts/abi/bind/backends"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle/contract"
+	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/params"
+)
+
+const (
+	sectionSize     = 4 // Section size used by the test oracle
+	processConfirms = 2 // Process confirmations used by the test oracle
+)
+
+var (
+	deployerKey, _ = crypto.GenerateKey()
+	deployerAddr   = crypto.PubkeyToAddress(deployerKey.PublicKey)
+	strangerKey, _ = crypto.GenerateKey()
+	strangerAddr   = crypto.PubkeyToAddress(strangerKey.PublicKey)
+)
+
+// oracleTester is a checkpoint oracle deployed on a simulated chain with a set
+// of admins sorted by address.
+type oracleTester struct {
+	backend *backends.SimulatedBackend
+	oracle  *CheckpointOracle
+	keys    []*ecdsa.PrivateKey
+	admins  []common.Address
+}
+
+// newOracleTester deploys a checkpoint oracle with the given number of admins,
+// requiring threshold signatures to register a checkpoint.
+func newOracleTester(t *testing.T, admins int, threshold uint64) *oracleTester {
+	tester := new(oracleTester)
+	alloc := core.GenesisAlloc{
+		deployerAddr: {Balance: big.NewInt(1000000000000000000)},
+		strangerAddr: {Balance: big.NewInt(1000000000000000000)},
+	}
+	for i := 0; i < admins; i++ {
+		key, _ := crypto.GenerateKey()
+		tester.keys = append(tester.keys, key)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000000000000)}
+	}
+	sort.Slice(tester.keys, func(i, j int) bool {
+		return bytes.Compare(crypto.PubkeyToAddress(tester.keys[i].PublicKey).Bytes(), crypto.PubkeyToAddress(tester.keys[j].PublicKey).Bytes()) < 0
+	})
+	for _, key := range tester.keys {
+		tester.admins = append(tester.admins, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	tester.backend = backends.NewSimulatedBackend(alloc, 10000000)
+
+	addr, _, _, err := contract.DeployCheckpointOracle(bind.NewKeyedTransactor(deployerKey), tester.backend, tester.admins, big.NewInt(sectionSize), big.NewInt(processConfirms), new(big.Int).SetUint64(threshold))
+	if err != nil {
+		t.Fatalf("Failed to deploy checkpoint oracle: %v", err)
+	}
+	tester.backend.Commit()
+
+	if tester.oracle, err = NewCheckpointOracle(addr, tester.backend); err != nil {
+		t.Fatalf("Failed to bind checkpoint oracle: %v", err)
+	}
+	return tester
+}
+
+// mine commits the given number of empty blocks.
+func (tester *oracleTester) mine(n int) {
+	for i := 0; i < n; i++ {
+		tester.backend.Commit()
+	}
+}
+
+// sign creates the signatures of the given admins approving a checkpoint.
+func (tester *oracleTester) sign(index uint64, hash common.Hash, admins ...int) [][]byte {
+	var sigs [][]byte
+	for _, admin := range admins {
+		sig, _ := crypto.Sign(SignatureHash(tester.oracle.ContractAddr(), index, hash).Bytes(), tester.keys[admin])
+		sig[64] += 27
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// register publishes a checkpoint from the given sender, returning whether the
+// transaction executed successfully.
+func (tester *oracleTester) register(t *testing.T, sender *ecdsa.PrivateKey, index uint64, hash common.Hash, sigs [][]byte) bool {
+	head, _ := tester.backend.HeaderByNumber(context.Background(), nil)
+
+	opts := bind.NewKeyedTransactor(sender)
+	opts.GasLimit = 1000000
+
+	tx, err := tester.oracle.RegisterCheckpoint(opts, index, hash, head.Number, head.Hash(), sigs)
+	if err != nil {
+		t.Fatalf("Failed to register checkpoint: %v", err)
+	}
+	tester.backend.Commit()
+
+	receipt, _ := tester.backend.TransactionReceipt(context.Background(), tx.Hash())
+	return receipt.Status == types.ReceiptStatusSuccessful
+}
+
+// checkLatest verifies the latest checkpoint registered in the oracle.
+func (tester *oracleTester) checkLatest(t *testing.T, index uint64, hash common.Hash) {
+	haveIndex, haveHash, _, err := tester.oracle.LatestCheckpoint(nil)
+	if err != nil {
+		t.Fatalf("Failed to retrieve latest checkpoint: %v", err)
+	}
+	if haveIndex != index || haveHash != hash {
+		t.Fatalf("latest checkpoint mismatch: have #%d %x, want #%d %x", haveIndex, haveHash, index, hash)
+	}
+}
+
+// Tests that the admin list is stored on deployment.
+func TestCheckpointOracleAdmins(t *testing.T) {
+	tester := newOracleTester(t, 3, 2)
+
+	admins, err := tester.oracle.Contract().GetAllAdmin(nil)
+	if err != nil {
+		t.Fatalf("Failed to retrieve admins: %v", err)
+	}
+	if len(admins) != len(tester.admins) {
+		t.Fatalf("admin count mismatch: have %d, want %d", len(admins), len(tester.admins))
+	}
+	for i, admin := range admins {
+		if admin != tester.admins[i] {
+			t.Errorf("admin %d mismatch: have %x, want %x", i, admin, tester.admins[i])
+		}
+	}
+	tester.checkLatest(t, 0, common.Hash{})
+}
+
+// Tests that checkpoints approved by enough admins get registered, and that
+// the approving signatures can be retrieved and verified.
+func TestCheckpointOracleRegister(t *testing.T) {
+	tester := newOracleTester(t, 3, 2)
+	tester.mine(sectionSize + processConfirms)
+
+	hash := common.HexToHash("0x01")
+	if !tester.register(t, tester.keys[0], 0, hash, tester.sign(0, hash, 2, 0)) {
+		t.Fatalf("checkpoint registration failed")
+	}
+	tester.checkLatest(t, 0, hash)
+
+	_, _, height, _ := tester.oracle.LatestCheckpoint(nil)
+	end := height.Uint64()
+	sigs, err := tester.oracle.CheckpointSignatures(&bind.FilterOpts{Start: end, End: &end}, 0, hash)
+	if err != nil {
+		t.Fatalf("Failed to retrieve signatures: %v", err)
+	}
+	if len(sigs) != 2 {
+		t.Fatalf("signature count mismatch: have %d, want 2", len(sigs))
+	}
+	config := &params.CheckpointOracleConfig{Address: tester.oracle.ContractAddr(), Signers: tester.admins, Threshold: 2}
+	if ok, signers := VerifySigners(config, 0, hash, sigs); !ok || len(signers) != 2 {
+		t.Fatalf("signature verification failed: ok %v, signers %d", ok, len(signers))
+	}
+	config.Threshold = 3
+	if ok, _ := VerifySigners(config, 0, hash, sigs); ok {
+		t.Fatalf("signatures verified below threshold")
+	}
+}
+
+// Tests that invalid checkpoint registrations are rejected.
+func TestCheckpointOracleRejects(t *testing.T) {
+	tester := newOracleTester(t, 3, 2)
+
+	// Sections not yet processed are ignored
+	hash := common.HexToHash("0x01")
+	tester.register(t, tester.keys[0], 0, hash, tester.sign(0, hash, 0, 1))
+	tester.checkLatest(t, 0, common.Hash{})
+
+	tester.mine(2*sectionSize + processConfirms)
+
+	// Checkpoints with too few, duplicate or foreign signatures are reverted
+	if tester.register(t, tester.keys[0], 0, hash, tester.sign(0, hash, 0)) {
+		t.Errorf("checkpoint registered with too few signatures")
+	}
+	if tester.register(t, tester.keys[0], 0, hash, tester.sign(0, hash, 1, 1)) {
+		t.Errorf("checkpoint registered with duplicate signatures")
+	}
+	if tester.register(t, tester.keys[0], 0, hash, tester.sign(1, hash, 0, 1)) {
+		t.Errorf("checkpoint registered with signatures for another section")
+	}
+	// Checkpoints published by non-admins are reverted
+	if tester.register(t, strangerKey, 0, hash, tester.sign(0, hash, 0, 1)) {
+		t.Errorf("checkpoint registered by a non-admin")
+	}
+	tester.checkLatest(t, 0, common.Hash{})
+
+	// Registered sections cannot be overwritten or rolled back
+	if !tester.register(t, tester.keys[0], 1, hash, tester.sign(1, hash, 0, 1)) {
+		t.Fatalf("checkpoint registration failed")
+	}
+	other := common.HexToHash("0x02")
+	tester.register(t, tester.keys[0], 1, other, tester.sign(1, other, 0, 1))
+	tester.register(t, tester.keys[0], 0, other, tester.sign(0, other, 0, 1))
+	tester.checkLatest(t, 1, hash)
+}
@@ -70,6 +70,7 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TxLookupLimit uint64        // Number of recent blocks to keep transaction lookups for, 0 for the entire chain
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -249,6 +250,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	// Take ownership of this particular state
 	// 获取这个特定状态的 所有权
 	go bc.update()
+
+	// Keep the transaction index within the configured limit
+	bc.wg.Add(1)
+	go bc.maintainTxIndex()
 	// 返回 bc 实例
 	return bc, nil
 }
@@ -496,6 +501,100 @@ func (bc *BlockChain) repair(head **types.Block) error {
 	}
 }
 
+// RepairState regenerates the state of the canonical block with the given
+// number by re-executing all blocks since the nearest ancestor with available
+// state, and sets it as the current head block. It is meant to be used after
+// SetHead, which would otherwise leave the chain at the last block with state
+// (or genesis) when rewinding below the last committed state root.
+func (bc *BlockChain) RepairState(number uint64) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	target := bc.GetBlockByNumber(number)
+	if target == nil {
+		return fmt.Errorf("block #%d not found", number)
+	}
+	// Find the nearest ancestor that still has its state available
+	block := target
+	statedb, err := state.New(block.Root(), bc.stateCache)
+	for err != nil {
+		if block.NumberU64() == 0 {
+			return errors.New("genesis state missing")
+		}
+		parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("block #%d not found", block.NumberU64()-1)
+		}
+		block = parent
+		statedb, err = state.New(block.Root(), bc.stateCache)
+	}
+	// State was available at a historical point, regenerate up to the target
+	var (
+		triedb = bc.stateCache.TrieDB()
+		limit  = common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+		start  = time.Now()
+		logged time.Time
+		proot  common.Hash
+	)
+	if block.NumberU64() < number {
+		log.Info("Regenerating chain state", "from", block.NumberU64(), "target", number)
+	}
+	for block.NumberU64() < number {
+		// Print progress logs if long enough time elapsed
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Regenerating chain state", "block", block.NumberU64()+1, "target", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		parent := block
+		if block = bc.GetBlockByNumber(parent.NumberU64() + 1); block == nil {
+			return fmt.Errorf("block #%d not found", parent.NumberU64()+1)
+		}
+		// Re-execute the block on top of the parent state and validate the result
+		receipts, _, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		if err != nil {
+			return err
+		}
+		if err := bc.Validator().ValidateState(block, parent, statedb, receipts, usedGas); err != nil {
+			return err
+		}
+		root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+		if err != nil {
+			return err
+		}
+		if err := statedb.Reset(root); err != nil {
+			return err
+		}
+		// Only keep the most recent state referenced, flushing it if memory runs low
+		triedb.Reference(root, common.Hash{})
+		if proot != (common.Hash{}) {
+			triedb.Dereference(proot)
+		}
+		proot = root
+
+		if nodes, _ := triedb.Size(); nodes > limit {
+			if err := triedb.Commit(root, true); err != nil {
+				return err
+			}
+		}
+	}
+	// Persist the regenerated state and make its block the new head
+	if err := triedb.Commit(block.Root(), true); err != nil {
+		return err
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bc.currentBlock.Store(block)
+	rawdb.WriteHeadBlockHash(bc.db, block.Hash())
+
+	if bc.CurrentFastBlock().NumberU64() < block.NumberU64() {
+		bc.currentFastBlock.Store(block)
+		rawdb.WriteHeadFastBlockHash(bc.db, block.Hash())
+	}
+	log.Info("Chain state regenerated", "number", block.Number(), "hash", block.Hash(), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // Export writes the active chain to the given writer.
 func (bc *BlockChain) Export(w io.Writer) error {
 	return bc.ExportN(w, uint64(0), bc.CurrentBlock().NumberU64())
@@ -877,6 +976,9 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		start = time.Now()
 		bytes = 0
 		batch = bc.db.NewBatch()
+
+		// Transactions below the index target would be unindexed right away
+		txIndexTarget = bc.txIndexTarget(bc.CurrentHeader().Number.Uint64())
 	)
 	for i, block := range blockChain {
 		receipts := receiptChain[i]
@@ -900,7 +1002,9 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		// Write all the data out into the database
 		rawdb.WriteBody(batch, block.Hash(), block.NumberU64(), block.Body())
 		rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
-		rawdb.WriteTxLookupEntries(batch, block)
+		if block.NumberU64() >= txIndexTarget {
+			rawdb.WriteTxLookupEntries(batch, block)
+		}
 
 		stats.processed++
 
@@ -1483,3 +1483,116 @@ func BenchmarkBlockChain_1x1000Executions(b *testing.B) {
 
 	benchmarkLargeNumberOfValueToNonexisting(b, numTxs, numBlocks, recipientFn, dataFn)
 }
+
+// Tests that rewinding the chain below the last committed state and repairing it
+// regenerates the missing state instead of dropping back to genesis.
+func TestSetHeadRepairState(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*triesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	// Import the chain, garbage collecting all but the most recent tries
+	diskdb := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Rewind to a block without state, the head should fall back to genesis
+	number := uint64(triesInMemory / 2)
+	if err := chain.SetHead(number); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("stateless rewind head mismatch: have %d, want 0", head)
+	}
+	if err := chain.RepairState(number + 1); err == nil {
+		t.Fatalf("repaired state above the header chain")
+	}
+	// Repair the state and ensure it's persisted and the chain can be extended
+	if err := chain.RepairState(number); err != nil {
+		t.Fatalf("failed to repair state: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[number-1].Hash() {
+		t.Fatalf("repaired head mismatch: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), number, blocks[number-1].Hash())
+	}
+	if _, err := state.New(blocks[number-1].Root(), state.NewDatabase(diskdb)); err != nil {
+		t.Fatalf("repaired state not persisted: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[number:]); err != nil {
+		t.Fatalf("failed to reinsert chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("reinserted head mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+}
+
+// Tests that the transaction index only covers the most recent blocks if limited,
+// and that it's recreated backwards if the limit is lifted.
+func TestTxLookupLimit(t *testing.T) {
+	var (
+		gendb   = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 32, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	// check waits for the indexer to reach the tail and verifies the lookups
+	check := func(chain *BlockChain, tail uint64) {
+		for i := 0; ; i++ {
+			if progress := chain.TxIndexProgress(); progress.Done() && progress.Tail == tail {
+				break
+			}
+			if i == 100 {
+				t.Fatalf("index tail not reached: have %+v, want %d", chain.TxIndexProgress(), tail)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		for _, block := range blocks {
+			indexed := block.NumberU64() >= tail
+			if tx, _, _, _ := rawdb.ReadTransaction(db, block.Transactions()[0].Hash()); (tx != nil) != indexed {
+				t.Fatalf("block %d: index mismatch: have %v, want %v", block.NumberU64(), tx != nil, indexed)
+			}
+		}
+	}
+	chain, err := NewBlockChain(db, &CacheConfig{TxLookupLimit: 8}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	check(chain, 25)
+	chain.Stop()
+
+	// Lift the limit and ensure the old blocks are reindexed
+	chain, err = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	check(chain, 0)
+}
@@ -17,8 +17,12 @@
 package rawdb
 
 import (
+	"encoding/binary"
+	"time"
+
 	"github.com/go-ethereum-analysis/common"
 	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/ethdb"
 	"github.com/go-ethereum-analysis/log"
 	"github.com/go-ethereum-analysis/rlp"
 )
@@ -41,10 +45,14 @@ func ReadTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64
 // WriteTxLookupEntries stores a positional metadata for every transaction from
 // a block, enabling hash based transaction and receipt lookups.
 func WriteTxLookupEntries(db DatabaseWriter, block *types.Block) {
-	for i, tx := range block.Transactions() {
+	writeTxLookupEntries(db, block.Hash(), block.NumberU64(), block.Transactions())
+}
+
+func writeTxLookupEntries(db DatabaseWriter, hash common.Hash, number uint64, txs types.Transactions) {
+	for i, tx := range txs {
 		entry := TxLookupEntry{
-			BlockHash:  block.Hash(),
-			BlockIndex: block.NumberU64(),
+			BlockHash:  hash,
+			BlockIndex: number,
 			Index:      uint64(i),
 		}
 		data, err := rlp.EncodeToBytes(entry)
@@ -62,6 +70,136 @@ func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(txLookupKey(hash))
 }
 
+// ReadTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed. Nil is returned if the index was never pruned, in which case all
+// blocks are indexed.
+func ReadTxIndexTail(db DatabaseReader) *uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions are
+// indexed.
+func WriteTxIndexTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction index tail", "err", err)
+	}
+}
+
+// IndexTransactions creates the transaction lookup entries of the canonical
+// blocks in the range [from, to). Blocks are processed backwards, moving the
+// index tail down as they are done, so an interrupted run can be resumed.
+func IndexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}) {
+	var (
+		batch  = db.NewBatch()
+		tail   = to
+		txs    int
+		start  = time.Now()
+		logged = time.Now()
+	)
+loop:
+	for tail > from {
+		// Abort if the indexing was interrupted, keeping the progress
+		select {
+		case <-interrupt:
+			break loop
+		default:
+		}
+		number := tail - 1
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			log.Error("Canonical hash missing, can't index transactions", "number", number)
+			break
+		}
+		body := ReadBody(db, hash, number)
+		if body == nil {
+			log.Error("Block body missing, can't index transactions", "number", number, "hash", hash)
+			break
+		}
+		writeTxLookupEntries(batch, hash, number, body.Transactions)
+		txs += len(body.Transactions)
+		tail = number
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			WriteTxIndexTail(batch, tail)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write transaction indices", "err", err)
+			}
+			batch.Reset()
+		}
+		// Indexing a long range takes a while, report some progress
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing transactions", "block", tail, "target", from, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if tail == to {
+		return
+	}
+	WriteTxIndexTail(batch, tail)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write transaction indices", "err", err)
+	}
+	log.Info("Indexed transactions", "blocks", to-tail, "txs", txs, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// UnindexTransactions removes the transaction lookup entries of the canonical
+// blocks in the range [from, to). Blocks are processed forwards, moving the
+// index tail up as they are done, so an interrupted run can be resumed.
+func UnindexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}) {
+	var (
+		batch  = db.NewBatch()
+		tail   = from
+		txs    int
+		start  = time.Now()
+		logged = time.Now()
+	)
+loop:
+	for tail < to {
+		// Abort if the unindexing was interrupted, keeping the progress
+		select {
+		case <-interrupt:
+			break loop
+		default:
+		}
+		// Blocks without a body have no lookup entries to remove either
+		if hash := ReadCanonicalHash(db, tail); hash != (common.Hash{}) {
+			if body := ReadBody(db, hash, tail); body != nil {
+				for _, tx := range body.Transactions {
+					DeleteTxLookupEntry(batch, tx.Hash())
+				}
+				txs += len(body.Transactions)
+			}
+		}
+		tail++
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			WriteTxIndexTail(batch, tail)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete transaction indices", "err", err)
+			}
+			batch.Reset()
+		}
+		// Unindexing a long range takes a while, report some progress
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Unindexing transactions", "block", tail, "target", to, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if tail == from {
+		return
+	}
+	WriteTxIndexTail(batch, tail)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete transaction indices", "err", err)
+	}
+	log.Info("Unindexed transactions", "blocks", tail-from, "txs", txs, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
 // ReadTransaction retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func ReadTransaction(db DatabaseReader, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
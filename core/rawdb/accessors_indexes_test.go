@@ -66,3 +66,50 @@ func TestLookupStorage(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the transaction index of block ranges can be created and removed,
+// moving the index tail along.
+func TestTxIndexing(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	for i := 0; i < 10; i++ {
+		tx := types.NewTransaction(uint64(i), common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), nil)
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, []*types.Transaction{tx}, nil, nil)
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		blocks = append(blocks, block)
+	}
+	check := func(tail uint64) {
+		t.Helper()
+		if have := ReadTxIndexTail(db); have == nil || *have != tail {
+			t.Fatalf("index tail mismatch: have %v, want %d", have, tail)
+		}
+		for i, block := range blocks {
+			hash, _, _ := ReadTxLookupEntry(db, block.Transactions()[0].Hash())
+			if indexed := uint64(i) >= tail; indexed != (hash != common.Hash{}) {
+				t.Fatalf("block %d: index mismatch: have %v, want %v", i, !indexed, indexed)
+			}
+		}
+	}
+	if tail := ReadTxIndexTail(db); tail != nil {
+		t.Fatalf("non existent index tail returned: %d", *tail)
+	}
+	IndexTransactions(db, 0, 10, nil)
+	check(0)
+
+	UnindexTransactions(db, 0, 6, nil)
+	check(6)
+
+	IndexTransactions(db, 3, 6, nil)
+	check(3)
+
+	// Interrupted runs must not move the tail
+	interrupt := make(chan struct{})
+	close(interrupt)
+
+	IndexTransactions(db, 0, 3, interrupt)
+	check(3)
+	UnindexTransactions(db, 3, 10, interrupt)
+	check(3)
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/log"
+	"github.com/olekukonko/tablewriter"
+)
+
+// DatabaseStat is the number of items and the total size of the keys and
+// values belonging to a category of database entries.
+type DatabaseStat struct {
+	Category string
+	Count    uint64
+	Size     common.StorageSize
+}
+
+func (s *DatabaseStat) add(size int) {
+	s.Count++
+	s.Size += common.StorageSize(size)
+}
+
+// Categories of database entries reported by InspectDatabase, in display order.
+const (
+	statHeaders = iota
+	statBodies
+	statReceipts
+	statTds
+	statCanonicalHashes
+	statHeaderNumbers
+	statTxLookups
+	statBloomBits
+	statTries
+	statPreimages
+	statCht
+	statBloomTrie
+	statIndexes
+	statMetadata
+	statUnaccounted
+	statCount
+)
+
+var statNames = [statCount]string{
+	statHeaders:         "Headers",
+	statBodies:          "Bodies",
+	statReceipts:        "Receipts",
+	statTds:             "Difficulties",
+	statCanonicalHashes: "Block number->hash",
+	statHeaderNumbers:   "Block hash->number",
+	statTxLookups:       "Transaction index",
+	statBloomBits:       "Bloombit index",
+	statTries:           "Trie nodes and contract codes",
+	statPreimages:       "Trie preimages",
+	statCht:             "CHT trie nodes",
+	statBloomTrie:       "Bloom trie nodes",
+	statIndexes:         "Chain indexer progress",
+	statMetadata:        "Singleton metadata",
+	statUnaccounted:     "Unaccounted",
+}
+
+// metadataKeys are the database keys holding a single chain wide value.
+var metadataKeys = [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, txIndexTailKey}
+
+// classifyKey returns the category a database key belongs to. Trie nodes and
+// contract codes are both keyed by the hash of their content, so they can't be
+// told apart.
+func classifyKey(key []byte) int {
+	var (
+		numLen    = 8
+		hashLen   = common.HashLength
+		hasPrefix = func(prefix []byte, length int) bool {
+			return bytes.HasPrefix(key, prefix) && len(key) == len(prefix)+length
+		}
+	)
+	switch {
+	case hasPrefix(headerPrefix, numLen+hashLen):
+		return statHeaders
+	case hasPrefix(headerPrefix, numLen+hashLen+len(headerTDSuffix)) && bytes.HasSuffix(key, headerTDSuffix):
+		return statTds
+	case hasPrefix(headerPrefix, numLen+len(headerHashSuffix)) && bytes.HasSuffix(key, headerHashSuffix):
+		return statCanonicalHashes
+	case hasPrefix(headerNumberPrefix, hashLen):
+		return statHeaderNumbers
+	case hasPrefix(blockBodyPrefix, numLen+hashLen):
+		return statBodies
+	case hasPrefix(blockReceiptsPrefix, numLen+hashLen):
+		return statReceipts
+	case hasPrefix(txLookupPrefix, hashLen):
+		return statTxLookups
+	case hasPrefix(bloomBitsPrefix, 2+numLen+hashLen):
+		return statBloomBits
+	case hasPrefix(preimagePrefix, hashLen):
+		return statPreimages
+	case len(key) == hashLen:
+		return statTries
+	case bytes.HasPrefix(key, ChtPrefix), bytes.HasPrefix(key, ChtTablePrefix):
+		return statCht
+	case bytes.HasPrefix(key, BloomTriePrefix), bytes.HasPrefix(key, BloomTrieTablePrefix):
+		return statBloomTrie
+	case bytes.HasPrefix(key, BloomBitsIndexPrefix), bytes.HasPrefix(key, ChtIndexTablePrefix), bytes.HasPrefix(key, BloomTrieIndexPrefix):
+		return statIndexes
+	case bytes.HasPrefix(key, configPrefix):
+		return statMetadata
+	}
+	for _, meta := range metadataKeys {
+		if bytes.Equal(key, meta) {
+			return statMetadata
+		}
+	}
+	return statUnaccounted
+}
+
+// InspectDatabase iterates over the entire database and gathers the number and
+// size of the entries in each category of the database schema.
+func InspectDatabase(db DatabaseIteratee) ([]DatabaseStat, error) {
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+
+	var (
+		stats  = make([]DatabaseStat, statCount)
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for i := range stats {
+		stats[i].Category = statNames[i]
+	}
+	for it.Next() {
+		key := it.Key()
+		stats[classifyKey(key)].add(len(key) + len(it.Value()))
+
+		// Inspecting a full chain takes a while, report some progress
+		count++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// WriteDatabaseStats renders the database statistics as a table.
+func WriteDatabaseStats(w io.Writer, stats []DatabaseStat) {
+	var (
+		rows  [][]string
+		count uint64
+		total common.StorageSize
+	)
+	for _, stat := range stats {
+		rows = append(rows, []string{stat.Category, fmt.Sprintf("%d", stat.Count), stat.Size.String()})
+		count += stat.Count
+		total += stat.Size
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetAutoFormatHeaders(false) // Would mangle the sizes in the footer
+	table.SetHeader([]string{"Category", "Items", "Size"})
+	table.SetFooter([]string{"Total", fmt.Sprintf("%d", count), total.String()})
+	table.AppendBulk(rows)
+	table.Render()
+}
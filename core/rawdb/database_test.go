@@ -0,0 +1,83 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/ethdb"
+)
+
+// Tests that database entries are attributed to the correct schema categories.
+func TestInspectDatabase(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("test block")})
+	WriteBlock(db, block)
+	WriteTd(db, block.Hash(), 1, big.NewInt(1))
+	WriteCanonicalHash(db, block.Hash(), 1)
+	WriteReceipts(db, block.Hash(), 1, nil)
+	WriteHeadBlockHash(db, block.Hash())
+	WriteBloomBits(db, 1, 0, block.Hash(), []byte{0x01})
+	WritePreimages(db, 1, map[common.Hash][]byte{{0x01}: []byte("preimage")})
+
+	db.Put(common.Hash{0x02}.Bytes(), []byte("trie node"))
+	db.Put(append(ChtTablePrefix, common.Hash{0x03}.Bytes()...), []byte("cht node"))
+	db.Put(append(BloomTrieIndexPrefix, []byte("count")...), []byte{0x01})
+	db.Put([]byte("unknown"), []byte("data"))
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("inspection failed: %v", err)
+	}
+	want := map[string]uint64{
+		"Headers":                       1,
+		"Bodies":                        1,
+		"Receipts":                      1,
+		"Difficulties":                  1,
+		"Block number->hash":            1,
+		"Block hash->number":            1,
+		"Bloombit index":                1,
+		"Trie nodes and contract codes": 1,
+		"Trie preimages":                1,
+		"CHT trie nodes":                1,
+		"Chain indexer progress":        1,
+		"Singleton metadata":            1,
+		"Unaccounted":                   1,
+	}
+	for _, stat := range stats {
+		if stat.Count != want[stat.Category] {
+			t.Errorf("%s: item count mismatch: have %d, want %d", stat.Category, stat.Count, want[stat.Category])
+		}
+		if stat.Count > 0 && stat.Size == 0 {
+			t.Errorf("%s: size not accounted", stat.Category)
+		}
+	}
+	// Make sure the report renders every category
+	var buf bytes.Buffer
+	WriteDatabaseStats(&buf, stats)
+	for _, stat := range stats {
+		if !strings.Contains(buf.String(), stat.Category) {
+			t.Errorf("%s: category missing from report", stat.Category)
+		}
+	}
+}
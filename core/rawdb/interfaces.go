@@ -16,6 +16,8 @@
 
 package rawdb
 
+import "github.com/go-ethereum-analysis/ethdb"
+
 // DatabaseReader wraps the Has and Get method of a backing data store.
 type DatabaseReader interface {
 	Has(key []byte) (bool, error)
@@ -31,3 +33,8 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// DatabaseIteratee wraps the NewIterator method of a backing data store.
+type DatabaseIteratee interface {
+	NewIterator(prefix []byte, start []byte) ethdb.Iterator
+}
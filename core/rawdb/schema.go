@@ -41,6 +41,9 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
+	txIndexTailKey = []byte("TransactionIndexTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	//
 	// 数据项前缀（使用单字节以避免混合数据类型，避免使用“ i”作为索引）
@@ -61,6 +64,14 @@ var (
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
+	// Light client helper trie prefixes, maintained by the light package.
+	ChtPrefix            = []byte("chtRoot-")  // ChtPrefix + chtNum (uint64 big endian) + hash -> trie root hash
+	ChtTablePrefix       = []byte("cht-")      // ChtTablePrefix + hash -> canonical hash trie node
+	ChtIndexTablePrefix  = []byte("chtIndex-") // ChtIndexTablePrefix is the data table of the CHT indexer to track its progress
+	BloomTriePrefix      = []byte("bltRoot-")  // BloomTriePrefix + bloomTrieNum (uint64 big endian) + hash -> trie root hash
+	BloomTrieTablePrefix = []byte("blt-")      // BloomTrieTablePrefix + hash -> bloom trie node
+	BloomTrieIndexPrefix = []byte("bltIndex-") // BloomTrieIndexPrefix is the data table of the bloom trie indexer to track its progress
+
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
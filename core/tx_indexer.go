@@ -0,0 +1,128 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/go-ethereum-analysis/core/rawdb"
+)
+
+// TxIndexProgress describes how far the transaction index is from covering the
+// range of blocks it is configured to.
+type TxIndexProgress struct {
+	Limit     uint64 // Number of recent blocks to keep indexed, 0 for the entire chain
+	Tail      uint64 // Oldest block whose transactions are indexed
+	Remaining uint64 // Number of blocks below the tail still waiting to be indexed
+}
+
+// Done returns whether all blocks in the configured range are indexed.
+func (p TxIndexProgress) Done() bool {
+	return p.Remaining == 0
+}
+
+// txIndexTarget returns the oldest block whose transactions should be indexed
+// for the given chain head.
+func (bc *BlockChain) txIndexTarget(head uint64) uint64 {
+	limit := bc.cacheConfig.TxLookupLimit
+	if limit == 0 || head < limit {
+		return 0
+	}
+	return head - limit + 1
+}
+
+// TxIndexProgress retrieves the current progress of the transaction indexer.
+func (bc *BlockChain) TxIndexProgress() TxIndexProgress {
+	progress := TxIndexProgress{Limit: bc.cacheConfig.TxLookupLimit}
+	if tail := rawdb.ReadTxIndexTail(bc.db); tail != nil {
+		progress.Tail = *tail
+	}
+	if target := bc.txIndexTarget(bc.CurrentBlock().NumberU64()); progress.Tail > target {
+		progress.Remaining = progress.Tail - target
+	}
+	return progress
+}
+
+// maintainTxIndex is responsible for keeping the transaction lookup entries of
+// the most recent TxLookupLimit blocks, deleting older ones as the chain grows
+// and recreating them backwards if the limit is raised. New blocks are indexed
+// as they are inserted, this only moves the tail of the index.
+//
+// Only one indexing run is active at a time, head events arriving meanwhile
+// are handled by the next run.
+func (bc *BlockChain) maintainTxIndex() {
+	defer bc.wg.Done()
+
+	var (
+		done      chan struct{}
+		interrupt = make(chan struct{})
+	)
+	run := func(head uint64) {
+		done = make(chan struct{})
+		go bc.indexTransactions(head, done, interrupt)
+	}
+	headCh := make(chan ChainHeadEvent, 1)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// Bring the index in line with the configured limit right away
+	run(bc.CurrentBlock().NumberU64())
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				run(head.Block.NumberU64())
+			}
+		case <-done:
+			done = nil
+
+		case <-bc.quit:
+			close(interrupt)
+			if done != nil {
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// indexTransactions moves the tail of the transaction index to the target of
+// the given chain head, indexing or unindexing blocks as needed.
+func (bc *BlockChain) indexTransactions(head uint64, done chan struct{}, interrupt chan struct{}) {
+	defer close(done)
+
+	// A missing tail means the index was never pruned, covering all blocks
+	var tail uint64
+	if t := rawdb.ReadTxIndexTail(bc.db); t != nil {
+		tail = *t
+	}
+	target := bc.txIndexTarget(head)
+
+	switch {
+	case tail > target:
+		// The limit was raised (or the chain rewound), reindex backwards. Blocks
+		// above the head are not canonical (any more), skip them.
+		if tail > head+1 {
+			tail = head + 1
+		}
+		rawdb.IndexTransactions(bc.db, target, tail, interrupt)
+
+	case tail < target:
+		rawdb.UnindexTransactions(bc.db, tail, target, interrupt)
+	}
+}
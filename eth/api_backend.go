@@ -52,9 +52,15 @@ func (b *EthAPIBackend) CurrentBlock() *types.Block {
 	return b.eth.blockchain.CurrentBlock()
 }
 
-func (b *EthAPIBackend) SetHead(number uint64) {
+func (b *EthAPIBackend) SetHead(number uint64, repair bool) error {
 	b.eth.protocolManager.downloader.Cancel()
-	b.eth.blockchain.SetHead(number)
+	if err := b.eth.blockchain.SetHead(number); err != nil {
+		return err
+	}
+	if repair {
+		return b.eth.blockchain.RepairState(number)
+	}
+	return nil
 }
 
 func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
@@ -113,6 +119,10 @@ func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (type
 	return nil, nil
 }
 
+func (b *EthAPIBackend) TxIndexProgress() core.TxIndexProgress {
+	return b.eth.blockchain.TxIndexProgress()
+}
+
 func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
 	number := rawdb.ReadHeaderNumber(b.eth.chainDb, hash)
 	if number == nil {
@@ -185,6 +195,16 @@ func (b *EthAPIBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.eth.txPool.Get(hash)
 }
 
+func (b *EthAPIBackend) GetTransactionStatus(ctx context.Context, hash common.Hash) (core.TxStatus, *rawdb.TxLookupEntry, error) {
+	if status := b.eth.txPool.Status([]common.Hash{hash})[0]; status != core.TxStatusUnknown {
+		return status, nil, nil
+	}
+	if blockHash, blockNumber, index := rawdb.ReadTxLookupEntry(b.eth.ChainDb(), hash); blockHash != (common.Hash{}) {
+		return core.TxStatusIncluded, &rawdb.TxLookupEntry{BlockHash: blockHash, BlockIndex: blockNumber, Index: index}, nil
+	}
+	return core.TxStatusUnknown, nil, nil
+}
+
 func (b *EthAPIBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.eth.txPool.State().GetNonce(addr), nil
 }
@@ -0,0 +1,196 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+
+	ethereum "github.com/go-ethereum-analysis"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/common/hexutil"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/eth/filters"
+	"github.com/go-ethereum-analysis/event"
+	"github.com/go-ethereum-analysis/internal/ethapi"
+	"github.com/go-ethereum-analysis/rlp"
+	"github.com/go-ethereum-analysis/rpc"
+)
+
+// ContractBackend implements bind.ContractBackend with direct calls to Ethereum
+// internals to support operations requiring more than simple calls / transactions,
+// such as contract bindings running inside the node itself.
+type ContractBackend struct {
+	eapi  *ethapi.PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
+	bcapi *ethapi.PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
+	txapi *ethapi.PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+
+	backend *EthAPIBackend       // Backend used to filter historical logs
+	events  *filters.EventSystem // Event system used to subscribe to new logs
+}
+
+// NewContractBackend creates a new native contract backend using an existing
+// Ethereum object.
+func NewContractBackend(apiBackend *EthAPIBackend) *ContractBackend {
+	return &ContractBackend{
+		eapi:    ethapi.NewPublicEthereumAPI(apiBackend),
+		bcapi:   ethapi.NewPublicBlockChainAPI(apiBackend),
+		txapi:   ethapi.NewPublicTransactionPoolAPI(apiBackend, new(ethapi.AddrLocker)),
+		backend: apiBackend,
+		events:  filters.NewEventSystem(apiBackend.EventMux(), apiBackend, false),
+	}
+}
+
+// CodeAt retrieves any code associated with the contract from the local API.
+func (b *ContractBackend) CodeAt(ctx context.Context, contract common.Address, blockNum *big.Int) ([]byte, error) {
+	return b.bcapi.GetCode(ctx, contract, toBlockNumber(blockNum))
+}
+
+// PendingCodeAt retrieves any code associated with the contract from the local
+// API in the pending state.
+func (b *ContractBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	return b.bcapi.GetCode(ctx, contract, rpc.PendingBlockNumber)
+}
+
+// CallContract implements bind.ContractCaller executing an Ethereum contract
+// call with the specified data as the input.
+func (b *ContractBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNum *big.Int) ([]byte, error) {
+	return b.bcapi.Call(ctx, toCallArgs(msg), toBlockNumber(blockNum))
+}
+
+// PendingCallContract implements bind.PendingContractCaller executing an Ethereum
+// contract call with the specified data as the input in the pending state.
+func (b *ContractBackend) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	return b.bcapi.Call(ctx, toCallArgs(msg), rpc.PendingBlockNumber)
+}
+
+func toCallArgs(msg ethereum.CallMsg) ethapi.CallArgs {
+	args := ethapi.CallArgs{
+		To:   msg.To,
+		From: msg.From,
+		Data: msg.Data,
+		Gas:  hexutil.Uint64(msg.Gas),
+	}
+	if msg.GasPrice != nil {
+		args.GasPrice = hexutil.Big(*msg.GasPrice)
+	}
+	if msg.Value != nil {
+		args.Value = hexutil.Big(*msg.Value)
+	}
+	return args
+}
+
+func toBlockNumber(num *big.Int) rpc.BlockNumber {
+	if num == nil {
+		return rpc.LatestBlockNumber
+	}
+	return rpc.BlockNumber(num.Int64())
+}
+
+// PendingNonceAt implements bind.ContractTransactor retrieving the current
+// pending nonce associated with an account.
+func (b *ContractBackend) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	out, err := b.txapi.GetTransactionCount(ctx, account, rpc.PendingBlockNumber)
+	if out != nil {
+		nonce = uint64(*out)
+	}
+	return nonce, err
+}
+
+// SuggestGasPrice implements bind.ContractTransactor retrieving the currently
+// suggested gas price to allow a timely execution of a transaction.
+func (b *ContractBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := b.eapi.GasPrice(ctx)
+	return (*big.Int)(price), err
+}
+
+// EstimateGas implements bind.ContractTransactor trying to estimate the gas
+// needed to execute a specific transaction based on the current pending state of
+// the backend blockchain. There is no guarantee that this is the true gas limit
+// requirement as other transactions may be added or removed by miners, but it
+// should provide a basis for setting a reasonable default.
+func (b *ContractBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	gas, err := b.bcapi.EstimateGas(ctx, toCallArgs(msg))
+	return uint64(gas), err
+}
+
+// SendTransaction implements bind.ContractTransactor injects the transaction
+// into the pending pool for execution.
+func (b *ContractBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	raw, _ := rlp.EncodeToBytes(tx)
+	_, err := b.txapi.SendRawTransaction(ctx, raw)
+	return err
+}
+
+// FilterLogs implements bind.ContractFilterer executing a log filtering query
+// against the local chain.
+func (b *ContractBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var filter *filters.Filter
+	if query.BlockHash != nil {
+		filter = filters.NewBlockFilter(b.backend, *query.BlockHash, query.Addresses, query.Topics)
+	} else {
+		from, to := int64(0), int64(rpc.LatestBlockNumber)
+		if query.FromBlock != nil {
+			from = query.FromBlock.Int64()
+		}
+		if query.ToBlock != nil {
+			to = query.ToBlock.Int64()
+		}
+		filter = filters.NewRangeFilter(b.backend, from, to, query.Addresses, query.Topics)
+	}
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Log, len(logs))
+	for i, log := range logs {
+		res[i] = *log
+	}
+	return res, nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer creating a background
+// log filtering operation, returning a subscription immediately, which can be
+// used to stream the found events.
+func (b *ContractBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sink := make(chan []*types.Log)
+	sub, err := b.events.SubscribeLogs(query, sink)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case logs := <-sink:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
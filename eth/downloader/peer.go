@@ -37,6 +37,7 @@ import (
 const (
 	maxLackingHashes  = 4096 // Maximum number of entries allowed on the list or lacking items
 	measurementImpact = 0.1  // The impact a single measurement has on a peer's final throughput value.
+	maxTaggedRequests = 4    // Maximum number of concurrent retrievals of a single type to a request id capable peer
 )
 
 var (
@@ -53,16 +54,6 @@ peerConnection表示一个活动peer，可从中检索 Hash和 Block。
 type peerConnection struct {
 	id string // Unique identifier of the peer
 
-	// 对端peer的当前 header的活动状态
-	headerIdle  int32 // Current header activity state of the peer (idle = 0, active = 1)
-	// 对端peer的当前block的活动状态
-	blockIdle   int32 // Current block activity state of the peer (idle = 0, active = 1)
-	// 对端peer的当前 receipt的活动状态
-	receiptIdle int32 // Current receipt activity state of the peer (idle = 0, active = 1)
-	// 对端peer的当前 state trie node的数据活动状态
-	stateIdle   int32 // Current node data activity state of the peer (idle = 0, active = 1)
-
-
 	// 每秒可检索的标头数量
 	headerThroughput  float64 // Number of headers measured to be retrievable per second
 	// 每秒可检索的块（实体）数
@@ -75,19 +66,16 @@ type peerConnection struct {
 	// 请求往返时间, 用来跟踪响应
 	rtt time.Duration // Request round trip time to track responsiveness (QoS)
 
-	// 开始获取最后一个 header 的时间
-	headerStarted  time.Time // Time instance when the last header fetch was started
-	// 开始获取最后一个 block（ body ）的时间
-	blockStarted   time.Time // Time instance when the last block (body) fetch was started
-	// 开始获取最后一次 receipt 的时间
-	receiptStarted time.Time // Time instance when the last receipt fetch was started
-	// 开始获取最后一个 state trie node数据的时间
-	stateStarted   time.Time // Time instance when the last node data fetch was started
+	headerStarted  map[uint64]time.Time // Start times of the in-flight header fetches, keyed by request id
+	blockStarted   map[uint64]time.Time // Start times of the in-flight block (body) fetches, keyed by request id
+	receiptStarted map[uint64]time.Time // Start times of the in-flight receipt fetches, keyed by request id
+	stateStarted   map[uint64]time.Time // Start times of the in-flight node data fetches, keyed by request id
 
 	// 不需要请求的哈希集（以前没有） 什么 Hash 值? state trie Hash集
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 
-	peer Peer  // 被封装的 peer 实例
+	peer   Peer       // 被封装的 peer 实例
+	tagged taggedPeer // Request id capable view of the peer, nil if retrievals can't be multiplexed
 
 	// ETH协议版本号切换策略
 	version int        // Eth protocol version number to switch strategies
@@ -110,6 +98,31 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// taggedPeer is implemented by remote peers whose protocol tags requests and
+// replies with an identifier, allowing several retrievals of the same type to
+// be in flight concurrently.
+type taggedPeer interface {
+	RequestTaggedHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error
+	RequestTaggedBodies(id uint64, hashes []common.Hash) error
+	RequestTaggedReceipts(id uint64, hashes []common.Hash) error
+	RequestTaggedNodeData(id uint64, hashes []common.Hash) error
+}
+
+// requestIDs is the source of the identifiers tagging data retrievals. It is
+// shared by all peers so that in-flight requests can be tracked by id alone.
+var requestIDs uint64
+
+// nextRequestID returns a fresh, non-zero identifier for a data retrieval.
+func nextRequestID() uint64 {
+	return atomic.AddUint64(&requestIDs, 1)
+}
+
+// scoredPeer is implemented by remote peers which maintain a reputation score
+// at the networking layer, allowing the downloader to report timeouts.
+type scoredPeer interface {
+	AdjustScore(delta int64)
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -143,15 +156,39 @@ func (w *lightPeerWrapper) RequestNodeData([]common.Hash) error {
 
 // newPeerConnection creates a new downloader peer.
 func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *peerConnection {
-	return &peerConnection{
-		id:      id,
-		lacking: make(map[common.Hash]struct{}),
+	p := &peerConnection{
+		id:             id,
+		headerStarted:  make(map[uint64]time.Time),
+		blockStarted:   make(map[uint64]time.Time),
+		receiptStarted: make(map[uint64]time.Time),
+		stateStarted:   make(map[uint64]time.Time),
+		lacking:        make(map[common.Hash]struct{}),
 
 		peer: peer,
 
 		version: version,
 		log:     logger,
 	}
+	if tagged, ok := peer.(taggedPeer); ok && version >= 66 {
+		p.tagged = tagged
+	}
+	return p
+}
+
+// requestLimit returns the number of retrievals of a single type that may be in
+// flight to the peer at the same time.
+func (p *peerConnection) requestLimit() int {
+	if p.tagged != nil {
+		return maxTaggedRequests
+	}
+	return 1
+}
+
+// Penalize lowers the reputation score of the remote peer, if it keeps one.
+func (p *peerConnection) Penalize(delta int64) {
+	if peer, ok := p.peer.(scoredPeer); ok {
+		peer.AdjustScore(-delta)
+	}
 }
 
 // Reset clears the internal state of a peer entity.
@@ -159,10 +196,10 @@ func (p *peerConnection) Reset() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	atomic.StoreInt32(&p.headerIdle, 0)
-	atomic.StoreInt32(&p.blockIdle, 0)
-	atomic.StoreInt32(&p.receiptIdle, 0)
-	atomic.StoreInt32(&p.stateIdle, 0)
+	p.headerStarted = make(map[uint64]time.Time)
+	p.blockStarted = make(map[uint64]time.Time)
+	p.receiptStarted = make(map[uint64]time.Time)
+	p.stateStarted = make(map[uint64]time.Time)
 
 	p.headerThroughput = 0
 	p.blockThroughput = 0
@@ -178,20 +215,22 @@ func (p *peerConnection) Reset() {
 FetchHeaders:
 发送header 拉取 req到远程 peer
  */
-func (p *peerConnection) FetchHeaders(from uint64, count int) error {
+func (p *peerConnection) FetchHeaders(id uint64, from uint64, count int) error {
 	// Sanity check the protocol version
 	if p.version < 62 {
 		panic(fmt.Sprintf("header fetch [eth/62+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.headerIdle, 0, 1) {
+	if !p.reserve(id, p.headerStarted) {
 		return errAlreadyFetching
 	}
-	p.headerStarted = time.Now()
-
 	// Issue the header retrieval request (absolut upwards without gaps)
 	// todo 这里才是主体
-	go p.peer.RequestHeadersByNumber(from, count, 0, false)
+	if p.tagged != nil {
+		go p.tagged.RequestTaggedHeadersByNumber(id, from, count, 0, false)
+	} else {
+		go p.peer.RequestHeadersByNumber(from, count, 0, false)
+	}
 
 	return nil
 }
@@ -203,17 +242,20 @@ func (p *peerConnection) FetchBodies(request *fetchRequest) error {
 		panic(fmt.Sprintf("body fetch [eth/62+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.blockIdle, 0, 1) {
+	if !p.reserve(request.ID, p.blockStarted) {
 		return errAlreadyFetching
 	}
-	p.blockStarted = time.Now()
 
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.peer.RequestBodies(hashes)
+	if p.tagged != nil {
+		go p.tagged.RequestTaggedBodies(request.ID, hashes)
+	} else {
+		go p.peer.RequestBodies(hashes)
+	}
 
 	return nil
 }
@@ -225,17 +267,20 @@ func (p *peerConnection) FetchReceipts(request *fetchRequest) error {
 		panic(fmt.Sprintf("body fetch [eth/63+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	if !atomic.CompareAndSwapInt32(&p.receiptIdle, 0, 1) {
+	if !p.reserve(request.ID, p.receiptStarted) {
 		return errAlreadyFetching
 	}
-	p.receiptStarted = time.Now()
 
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
 	for _, header := range request.Headers {
 		hashes = append(hashes, header.Hash())
 	}
-	go p.peer.RequestReceipts(hashes)
+	if p.tagged != nil {
+		go p.tagged.RequestTaggedReceipts(request.ID, hashes)
+	} else {
+		go p.peer.RequestReceipts(hashes)
+	}
 
 	return nil
 }
@@ -246,83 +291,115 @@ func (p *peerConnection) FetchReceipts(request *fetchRequest) error {
 FetchNodeData:
 向远程peer发送节点 state trie node 数据拉取请求
  */
-func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
+func (p *peerConnection) FetchNodeData(id uint64, hashes []common.Hash) error {
 	// Sanity check the protocol version
 	if p.version < 63 {
 		panic(fmt.Sprintf("node data fetch [eth/63+] requested on eth/%d", p.version))
 	}
 	// Short circuit if the peer is already fetching
-	// 修改state空闲标识位为  active: 1, 修改成功则成功,否则直接返回
-	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+	// 占用一个 state 拉取的请求槽位并记录开始拉取的时间, 槽位已满则直接返回
+	if !p.reserve(id, p.stateStarted) {
 		return errAlreadyFetching
 	}
 
-	// 记录 开始拉取 state 数据的时间
-	p.stateStarted = time.Now()
-
 	/** todo 真正启动拉取state 数据 */
 	// TODO 注意: 只有 fast 模式才会最终走到这里来
 	// TODO 查看  eth/downloader.syncWithPeer() 自明
-	go p.peer.RequestNodeData(hashes)
+	if p.tagged != nil {
+		go p.tagged.RequestTaggedNodeData(id, hashes)
+	} else {
+		go p.peer.RequestNodeData(hashes)
+	}
 
 	return nil
 }
 
-// SetHeadersIdle sets the peer to idle, allowing it to execute new header retrieval
-// requests. Its estimated header retrieval throughput is updated with that measured
-// just now.
+// SetHeadersIdle finishes the header retrieval with the given id, allowing the
+// peer to execute new header retrieval requests. Its estimated header retrieval
+// throughput is updated with that measured just now.
 //
 /**
 SetHeadersIdle:
 将peer设置为空闲，从而允许其执行新的header拉取req。 其估计的 header 拉取吞吐量已更新为刚刚测量的
  */
-func (p *peerConnection) SetHeadersIdle(delivered int) {
-	p.setIdle(p.headerStarted, delivered, &p.headerThroughput, &p.headerIdle)
+func (p *peerConnection) SetHeadersIdle(id uint64, delivered int) {
+	p.setIdle(id, p.headerStarted, delivered, &p.headerThroughput)
 }
 
-// SetBlocksIdle sets the peer to idle, allowing it to execute new block retrieval
-// requests. Its estimated block retrieval throughput is updated with that measured
-// just now.
-func (p *peerConnection) SetBlocksIdle(delivered int) {
-	p.setIdle(p.blockStarted, delivered, &p.blockThroughput, &p.blockIdle)
+// SetBlocksIdle finishes the block retrieval with the given id, allowing the
+// peer to execute new block retrieval requests. Its estimated block retrieval
+// throughput is updated with that measured just now.
+func (p *peerConnection) SetBlocksIdle(id uint64, delivered int) {
+	p.setIdle(id, p.blockStarted, delivered, &p.blockThroughput)
 }
 
-// SetBodiesIdle sets the peer to idle, allowing it to execute block body retrieval
-// requests. Its estimated body retrieval throughput is updated with that measured
-// just now.
-func (p *peerConnection) SetBodiesIdle(delivered int) {
-	p.setIdle(p.blockStarted, delivered, &p.blockThroughput, &p.blockIdle)
+// SetBodiesIdle finishes the block body retrieval with the given id, allowing
+// the peer to execute new body retrieval requests. Its estimated body retrieval
+// throughput is updated with that measured just now.
+func (p *peerConnection) SetBodiesIdle(id uint64, delivered int) {
+	p.setIdle(id, p.blockStarted, delivered, &p.blockThroughput)
 }
 
-// SetReceiptsIdle sets the peer to idle, allowing it to execute new receipt
-// retrieval requests. Its estimated receipt retrieval throughput is updated
-// with that measured just now.
-func (p *peerConnection) SetReceiptsIdle(delivered int) {
-	p.setIdle(p.receiptStarted, delivered, &p.receiptThroughput, &p.receiptIdle)
+// SetReceiptsIdle finishes the receipt retrieval with the given id, allowing
+// the peer to execute new receipt retrieval requests. Its estimated receipt
+// retrieval throughput is updated with that measured just now.
+func (p *peerConnection) SetReceiptsIdle(id uint64, delivered int) {
+	p.setIdle(id, p.receiptStarted, delivered, &p.receiptThroughput)
 }
 
-// SetNodeDataIdle sets the peer to idle, allowing it to execute new state trie
-// data retrieval requests. Its estimated state retrieval throughput is updated
-// with that measured just now.
+// SetNodeDataIdle finishes the state trie data retrieval with the given id,
+// allowing the peer to execute new state retrieval requests. Its estimated state
+// retrieval throughput is updated with that measured just now.
 //
 /**
 SetNodeDataIdle
 将 peer 设置为空闲，从而允许其执行新的state Trie数据 拉取请求。
 其估计的 state 拉取吞吐量已更新为现在测量的状态。
  */
-func (p *peerConnection) SetNodeDataIdle(delivered int) {
-	p.setIdle(p.stateStarted, delivered, &p.stateThroughput, &p.stateIdle)
+func (p *peerConnection) SetNodeDataIdle(id uint64, delivered int) {
+	p.setIdle(id, p.stateStarted, delivered, &p.stateThroughput)
 }
 
-// setIdle sets the peer to idle, allowing it to execute new retrieval requests.
-// Its estimated retrieval throughput is updated with that measured just now.
-func (p *peerConnection) setIdle(started time.Time, delivered int, throughput *float64, idle *int32) {
-	// Irrelevant of the scaling, make sure the peer ends up idle
-	defer atomic.StoreInt32(idle, 0)
+// reserve marks a retrieval as in flight to the peer, returning false if the
+// peer already has as many requests of that type pending as it may.
+func (p *peerConnection) reserve(id uint64, inflight map[uint64]time.Time) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := inflight[id]; ok || len(inflight) >= p.requestLimit() {
+		return false
+	}
+	inflight[id] = time.Now()
+	return true
+}
 
+// idle reports whether the peer can accept another retrieval of a type.
+func (p *peerConnection) idle(inflight map[uint64]time.Time) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return len(inflight) < p.requestLimit()
+}
+
+// setIdle marks a retrieval of the peer finished, allowing it to execute new
+// requests. Its estimated retrieval throughput is updated with that measured
+// just now. Untagged replies of peers unable to multiplex requests finish their
+// only pending retrieval.
+func (p *peerConnection) setIdle(id uint64, inflight map[uint64]time.Time, delivered int, throughput *float64) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if id == 0 && p.tagged == nil {
+		for pending := range inflight {
+			id = pending
+		}
+	}
+	started, ok := inflight[id]
+	if !ok {
+		return
+	}
+	delete(inflight, id)
+
 	// If nothing was delivered (hard timeout / unavailable data), reduce throughput to minimum
 	if delivered == 0 {
 		*throughput = 0
@@ -540,42 +617,42 @@ func (ps *peerSet) AllPeers() []*peerConnection {
 // within the active peer set, ordered by their reputation.
 func (ps *peerSet) HeaderIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
-		return atomic.LoadInt32(&p.headerIdle) == 0
+		return p.idle(p.headerStarted)
 	}
 	throughput := func(p *peerConnection) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.headerThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 66, idle, throughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
 // the active peer set, ordered by their reputation.
 func (ps *peerSet) BodyIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
-		return atomic.LoadInt32(&p.blockIdle) == 0
+		return p.idle(p.blockStarted)
 	}
 	throughput := func(p *peerConnection) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.blockThroughput
 	}
-	return ps.idlePeers(62, 64, idle, throughput)
+	return ps.idlePeers(62, 66, idle, throughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
 // within the active peer set, ordered by their reputation.
 func (ps *peerSet) ReceiptIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
-		return atomic.LoadInt32(&p.receiptIdle) == 0
+		return p.idle(p.receiptStarted)
 	}
 	throughput := func(p *peerConnection) float64 {
 		p.lock.RLock()
 		defer p.lock.RUnlock()
 		return p.receiptThroughput
 	}
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 66, idle, throughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
@@ -587,7 +664,7 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 
 	// 回调函数, 返回节点是否具备 state 空闲
 	idle := func(p *peerConnection) bool {
-		return atomic.LoadInt32(&p.stateIdle) == 0
+		return p.idle(p.stateStarted)
 	}
 
 	//
@@ -598,7 +675,7 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 	}
 
 	// 获取空闲的 peers
-	return ps.idlePeers(63, 64, idle, throughput)
+	return ps.idlePeers(63, 66, idle, throughput)
 }
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
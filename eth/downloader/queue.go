@@ -46,6 +46,7 @@ var (
 
 // fetchRequest is a currently running data retrieval operation.
 type fetchRequest struct {
+	ID      uint64          // Identifier tagging the request and its reply
 	Peer    *peerConnection // Peer to which the request was sent
 	From    uint64          // [eth/62] Requested chain element index (used for skeleton fills only)
 	Headers []*types.Header // [eth/62] Requested headers, sorted by request order
@@ -100,7 +101,7 @@ type queue struct {
 	headerTaskQueue *prque.Prque                   // [eth/62] Priority queue of the skeleton indexes to fetch the filling headers for
 	// 每个peer 的header batch的已知不可用的集合
 	headerPeerMiss  map[string]map[uint64]struct{} // [eth/62] Set of per-peer header batches known to be unavailable
-	headerPendPool  map[string]*fetchRequest       // [eth/62] Currently pending header retrieval operations
+	headerPendPool  map[uint64]*fetchRequest       // [eth/62] Currently pending header retrieval operations, keyed by request id
 	// 结果缓存累积完成的 header (这里的已完成指的是 同步拉取回来的headers,  不是指 已经处理完成的)
 	headerResults   []*types.Header                // [eth/62] Result cache accumulating the completed headers
 	// 已被处理的 header 数量
@@ -123,7 +124,7 @@ type queue struct {
 	// headers的优先级队列，以获取对应的 blocks（bodies）
 	blockTaskQueue *prque.Prque                  // [eth/62] Priority queue of the headers to fetch the blocks (bodies) for
 	// 当前待处理的 block（body）的拉取操作req实例
-	blockPendPool  map[string]*fetchRequest      // [eth/62] Currently pending block (body) retrieval operations
+	blockPendPool  map[uint64]*fetchRequest      // [eth/62] Currently pending block (body) retrieval operations, keyed by request id
 	// 一组已完成抓取的block（body）的hash (去重用)
 	blockDonePool  map[common.Hash]struct{}      // [eth/62] Set of the completed block (body) fetches
 
@@ -132,7 +133,7 @@ type queue struct {
 	// headers的优先级队列，以获取其receipts
 	receiptTaskQueue *prque.Prque                  // [eth/63] Priority queue of the headers to fetch the receipts for
 	// 当前待处理的receipt拉取req
-	receiptPendPool  map[string]*fetchRequest      // [eth/63] Currently pending receipt retrieval operations
+	receiptPendPool  map[uint64]*fetchRequest      // [eth/63] Currently pending receipt retrieval operations, keyed by request id
 	// 一组已完成抓取的 receipt的hash
 	receiptDonePool  map[common.Hash]struct{}      // [eth/63] Set of the completed receipt fetches
 
@@ -154,15 +155,15 @@ type queue struct {
 func newQueue() *queue {
 	lock := new(sync.Mutex)
 	return &queue{
-		headerPendPool:   make(map[string]*fetchRequest),
+		headerPendPool:   make(map[uint64]*fetchRequest),
 		headerContCh:     make(chan bool),
 		blockTaskPool:    make(map[common.Hash]*types.Header),
 		blockTaskQueue:   prque.New(),
-		blockPendPool:    make(map[string]*fetchRequest),
+		blockPendPool:    make(map[uint64]*fetchRequest),
 		blockDonePool:    make(map[common.Hash]struct{}),
 		receiptTaskPool:  make(map[common.Hash]*types.Header),
 		receiptTaskQueue: prque.New(),
-		receiptPendPool:  make(map[string]*fetchRequest),
+		receiptPendPool:  make(map[uint64]*fetchRequest),
 		receiptDonePool:  make(map[common.Hash]struct{}),
 		resultCache:      make([]*fetchResult, blockCacheItems),
 		active:           sync.NewCond(lock),
@@ -179,16 +180,16 @@ func (q *queue) Reset() {
 	q.mode = FullSync
 
 	q.headerHead = common.Hash{}
-	q.headerPendPool = make(map[string]*fetchRequest)
+	q.headerPendPool = make(map[uint64]*fetchRequest)
 
 	q.blockTaskPool = make(map[common.Hash]*types.Header)
 	q.blockTaskQueue.Reset()
-	q.blockPendPool = make(map[string]*fetchRequest)
+	q.blockPendPool = make(map[uint64]*fetchRequest)
 	q.blockDonePool = make(map[common.Hash]struct{})
 
 	q.receiptTaskPool = make(map[common.Hash]*types.Header)
 	q.receiptTaskQueue.Reset()
-	q.receiptPendPool = make(map[string]*fetchRequest)
+	q.receiptPendPool = make(map[uint64]*fetchRequest)
 	q.receiptDonePool = make(map[common.Hash]struct{})
 
 	q.resultCache = make([]*fetchResult, blockCacheItems)
@@ -292,7 +293,7 @@ func (q *queue) ShouldThrottleReceipts() bool {
 // resultSlots calculates the number of results slots available for requests
 // whilst adhering to both the item and the memory limit too of the results
 // cache.
-func (q *queue) resultSlots(pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}) int {
+func (q *queue) resultSlots(pendPool map[uint64]*fetchRequest, donePool map[common.Hash]struct{}) int {
 	// Calculate the maximum length capped by the memory limit
 	limit := len(q.resultCache)
 	if common.StorageSize(len(q.resultCache))*q.resultSize > common.StorageSize(blockCacheMemory) {
@@ -539,9 +540,9 @@ func (q *queue) ReserveHeaders(p *peerConnection, count int) *fetchRequest {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	// Short circuit if the peer's already downloading something (sanity check to
-	// not corrupt state)
-	if _, ok := q.headerPendPool[p.id]; ok {
+	// Short circuit if the peer's already downloading as much as it may (sanity
+	// check to not corrupt state)
+	if pendingCount(q.headerPendPool, p.id) >= p.requestLimit() {
 		return nil
 	}
 	// Retrieve a batch of hashes, skipping previously failed ones
@@ -565,11 +566,12 @@ func (q *queue) ReserveHeaders(p *peerConnection, count int) *fetchRequest {
 		return nil
 	}
 	request := &fetchRequest{
+		ID:   nextRequestID(),
 		Peer: p,
 		From: send,
 		Time: time.Now(),
 	}
-	q.headerPendPool[p.id] = request
+	q.headerPendPool[request.ID] = request
 	return request
 }
 
@@ -607,13 +609,13 @@ func (q *queue) ReserveReceipts(p *peerConnection, count int) (*fetchRequest, bo
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
 func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
-	pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}, isNoop func(*types.Header) bool) (*fetchRequest, bool, error) {
+	pendPool map[uint64]*fetchRequest, donePool map[common.Hash]struct{}, isNoop func(*types.Header) bool) (*fetchRequest, bool, error) {
 	// Short circuit if the pool has been depleted, or if the peer's already
-	// downloading something (sanity check not to corrupt state)
+	// downloading as much as it may (sanity check not to corrupt state)
 	if taskQueue.Empty() {
 		return nil, false, nil
 	}
-	if _, ok := pendPool[p.id]; ok {
+	if pendingCount(pendPool, p.id) >= p.requestLimit() {
 		return nil, false, nil
 	}
 	// Calculate an upper limit on the items we might fetch (i.e. throttling)
@@ -675,11 +677,12 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 		return nil, progress, nil
 	}
 	request := &fetchRequest{
+		ID:      nextRequestID(),
 		Peer:    p,
 		Headers: send,
 		Time:    time.Now(),
 	}
-	pendPool[p.id] = request
+	pendPool[request.ID] = request
 
 	return request, progress, nil
 }
@@ -702,7 +705,7 @@ func (q *queue) CancelReceipts(request *fetchRequest) {
 }
 
 // Cancel aborts a fetch request, returning all pending hashes to the task queue.
-func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool map[string]*fetchRequest) {
+func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool map[uint64]*fetchRequest) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -712,7 +715,7 @@ func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool m
 	for _, header := range request.Headers {
 		taskQueue.Push(header, -float32(header.Number.Uint64()))
 	}
-	delete(pendPool, request.Peer.id)
+	delete(pendPool, request.ID)
 }
 
 // Revoke cancels all pending requests belonging to a given peer. This method is
@@ -722,23 +725,59 @@ func (q *queue) Revoke(peerID string) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
-	if request, ok := q.blockPendPool[peerID]; ok {
+	for id, request := range q.blockPendPool {
+		if request.Peer.id != peerID {
+			continue
+		}
 		for _, header := range request.Headers {
 			q.blockTaskQueue.Push(header, -float32(header.Number.Uint64()))
 		}
-		delete(q.blockPendPool, peerID)
+		delete(q.blockPendPool, id)
 	}
-	if request, ok := q.receiptPendPool[peerID]; ok {
+	for id, request := range q.receiptPendPool {
+		if request.Peer.id != peerID {
+			continue
+		}
 		for _, header := range request.Headers {
 			q.receiptTaskQueue.Push(header, -float32(header.Number.Uint64()))
 		}
-		delete(q.receiptPendPool, peerID)
+		delete(q.receiptPendPool, id)
+	}
+}
+
+// pendingCount returns the number of requests in a pending pool belonging to a
+// given peer.
+func pendingCount(pendPool map[uint64]*fetchRequest, peerID string) int {
+	count := 0
+	for _, request := range pendPool {
+		if request.Peer.id == peerID {
+			count++
+		}
 	}
+	return count
+}
+
+// pendingRequest retrieves the request from a pending pool that a delivery of
+// the given peer answers. Untagged deliveries match the only request of peers
+// unable to multiplex retrievals.
+func pendingRequest(pendPool map[uint64]*fetchRequest, peerID string, reqID uint64) *fetchRequest {
+	if reqID != 0 {
+		if request := pendPool[reqID]; request != nil && request.Peer.id == peerID {
+			return request
+		}
+		return nil
+	}
+	for _, request := range pendPool {
+		if request.Peer.id == peerID && request.Peer.tagged == nil {
+			return request
+		}
+	}
+	return nil
 }
 
 // ExpireHeaders checks for in flight requests that exceeded a timeout allowance,
-// canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireHeaders(timeout time.Duration) map[string]int {
+// canceling them and returning them for penalising the responsible peers.
+func (q *queue) ExpireHeaders(timeout time.Duration) []*fetchRequest {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -746,8 +785,9 @@ func (q *queue) ExpireHeaders(timeout time.Duration) map[string]int {
 }
 
 // ExpireBodies checks for in flight block body requests that exceeded a timeout
-// allowance, canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireBodies(timeout time.Duration) map[string]int {
+// allowance, canceling them and returning them for penalising the responsible
+// peers.
+func (q *queue) ExpireBodies(timeout time.Duration) []*fetchRequest {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -755,8 +795,9 @@ func (q *queue) ExpireBodies(timeout time.Duration) map[string]int {
 }
 
 // ExpireReceipts checks for in flight receipt requests that exceeded a timeout
-// allowance, canceling them and returning the responsible peers for penalisation.
-func (q *queue) ExpireReceipts(timeout time.Duration) map[string]int {
+// allowance, canceling them and returning them for penalising the responsible
+// peers.
+func (q *queue) ExpireReceipts(timeout time.Duration) []*fetchRequest {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -764,14 +805,14 @@ func (q *queue) ExpireReceipts(timeout time.Duration) map[string]int {
 }
 
 // expire is the generic check that move expired tasks from a pending pool back
-// into a task pool, returning all entities caught with expired tasks.
+// into a task pool, returning all the requests caught expired.
 //
 // Note, this method expects the queue lock to be already held. The
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
-func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest, taskQueue *prque.Prque, timeoutMeter metrics.Meter) map[string]int {
+func (q *queue) expire(timeout time.Duration, pendPool map[uint64]*fetchRequest, taskQueue *prque.Prque, timeoutMeter metrics.Meter) []*fetchRequest {
 	// Iterate over the expired requests and return each to the queue
-	expiries := make(map[string]int)
+	var expiries []*fetchRequest
 	for id, request := range pendPool {
 		if time.Since(request.Time) > timeout {
 			// Update the metrics with the timeout
@@ -784,14 +825,11 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 			for _, header := range request.Headers {
 				taskQueue.Push(header, -float32(header.Number.Uint64()))
 			}
-			// Add the peer to the expiry report along the the number of failed requests
-			expiries[id] = len(request.Headers)
+			// Add the request to the expiry report and remove it from the pending pool
+			expiries = append(expiries, request)
+			delete(pendPool, id)
 		}
 	}
-	// Remove the expired requests from the pending pool
-	for id := range expiries {
-		delete(pendPool, id)
-	}
 	return expiries
 }
 
@@ -802,17 +840,17 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 // If the headers are accepted, the method makes an attempt to deliver the set
 // of ready headers to the processor to keep the pipeline full. However it will
 // not block to prevent stalling other pending deliveries.
-func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
+func (q *queue) DeliverHeaders(id string, reqID uint64, headers []*types.Header, headerProcCh chan []*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
 	// Short circuit if the data was never requested
-	request := q.headerPendPool[id]
+	request := pendingRequest(q.headerPendPool, id, reqID)
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
 	headerReqTimer.UpdateSince(request.Time)
-	delete(q.headerPendPool, id)
+	delete(q.headerPendPool, request.ID)
 
 	// Ensure headers can be mapped onto the skeleton chain
 	target := q.headerTaskPool[request.From].Hash()
@@ -886,7 +924,7 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 // DeliverBodies injects a block body retrieval response into the results queue.
 // The method returns the number of blocks bodies accepted from the delivery and
 // also wakes any threads waiting for data delivery.
-func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
+func (q *queue) DeliverBodies(id string, reqID uint64, txLists [][]*types.Transaction, uncleLists [][]*types.Header) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -898,13 +936,13 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLi
 		result.Uncles = uncleLists[index]
 		return nil
 	}
-	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
+	return q.deliver(id, reqID, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
 }
 
 // DeliverReceipts injects a receipt retrieval response into the results queue.
 // The method returns the number of transaction receipts accepted from the delivery
 // and also wakes any threads waiting for data delivery.
-func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int, error) {
+func (q *queue) DeliverReceipts(id string, reqID uint64, receiptList [][]*types.Receipt) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -915,7 +953,7 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 		result.Receipts = receiptList[index]
 		return nil
 	}
-	return q.deliver(id, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
+	return q.deliver(id, reqID, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
 }
 
 // deliver injects a data retrieval response into the results queue.
@@ -923,17 +961,17 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 // Note, this method expects the queue lock to be already held for writing. The
 // reason the lock is not obtained in here is because the parameters already need
 // to access the queue, so they already need a lock anyway.
-func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
-	pendPool map[string]*fetchRequest, donePool map[common.Hash]struct{}, reqTimer metrics.Timer,
+func (q *queue) deliver(id string, reqID uint64, taskPool map[common.Hash]*types.Header, taskQueue *prque.Prque,
+	pendPool map[uint64]*fetchRequest, donePool map[common.Hash]struct{}, reqTimer metrics.Timer,
 	results int, reconstruct func(header *types.Header, index int, result *fetchResult) error) (int, error) {
 
 	// Short circuit if the data was never requested
-	request := pendPool[id]
+	request := pendingRequest(pendPool, id, reqID)
 	if request == nil {
 		return 0, errNoFetchesPending
 	}
 	reqTimer.UpdateSince(request.Time)
-	delete(pendPool, id)
+	delete(pendPool, request.ID)
 
 	// If no data items were retrieved, mark them as unavailable for the origin peer
 	if results == 0 {
@@ -39,6 +39,7 @@ todo 超级重要的一个req
 stateReq 代表一批 state 获取请求，这些请求被组合到一个数据检索网络数据包中。
  */
 type stateReq struct {
+	id uint64 // Identifier tagging the request and its reply
 
 	// 准备去 同步的 state 的 item 的hash
 	items    []common.Hash              // Hashes of the state items to download
@@ -123,7 +124,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 	var (
 		// 记录当前进行中的请求
 		// todo 这个相当有用,用来记录是否活跃
-		active   = make(map[string]*stateReq) // Currently in-flight requests
+		active   = make(map[uint64]*stateReq) // Currently in-flight requests, keyed by request id
 		// 完成或失败的请求
 		finished []*stateReq                  // Completed or failed requests
 		// 活动请求超时
@@ -139,7 +140,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 		for _, req := range active {
 			req.timer.Stop()
 			// 将对应req中的 peer 设置为空闲
-			req.peer.SetNodeDataIdle(len(req.items))
+			req.peer.SetNodeDataIdle(req.id, len(req.items))
 		}
 	}()
 	// Run the state sync.
@@ -180,7 +181,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 		// Handle incoming state packs:
 		case pack := <-d.stateCh:
 			// Discard any data not requested (or previously timed out)
-			req := active[pack.PeerId()]
+			req := activeStateReq(active, pack.PeerId(), pack.RequestId())
 			if req == nil {
 				log.Debug("Unrequested node data", "peer", pack.PeerId(), "len", pack.Items())
 				continue
@@ -190,21 +191,21 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			req.response = pack.(*statePack).states
 
 			finished = append(finished, req)
-			delete(active, pack.PeerId())
+			delete(active, req.id)
 
 			// Handle dropped peer connections:
 		case p := <-peerDrop:
-			// Skip if no request is currently pending
-			req := active[p.id]
-			if req == nil {
-				continue
-			}
-			// Finalize the request and queue up for processing
-			req.timer.Stop()
-			req.dropped = true
+			// Finalize all the requests pending on the peer and queue up for processing
+			for id, req := range active {
+				if req.peer.id != p.id {
+					continue
+				}
+				req.timer.Stop()
+				req.dropped = true
 
-			finished = append(finished, req)
-			delete(active, p.id)
+				finished = append(finished, req)
+				delete(active, id)
+			}
 
 		// Handle timed-out requests:
 		//
@@ -213,12 +214,12 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			// If the peer is already requesting something else, ignore the stale timeout.
 			// This can happen when the timeout and the delivery happens simultaneously,
 			// causing both pathways to trigger.
-			if active[req.peer.id] != req {
+			if active[req.id] != req {
 				continue
 			}
 			// Move the timed out data back into the download queue
 			finished = append(finished, req)
-			delete(active, req.peer.id)
+			delete(active, req.id)
 
 		// Track outgoing state requests:
 		//
@@ -238,7 +239,10 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			在这种情况下，永远不会满足第一个请求，因为我们决不能无声地覆盖它，
 			因为这会导致有效请求丢失并导致同步卡住
 			 */
-			if old := active[req.peer.id]; old != nil { // assigned: 分配
+			//
+			// Peers tagging their replies with request ids are exempt, as their replies
+			// can always be told apart.
+			if old := activeStateReq(active, req.peer.id, 0); old != nil { // assigned: 分配
 				log.Warn("Busy peer assigned new state fetch", "peer", old.peer.id)
 
 				// Make sure the previous one doesn't get siletly lost
@@ -248,6 +252,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 				old.dropped = true // 标识为 移除状态
 
 				finished = append(finished, old)
+				delete(active, old.id)
 			}
 			// Start a timer to notify the sync loop if the peer stalled.
 			//
@@ -268,11 +273,29 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			})
 
 			// 在 active (活跃中 )集中记录当前req
-			active[req.peer.id] = req
+			active[req.id] = req
 		}
 	}
 }
 
+// activeStateReq retrieves the in-flight state request that a delivery of the
+// given peer answers. Untagged deliveries match the only request of peers unable
+// to multiplex retrievals.
+func activeStateReq(active map[uint64]*stateReq, peerID string, reqID uint64) *stateReq {
+	if reqID != 0 {
+		if req := active[reqID]; req != nil && req.peer.id == peerID {
+			return req
+		}
+		return nil
+	}
+	for _, req := range active {
+		if req.peer.id == peerID && req.peer.tagged == nil {
+			return req
+		}
+	}
+	return nil
+}
+
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 //
@@ -434,7 +457,7 @@ func (s *stateSync) loop() (err error) {
 				log.Warn("Node data write error", "err", err)
 				return err
 			}
-			req.peer.SetNodeDataIdle(len(req.response))
+			req.peer.SetNodeDataIdle(req.id, len(req.response))
 		}
 	}
 	return nil
@@ -486,7 +509,7 @@ func (s *stateSync) assignTasks() {
 		// 分配与估计的延迟/带宽成比例的一批提取 (batch)
 		cap := p.NodeDataCapacity(s.d.requestRTT())
 		// 组装 req 实例
-		req := &stateReq{peer: p, timeout: s.d.requestTTL()}
+		req := &stateReq{id: nextRequestID(), peer: p, timeout: s.d.requestTTL()}
 
 		/** todo 给该req填充一些拉取 state trie node 数据的 task*/
 		s.fillTasks(cap, req)
@@ -501,7 +524,7 @@ func (s *stateSync) assignTasks() {
 			case s.d.trackStateReq <- req:
 
 				/** todo  去对端peer 请求 state trie node 的数据 */
-				req.peer.FetchNodeData(req.items)
+				req.peer.FetchNodeData(req.id, req.items)
 			case <-s.cancel:
 			case <-s.d.cancelCh:
 			}
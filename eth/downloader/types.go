@@ -28,6 +28,7 @@ type peerDropFn func(id string)
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() string
+	RequestId() uint64
 	Items() int
 	Stats() string
 }
@@ -35,21 +36,25 @@ type dataPack interface {
 // headerPack is a batch of block headers returned by a peer.
 type headerPack struct {
 	peerID  string
+	reqID   uint64
 	headers []*types.Header
 }
 
-func (p *headerPack) PeerId() string { return p.peerID }
-func (p *headerPack) Items() int     { return len(p.headers) }
-func (p *headerPack) Stats() string  { return fmt.Sprintf("%d", len(p.headers)) }
+func (p *headerPack) PeerId() string    { return p.peerID }
+func (p *headerPack) RequestId() uint64 { return p.reqID }
+func (p *headerPack) Items() int        { return len(p.headers) }
+func (p *headerPack) Stats() string     { return fmt.Sprintf("%d", len(p.headers)) }
 
 // bodyPack is a batch of block bodies returned by a peer.
 type bodyPack struct {
 	peerID       string
+	reqID        uint64
 	transactions [][]*types.Transaction
 	uncles       [][]*types.Header
 }
 
-func (p *bodyPack) PeerId() string { return p.peerID }
+func (p *bodyPack) PeerId() string    { return p.peerID }
+func (p *bodyPack) RequestId() uint64 { return p.reqID }
 func (p *bodyPack) Items() int {
 	if len(p.transactions) <= len(p.uncles) {
 		return len(p.transactions)
@@ -61,19 +66,23 @@ func (p *bodyPack) Stats() string { return fmt.Sprintf("%d:%d", len(p.transactio
 // receiptPack is a batch of receipts returned by a peer.
 type receiptPack struct {
 	peerID   string
+	reqID    uint64
 	receipts [][]*types.Receipt
 }
 
-func (p *receiptPack) PeerId() string { return p.peerID }
-func (p *receiptPack) Items() int     { return len(p.receipts) }
-func (p *receiptPack) Stats() string  { return fmt.Sprintf("%d", len(p.receipts)) }
+func (p *receiptPack) PeerId() string    { return p.peerID }
+func (p *receiptPack) RequestId() uint64 { return p.reqID }
+func (p *receiptPack) Items() int        { return len(p.receipts) }
+func (p *receiptPack) Stats() string     { return fmt.Sprintf("%d", len(p.receipts)) }
 
 // statePack is a batch of states returned by a peer.
 type statePack struct {
 	peerID string
+	reqID  uint64
 	states [][]byte
 }
 
-func (p *statePack) PeerId() string { return p.peerID }
-func (p *statePack) Items() int     { return len(p.states) }
-func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+func (p *statePack) PeerId() string    { return p.peerID }
+func (p *statePack) RequestId() uint64 { return p.reqID }
+func (p *statePack) Items() int        { return len(p.states) }
+func (p *statePack) Stats() string     { return fmt.Sprintf("%d", len(p.states)) }
@@ -40,4 +40,15 @@ var (
 	headerFilterOutMeter = metrics.NewRegisteredMeter("eth/fetcher/filter/headers/out", nil)
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/in", nil)
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/out", nil)
+
+	txAnnounceInMeter    = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/in", nil)
+	txAnnounceKnownMeter = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/known", nil)
+	txAnnounceDOSMeter   = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/dos", nil)
+
+	txBroadcastInMeter = metrics.NewRegisteredMeter("eth/fetcher/tx/broadcasts/in", nil)
+	txReplyInMeter     = metrics.NewRegisteredMeter("eth/fetcher/tx/replies/in", nil)
+	txReplyDropMeter   = metrics.NewRegisteredMeter("eth/fetcher/tx/replies/drop", nil)
+
+	txRequestOutMeter     = metrics.NewRegisteredMeter("eth/fetcher/tx/request/out", nil)
+	txRequestTimeoutMeter = metrics.NewRegisteredMeter("eth/fetcher/tx/request/timeout", nil)
 )
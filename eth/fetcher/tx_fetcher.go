@@ -0,0 +1,504 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txGatherSlack   = 100 * time.Millisecond // Interval used to collate almost-expired announces with fetches
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	maxTxAnnounces  = 4096                   // Maximum number of unique transactions a peer may have announced
+	maxTxRetrievals = 256                    // Maximum number of transactions to retrieve in one request
+)
+
+// ErrUnrequestedTx is returned for a transaction delivered as a reply to a
+// retrieval request it was not part of.
+var ErrUnrequestedTx = errors.New("unrequested transaction")
+
+// txCheckerFn is a callback type for checking whether a transaction is already
+// known locally.
+type txCheckerFn func(common.Hash) bool
+
+// txAdderFn is a callback type for injecting a batch of transactions into the
+// local pool.
+type txAdderFn func([]*types.Transaction) []error
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the notification of the availability of a batch of new
+// transactions in the network.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes being announced
+}
+
+// txDelivery is the notification that a batch of transactions have been added
+// to the pool and should be untracked.
+type txDelivery struct {
+	origin string        // Identifier of the peer originating the transactions
+	hashes []common.Hash // Batch of transaction hashes having been delivered
+	direct bool          // Whether this is a direct reply or a broadcast
+}
+
+// txFilterTask represents a batch of transactions delivered as a reply, needing
+// to be checked against the request sent to the peer.
+type txFilterTask struct {
+	peer      string               // The source peer of the transactions
+	txs       []*types.Transaction // Collection of transactions to filter
+	requested []bool               // Whether each transaction was requested
+}
+
+// txRequest represents an in-flight transaction retrieval request.
+type txRequest struct {
+	hashes []common.Hash // Transactions having been requested
+	time   time.Time     // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on hash
+// announcements. A transaction announced by several peers is only retrieved
+// from one of them at a time, the others being kept as alternates in case
+// the request times out or the peer disconnects.
+type TxFetcher struct {
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	filter  chan chan *txFilterTask
+	drop    chan string
+	quit    chan struct{}
+
+	// Announce states
+	announces map[string]map[common.Hash]struct{} // Set of announced transactions, grouped by origin peer
+	announced map[common.Hash]map[string]struct{} // Set of peers having announced a transaction
+	waiting   map[common.Hash]time.Time           // Transactions waiting for a broadcast to arrive
+	queued    map[common.Hash]struct{}            // Transactions scheduled for retrieval
+	fetching  map[common.Hash]string              // Transactions currently being retrieved, and from whom
+	requests  map[string]*txRequest               // In-flight transaction retrievals, grouped by peer
+
+	// Callbacks
+	hasTx    txCheckerFn   // Checks whether a transaction is already known
+	addTxs   txAdderFn     // Injects a batch of transactions into the pool
+	fetchTxs txRequesterFn // Requests a batch of transactions from a peer
+
+	// Testing hooks
+	fetchingHook func(string, []common.Hash) // Method to call upon starting a transaction retrieval
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx txCheckerFn, addTxs txAdderFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		filter:    make(chan chan *txFilterTask),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		waiting:   make(map[common.Hash]time.Time),
+		queued:    make(map[common.Hash]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+	}
+}
+
+// Start boots up the announcement based transaction retriever.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retriever, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions in the network.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	// Skip any transaction announcements already known locally
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if !f.hasTx(hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+	txAnnounceKnownMeter.Mark(int64(len(hashes) - len(unknown)))
+
+	if len(unknown) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknown}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue injects a batch of transactions received from a peer into the pool,
+// either as a reply to a retrieval request (direct) or as a broadcast. The pool
+// errors for the individual transactions are returned. Transactions in a reply
+// that were not requested from the peer are dropped with ErrUnrequestedTx.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) []error {
+	errs := make([]error, len(txs))
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+
+		requested := f.filterReply(peer, txs)
+		if requested == nil {
+			for i := range errs {
+				errs[i] = errTerminated
+			}
+			return errs
+		}
+		var (
+			added []*types.Transaction
+			index []int
+		)
+		for i, tx := range txs {
+			if !requested[i] {
+				errs[i] = ErrUnrequestedTx
+				continue
+			}
+			added = append(added, tx)
+			index = append(index, i)
+		}
+		txReplyDropMeter.Mark(int64(len(txs) - len(added)))
+
+		// A reply made up entirely of unrequested transactions says nothing about
+		// the request actually in flight, leave that one be
+		if len(added) == 0 && len(txs) > 0 {
+			return errs
+		}
+		for i, err := range f.addTxs(added) {
+			errs[index[i]] = err
+		}
+		txs = added
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+		copy(errs, f.addTxs(txs))
+	}
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+	case <-f.quit:
+	}
+	return errs
+}
+
+// filterReply checks which transactions of a reply were requested from the peer,
+// returning nil if the fetcher was terminated.
+func (f *TxFetcher) filterReply(peer string, txs []*types.Transaction) []bool {
+	// Send the filter channel to the fetcher
+	filter := make(chan *txFilterTask)
+
+	select {
+	case f.filter <- filter:
+	case <-f.quit:
+		return nil
+	}
+	// Request the filtering of the transaction list
+	select {
+	case filter <- &txFilterTask{peer: peer, txs: txs}:
+	case <-f.quit:
+		return nil
+	}
+	// Retrieve the outcome of the filtering
+	select {
+	case task := <-filter:
+		return task.requested
+	case <-f.quit:
+		return nil
+	}
+}
+
+// Drop should be called when a peer disconnects. It cleans up all the internal
+// data structures of the given node.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, checking and processing various notification
+// events.
+func (f *TxFetcher) loop() {
+	waitTimer := time.NewTimer(0)
+	<-waitTimer.C
+	timeoutTimer := time.NewTimer(0)
+	<-timeoutTimer.C
+
+	for {
+		select {
+		case <-f.quit:
+			waitTimer.Stop()
+			timeoutTimer.Stop()
+			return
+
+		case ann := <-f.notify:
+			// Transactions were announced, make sure the peer isn't DOSing us
+			known := f.announces[ann.origin]
+			if known == nil {
+				known = make(map[common.Hash]struct{})
+				f.announces[ann.origin] = known
+			}
+			idle := len(f.waiting) == 0
+			for _, hash := range ann.hashes {
+				if len(known) >= maxTxAnnounces {
+					log.Debug("Peer exceeded outstanding tx announces", "peer", ann.origin, "limit", maxTxAnnounces)
+					txAnnounceDOSMeter.Mark(1)
+					break
+				}
+				known[hash] = struct{}{}
+				if f.announced[hash] == nil {
+					f.announced[hash] = make(map[string]struct{})
+				}
+				f.announced[hash][ann.origin] = struct{}{}
+
+				// If the transaction is new, give broadcasts a chance to deliver it
+				if _, ok := f.waiting[hash]; ok {
+					continue
+				}
+				if _, ok := f.queued[hash]; ok {
+					continue
+				}
+				if _, ok := f.fetching[hash]; ok {
+					continue
+				}
+				f.waiting[hash] = time.Now()
+			}
+			if idle && len(f.waiting) > 0 {
+				f.rescheduleWait(waitTimer)
+			}
+			// The announcer might be able to serve already queued transactions
+			f.scheduleFetches(timeoutTimer)
+
+		case <-waitTimer.C:
+			// At least one transaction's wait ran out, queue it for retrieval
+			for hash, start := range f.waiting {
+				if time.Since(start) > txArriveTimeout-txGatherSlack {
+					delete(f.waiting, hash)
+					f.queued[hash] = struct{}{}
+				}
+			}
+			f.scheduleFetches(timeoutTimer)
+			f.rescheduleWait(waitTimer)
+
+		case <-timeoutTimer.C:
+			// At least one request timed out, reschedule its transactions to other peers
+			for peer, req := range f.requests {
+				if time.Since(req.time) < txFetchTimeout {
+					continue
+				}
+				txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+				for _, hash := range req.hashes {
+					if f.fetching[hash] != peer {
+						continue
+					}
+					delete(f.fetching, hash)
+					f.forgetAnnounce(peer, hash)
+				}
+				delete(f.requests, peer)
+			}
+			f.scheduleFetches(timeoutTimer)
+			f.rescheduleTimeout(timeoutTimer)
+
+		case delivery := <-f.cleanup:
+			// Transactions were imported, stop tracking them
+			for _, hash := range delivery.hashes {
+				f.forgetHash(hash)
+			}
+			// If it was a reply, anything missing is not available from the peer
+			if req := f.requests[delivery.origin]; delivery.direct && req != nil {
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == delivery.origin {
+						delete(f.fetching, hash)
+						f.forgetAnnounce(delivery.origin, hash)
+					}
+				}
+				delete(f.requests, delivery.origin)
+			}
+			f.scheduleFetches(timeoutTimer)
+
+		case filter := <-f.filter:
+			// Transactions arrived in a reply, check which of them were requested
+			var task *txFilterTask
+			select {
+			case task = <-filter:
+			case <-f.quit:
+				return
+			}
+			pending := make(map[common.Hash]struct{})
+			if req := f.requests[task.peer]; req != nil {
+				for _, hash := range req.hashes {
+					pending[hash] = struct{}{}
+				}
+			}
+			requested := make([]bool, len(task.txs))
+			for i, tx := range task.txs {
+				_, requested[i] = pending[tx.Hash()]
+			}
+			select {
+			case filter <- &txFilterTask{peer: task.peer, txs: task.txs, requested: requested}:
+			case <-f.quit:
+				return
+			}
+
+		case peer := <-f.drop:
+			// A peer disconnected, move its requests to the alternates
+			if req := f.requests[peer]; req != nil {
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+					}
+				}
+				delete(f.requests, peer)
+			}
+			for hash := range f.announces[peer] {
+				f.forgetAnnounce(peer, hash)
+			}
+			delete(f.announces, peer)
+			f.scheduleFetches(timeoutTimer)
+		}
+	}
+}
+
+// scheduleFetches assigns the queued transactions to idle peers having announced
+// them and sends out the retrieval requests.
+func (f *TxFetcher) scheduleFetches(timeout *time.Timer) {
+	if len(f.queued) == 0 {
+		return
+	}
+	idle := len(f.requests) == 0
+	for peer, known := range f.announces {
+		if f.requests[peer] != nil {
+			continue
+		}
+		var hashes []common.Hash
+		for hash := range known {
+			if _, ok := f.queued[hash]; !ok {
+				continue
+			}
+			hashes = append(hashes, hash)
+			if len(hashes) >= maxTxRetrievals {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		for _, hash := range hashes {
+			delete(f.queued, hash)
+			f.fetching[hash] = peer
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: time.Now()}
+		txRequestOutMeter.Mark(int64(len(hashes)))
+
+		log.Trace("Fetching scheduled transactions", "peer", peer, "count", len(hashes))
+		if f.fetchingHook != nil {
+			f.fetchingHook(peer, hashes)
+		}
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+			}
+		}(peer, hashes)
+	}
+	if idle && len(f.requests) > 0 {
+		f.rescheduleTimeout(timeout)
+	}
+}
+
+// rescheduleWait resets the specified wait timer to the next announce timeout.
+func (f *TxFetcher) rescheduleWait(timer *time.Timer) {
+	if len(f.waiting) == 0 {
+		return
+	}
+	earliest := time.Now()
+	for _, start := range f.waiting {
+		if earliest.After(start) {
+			earliest = start
+		}
+	}
+	timer.Reset(txArriveTimeout - time.Since(earliest))
+}
+
+// rescheduleTimeout resets the specified timeout timer to the next request
+// timeout.
+func (f *TxFetcher) rescheduleTimeout(timer *time.Timer) {
+	if len(f.requests) == 0 {
+		return
+	}
+	earliest := time.Now()
+	for _, req := range f.requests {
+		if earliest.After(req.time) {
+			earliest = req.time
+		}
+	}
+	timer.Reset(txFetchTimeout - time.Since(earliest))
+}
+
+// forgetAnnounce removes the announcement of a transaction by a single peer. If
+// no other peer announced it, the transaction is dropped altogether, otherwise
+// it's queued up for retrieval from one of the alternates.
+func (f *TxFetcher) forgetAnnounce(peer string, hash common.Hash) {
+	delete(f.announces[peer], hash)
+	if peers := f.announced[hash]; peers != nil {
+		delete(peers, peer)
+		if len(peers) > 0 {
+			if _, ok := f.fetching[hash]; !ok {
+				if _, ok := f.waiting[hash]; !ok {
+					f.queued[hash] = struct{}{}
+				}
+			}
+			return
+		}
+	}
+	delete(f.announced, hash)
+	delete(f.waiting, hash)
+	delete(f.queued, hash)
+}
+
+// forgetHash removes all traces of a transaction announcement from the fetcher's
+// internal state.
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	for peer := range f.announced[hash] {
+		delete(f.announces[peer], hash)
+	}
+	delete(f.announced, hash)
+	delete(f.waiting, hash)
+	delete(f.queued, hash)
+	delete(f.fetching, hash)
+}
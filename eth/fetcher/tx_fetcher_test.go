@@ -0,0 +1,264 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/types"
+)
+
+// txFetchRequest is a transaction retrieval issued by the fetcher.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for mocking out the local transaction
+// pool and the remote peers.
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	requests chan *txFetchRequest
+
+	pool map[common.Hash]*types.Transaction
+	lock sync.RWMutex
+}
+
+// newTxFetcherTester creates a new transaction fetcher test mocker.
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		requests: make(chan *txFetchRequest, 16),
+		pool:     make(map[common.Hash]*types.Transaction),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.addTxs, tester.fetchTxs)
+	tester.fetcher.Start()
+	return tester
+}
+
+func (t *txFetcherTester) hasTx(hash common.Hash) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.pool[hash] != nil
+}
+
+func (t *txFetcherTester) addTxs(txs []*types.Transaction) []error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, tx := range txs {
+		t.pool[tx.Hash()] = tx
+	}
+	return make([]error, len(txs))
+}
+
+func (t *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	t.requests <- &txFetchRequest{peer: peer, hashes: hashes}
+	return nil
+}
+
+// makeTxs creates a batch of distinct transactions.
+func makeTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	}
+	return txs
+}
+
+// hashesOf returns the hashes of a batch of transactions.
+func hashesOf(txs []*types.Transaction) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
+
+// verifyTxRequest checks that a transaction retrieval was issued to the given
+// peer for the given number of transactions.
+func verifyTxRequest(t *testing.T, requests chan *txFetchRequest, peer string, count int, wait time.Duration) *txFetchRequest {
+	select {
+	case req := <-requests:
+		if req.peer != peer {
+			t.Fatalf("request peer mismatch: have %s, want %s", req.peer, peer)
+		}
+		if len(req.hashes) != count {
+			t.Fatalf("request size mismatch: have %d, want %d", len(req.hashes), count)
+		}
+		return req
+	case <-time.After(wait):
+		t.Fatalf("no request issued to %s", peer)
+	}
+	return nil
+}
+
+// verifyNoTxRequest checks that no transaction retrieval is issued.
+func verifyNoTxRequest(t *testing.T, requests chan *txFetchRequest, wait time.Duration) {
+	select {
+	case req := <-requests:
+		t.Fatalf("unexpected request to %s for %d txs", req.peer, len(req.hashes))
+	case <-time.After(wait):
+	}
+}
+
+// Tests that announced transactions are retrieved after the arrival timeout and
+// that announcements from multiple peers are only retrieved once.
+func TestTxFetcherAnnounce(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(8)
+	tester.fetcher.Notify("A", hashesOf(txs))
+	tester.fetcher.Notify("B", hashesOf(txs))
+
+	req := verifyTxRequest(t, tester.requests, "A", len(txs), 2*txArriveTimeout)
+	if req == nil {
+		req = verifyTxRequest(t, tester.requests, "B", len(txs), 2*txArriveTimeout)
+	}
+	tester.fetcher.Enqueue(req.peer, txs, true)
+	verifyNoTxRequest(t, tester.requests, 2*txArriveTimeout)
+
+	for _, tx := range txs {
+		if !tester.hasTx(tx.Hash()) {
+			t.Errorf("transaction %x not added to the pool", tx.Hash())
+		}
+	}
+}
+
+// Tests that transactions arriving through broadcasts within the arrival window
+// are never explicitly retrieved.
+func TestTxFetcherBroadcastArrival(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.fetcher.Notify("A", hashesOf(txs))
+	tester.fetcher.Enqueue("B", txs, false)
+
+	verifyNoTxRequest(t, tester.requests, 2*txArriveTimeout)
+}
+
+// Tests that already known transactions are not scheduled for retrieval.
+func TestTxFetcherKnownAnnounce(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.addTxs(txs[:2])
+	tester.fetcher.Notify("A", hashesOf(txs))
+
+	req := verifyTxRequest(t, tester.requests, "A", 2, 2*txArriveTimeout)
+	for _, hash := range req.hashes {
+		if tester.hasTx(hash) {
+			t.Errorf("known transaction %x requested", hash)
+		}
+	}
+}
+
+// Tests that transactions a peer failed to deliver in a reply are retrieved
+// from an alternate peer having announced them.
+func TestTxFetcherPartialReply(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.fetcher.Notify("A", hashesOf(txs))
+	verifyTxRequest(t, tester.requests, "A", len(txs), 2*txArriveTimeout)
+
+	tester.fetcher.Notify("B", hashesOf(txs))
+	tester.fetcher.Enqueue("A", txs[:1], true)
+
+	verifyTxRequest(t, tester.requests, "B", len(txs)-1, 2*txArriveTimeout)
+}
+
+// Tests that if a peer with an in-flight request disconnects, the transactions
+// are retrieved from an alternate peer.
+func TestTxFetcherDropPeer(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.fetcher.Notify("A", hashesOf(txs))
+	verifyTxRequest(t, tester.requests, "A", len(txs), 2*txArriveTimeout)
+
+	tester.fetcher.Notify("B", hashesOf(txs))
+	tester.fetcher.Drop("A")
+
+	verifyTxRequest(t, tester.requests, "B", len(txs), 2*txArriveTimeout)
+}
+
+// Tests that timed out retrievals are rescheduled to alternate peers.
+func TestTxFetcherTimeout(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.fetcher.Notify("A", hashesOf(txs))
+	verifyTxRequest(t, tester.requests, "A", len(txs), 2*txArriveTimeout)
+
+	tester.fetcher.Notify("B", hashesOf(txs))
+	verifyNoTxRequest(t, tester.requests, txFetchTimeout/2)
+	verifyTxRequest(t, tester.requests, "B", len(txs), txFetchTimeout)
+}
+
+// Tests that transactions delivered in a reply without having been requested
+// from the peer are dropped instead of being added to the pool.
+func TestTxFetcherUnrequestedReply(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(4)
+	tester.fetcher.Notify("A", hashesOf(txs[:2]))
+	verifyTxRequest(t, tester.requests, "A", 2, 2*txArriveTimeout)
+
+	// A reply from a peer without any request in flight is dropped entirely
+	for i, err := range tester.fetcher.Enqueue("B", txs[:2], true) {
+		if err != ErrUnrequestedTx {
+			t.Errorf("transaction %d from unasked peer: error mismatch: have %v, want %v", i, err, ErrUnrequestedTx)
+		}
+	}
+	for _, tx := range txs[:2] {
+		if tester.hasTx(tx.Hash()) {
+			t.Errorf("unrequested transaction %x added to the pool", tx.Hash())
+		}
+	}
+	// A reply mixing requested and unrequested transactions only keeps the former
+	errs := tester.fetcher.Enqueue("A", txs, true)
+	for i, tx := range txs {
+		want := error(nil)
+		if i >= 2 {
+			want = ErrUnrequestedTx
+		}
+		if errs[i] != want {
+			t.Errorf("transaction %d: error mismatch: have %v, want %v", i, errs[i], want)
+		}
+		if tester.hasTx(tx.Hash()) != (want == nil) {
+			t.Errorf("transaction %d: pool presence mismatch: have %v, want %v", i, tester.hasTx(tx.Hash()), want == nil)
+		}
+	}
+	// The request being answered, resending the same reply is unrequested too
+	for i, err := range tester.fetcher.Enqueue("A", txs[:2], true) {
+		if err != ErrUnrequestedTx {
+			t.Errorf("transaction %d in repeated reply: error mismatch: have %v, want %v", i, err, ErrUnrequestedTx)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -324,26 +325,13 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
-	if crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
-	}
 	// Run the filter and return all the logs
 	//
 	// 运行过滤器并返回所有日志
+	filter, err := api.newLogFilter(crit)
+	if err != nil {
+		return nil, err
+	}
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
@@ -351,6 +339,12 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	return returnLogs(logs), err
 }
 
+// GetLogsPage returns a page of at most the requested number of logs matching
+// the given argument, continuing from the cursor of the previous page if set.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, page LogPageRequest) (*LogPage, error) {
+	return api.logsPage(ctx, crit, page)
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
@@ -373,39 +367,143 @@ func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterlogs
 func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	crit, err := api.logsFilterCriteria(id)
+	if err != nil {
+		return nil, err
+	}
+	// Run the filter and return all the logs
+	//
+	// 运行过滤器并返回所有日志
+	filter, err := api.newLogFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return returnLogs(logs), nil
+}
+
+// GetFilterLogsPage returns a page of at most the requested number of logs for
+// the filter with the given id, continuing from the cursor of the previous page
+// if set.
+func (api *PublicFilterAPI) GetFilterLogsPage(ctx context.Context, id rpc.ID, page LogPageRequest) (*LogPage, error) {
+	crit, err := api.logsFilterCriteria(id)
+	if err != nil {
+		return nil, err
+	}
+	return api.logsPage(ctx, crit, page)
+}
+
+// logsFilterCriteria returns the criteria of the logs filter with the given id.
+func (api *PublicFilterAPI) logsFilterCriteria(id rpc.ID) (FilterCriteria, error) {
 	api.filtersMu.Lock()
 	f, found := api.filters[id]
 	api.filtersMu.Unlock()
 
 	if !found || f.typ != LogsSubscription {
-		return nil, fmt.Errorf("filter not found")
+		return FilterCriteria{}, fmt.Errorf("filter not found")
 	}
+	return f.crit, nil
+}
 
+// newLogFilter constructs the filter retrieving the logs matching the criteria.
+func (api *PublicFilterAPI) newLogFilter(crit FilterCriteria) (*Filter, error) {
+	if err := checkHashLimits(crit.BlockHashes, crit.TxHashes); err != nil {
+		return nil, err
+	}
 	var filter *Filter
-	if f.crit.BlockHash != nil {
+	switch {
+	case crit.BlockHash != nil:
 		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
+		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
+	case len(crit.BlockHashes) > 0:
+		filter = NewBlocksFilter(api.backend, crit.BlockHashes, crit.Addresses, crit.Topics)
+	case !isRangeQuery(crit):
+		// Only transactions requested, search the blocks containing them
+		return NewTxFilter(api.backend, crit.TxHashes, crit.Addresses, crit.Topics)
+	default:
 		// Convert the RPC block numbers into internal representations
 		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
+		if crit.FromBlock != nil {
+			begin = crit.FromBlock.Int64()
 		}
 		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
+		if crit.ToBlock != nil {
+			end = crit.ToBlock.Int64()
 		}
 		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
+		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	//
-	// 运行过滤器并返回所有日志
-	logs, err := filter.Logs(ctx)
+	if len(crit.TxHashes) > 0 {
+		filter.SetTxHashes(crit.TxHashes)
+	}
+	return filter, nil
+}
+
+// checkHashLimits ensures that a query names no more blocks and transactions
+// than it may, as every one of them has to be looked up individually.
+func checkHashLimits(blocks []common.Hash, txs []common.Hash) error {
+	if len(blocks) > maxFilterHashes {
+		return fmt.Errorf("too many block hashes: %d, at most %d allowed", len(blocks), maxFilterHashes)
+	}
+	if len(txs) > maxFilterHashes {
+		return fmt.Errorf("too many transaction hashes: %d, at most %d allowed", len(txs), maxFilterHashes)
+	}
+	return nil
+}
+
+// isRangeQuery checks whether the criteria query a range of blocks, as opposed
+// to specific blocks or the blocks of specific transactions.
+func isRangeQuery(crit FilterCriteria) bool {
+	if crit.BlockHash != nil || len(crit.BlockHashes) > 0 {
+		return false
+	}
+	return len(crit.TxHashes) == 0 || crit.FromBlock != nil || crit.ToBlock != nil
+}
+
+// logsPage retrieves a page of the logs matching the criteria, starting from the
+// cursor of the request if set.
+func (api *PublicFilterAPI) logsPage(ctx context.Context, crit FilterCriteria, page LogPageRequest) (*LogPage, error) {
+	if page.Limit <= 0 || page.Limit > maxLogPageSize {
+		return nil, fmt.Errorf("page limit must be between 1 and %d", maxLogPageSize)
+	}
+	// Continue from the block of the cursor, skipping the logs before it
+	cursor := page.Cursor
+	if cursor != nil && isRangeQuery(crit) {
+		crit.FromBlock = new(big.Int).SetUint64(cursor.Block)
+	}
+	filter, err := api.newLogFilter(crit)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), nil
+	// Retrieve one more log than requested to know whether there are more. The
+	// filter stops at block boundaries, so continue it if skipped logs made the
+	// search stop early.
+	var logs []*types.Log
+	for {
+		filter.SetLimit(page.Limit + 1 - len(logs))
+		found, err := filter.Logs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range found {
+			if cursor != nil && cursor.after(log) {
+				continue
+			}
+			logs = append(logs, log)
+		}
+		if len(logs) > page.Limit || !filter.limited {
+			break
+		}
+	}
+	result := &LogPage{Logs: returnLogs(logs)}
+	if len(logs) > page.Limit {
+		next := logs[page.Limit]
+		result.Logs, result.Cursor = logs[:page.Limit], &LogCursor{Block: next.BlockNumber, Index: next.Index}
+	}
+	return result, nil
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
@@ -460,14 +558,72 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+const (
+	// maxLogPageSize is the maximum number of logs a page may be requested to hold.
+	maxLogPageSize = 10000
+
+	// maxFilterHashes is the maximum number of block or transaction hashes a
+	// query may name.
+	maxFilterHashes = 1000
+)
+
+// LogCursor is the position of the next log to retrieve when paginating through
+// the logs matching a query. It is encoded as an opaque continuation token.
+type LogCursor struct {
+	Block uint64 // Number of the block containing the log
+	Index uint   // Index of the log within the block
+}
+
+// after checks whether the cursor is positioned after the given log.
+func (c *LogCursor) after(log *types.Log) bool {
+	return log.BlockNumber < c.Block || (log.BlockNumber == c.Block && log.Index < c.Index)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c LogCursor) MarshalText() ([]byte, error) {
+	var blob [16]byte
+	binary.BigEndian.PutUint64(blob[:8], c.Block)
+	binary.BigEndian.PutUint64(blob[8:], uint64(c.Index))
+	return hexutil.Bytes(blob[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *LogCursor) UnmarshalText(input []byte) error {
+	var blob hexutil.Bytes
+	if err := blob.UnmarshalText(input); err != nil {
+		return err
+	}
+	if len(blob) != 16 {
+		return errors.New("invalid log cursor")
+	}
+	c.Block = binary.BigEndian.Uint64(blob[:8])
+	c.Index = uint(binary.BigEndian.Uint64(blob[8:]))
+	return nil
+}
+
+// LogPageRequest requests a page of the logs matching a query.
+type LogPageRequest struct {
+	Limit  int        `json:"limit"`            // Maximum number of logs in the page
+	Cursor *LogCursor `json:"cursor,omitempty"` // Cursor of the previous page to continue from
+}
+
+// LogPage is a page of the logs matching a query. The cursor is set if there are
+// more logs, to request the next page with.
+type LogPage struct {
+	Logs   []*types.Log `json:"logs"`
+	Cursor *LogCursor   `json:"cursor"`
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
-		BlockHash *common.Hash     `json:"blockHash"`
-		FromBlock *rpc.BlockNumber `json:"fromBlock"`
-		ToBlock   *rpc.BlockNumber `json:"toBlock"`
-		Addresses interface{}      `json:"address"`
-		Topics    []interface{}    `json:"topics"`
+		BlockHash   *common.Hash     `json:"blockHash"`
+		BlockHashes []common.Hash    `json:"blockHashes"`
+		FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+		ToBlock     *rpc.BlockNumber `json:"toBlock"`
+		Addresses   interface{}      `json:"address"`
+		Topics      []interface{}    `json:"topics"`
+		TxHashes    []common.Hash    `json:"transactionHashes"`
 	}
 
 	var raw input
@@ -475,12 +631,19 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if raw.BlockHash != nil {
+	if raw.BlockHash != nil && len(raw.BlockHashes) > 0 {
+		return fmt.Errorf("cannot specify both BlockHash and BlockHashes, choose one or the other")
+	}
+	if err := checkHashLimits(raw.BlockHashes, raw.TxHashes); err != nil {
+		return err
+	}
+	if raw.BlockHash != nil || len(raw.BlockHashes) > 0 {
 		if raw.FromBlock != nil || raw.ToBlock != nil {
 			// BlockHash is mutually exclusive with FromBlock/ToBlock criteria
 			return fmt.Errorf("cannot specify both BlockHash and FromBlock/ToBlock, choose one or the other")
 		}
 		args.BlockHash = raw.BlockHash
+		args.BlockHashes = raw.BlockHashes
 	} else {
 		if raw.FromBlock != nil {
 			args.FromBlock = big.NewInt(raw.FromBlock.Int64())
@@ -491,6 +654,7 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	args.TxHashes = raw.TxHashes
 	args.Addresses = []common.Address{}
 
 	if raw.Addresses != nil {
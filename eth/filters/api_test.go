@@ -19,6 +19,7 @@ package filters
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/go-ethereum-analysis/common"
@@ -183,3 +184,49 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
 }
+
+func TestUnmarshalJSONHashFilterArgs(t *testing.T) {
+	var (
+		hash0 = common.HexToHash("3ac225168df54212a25c1c01fd35bebfea408fdac2e31ddd6f80a4bbf9a5f1ca")
+		hash1 = common.HexToHash("9084a792d2f8b16a62b882fd56f7860c07bf5fa91dd8a2ae7e809e5180fef0b3")
+	)
+	// block and transaction hashes
+	var test0 FilterCriteria
+	vector := fmt.Sprintf(`{"blockHashes":["%s","%s"],"transactionHashes":["%s"]}`, hash0.Hex(), hash1.Hex(), hash1.Hex())
+	if err := json.Unmarshal([]byte(vector), &test0); err != nil {
+		t.Fatal(err)
+	}
+	if len(test0.BlockHashes) != 2 || test0.BlockHashes[0] != hash0 || test0.BlockHashes[1] != hash1 {
+		t.Fatalf("invalid block hashes, got %x", test0.BlockHashes)
+	}
+	if len(test0.TxHashes) != 1 || test0.TxHashes[0] != hash1 {
+		t.Fatalf("invalid transaction hashes, got %x", test0.TxHashes)
+	}
+	// transaction hashes within a range
+	var test1 FilterCriteria
+	vector = fmt.Sprintf(`{"fromBlock":"0x1","transactionHashes":["%s"]}`, hash0.Hex())
+	if err := json.Unmarshal([]byte(vector), &test1); err != nil {
+		t.Fatal(err)
+	}
+	if test1.FromBlock == nil || test1.FromBlock.Int64() != 1 || len(test1.TxHashes) != 1 {
+		t.Fatalf("invalid criteria, got %+v", test1)
+	}
+	// block hashes are exclusive with a single block hash and ranges
+	invalid := []string{
+		fmt.Sprintf(`{"blockHash":"%s","blockHashes":["%s"]}`, hash0.Hex(), hash1.Hex()),
+		fmt.Sprintf(`{"blockHashes":["%s"],"fromBlock":"0x1"}`, hash0.Hex()),
+		fmt.Sprintf(`{"blockHashes":["%s"],"toBlock":"latest"}`, hash0.Hex()),
+	}
+	// too many block or transaction hashes
+	many := `"` + strings.TrimSuffix(strings.Repeat(hash0.Hex()+`","`, maxFilterHashes+1), `,"`)
+	invalid = append(invalid,
+		fmt.Sprintf(`{"blockHashes":[%s]}`, many),
+		fmt.Sprintf(`{"transactionHashes":[%s]}`, many),
+	)
+	for i, vector := range invalid {
+		var crit FilterCriteria
+		if err := json.Unmarshal([]byte(vector), &crit); err == nil {
+			t.Errorf("invalid criteria %d accepted: %.200s", i, vector)
+		}
+	}
+}
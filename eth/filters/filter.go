@@ -19,17 +19,25 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
+	"sort"
+	"sync"
 
 	"github.com/go-ethereum-analysis/common"
 	"github.com/go-ethereum-analysis/core"
 	"github.com/go-ethereum-analysis/core/bloombits"
+	"github.com/go-ethereum-analysis/core/rawdb"
 	"github.com/go-ethereum-analysis/core/types"
 	"github.com/go-ethereum-analysis/ethdb"
 	"github.com/go-ethereum-analysis/event"
 	"github.com/go-ethereum-analysis/rpc"
 )
 
+// matchBatchSize is the maximum number of bloom matched blocks whose headers and
+// logs are retrieved concurrently.
+const matchBatchSize = 16
+
 type Backend interface {
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
@@ -55,8 +63,12 @@ type Filter struct {
 	addresses []common.Address
 	topics    [][]common.Hash
 
-	block      common.Hash // Block hash if filtering a single block
-	begin, end int64       // Range interval if filtering multiple blocks
+	blocks     []common.Hash        // Block hashes if filtering specific blocks
+	begin, end int64                // Range interval if filtering multiple blocks
+	txs        map[common.Hash]bool // Transactions the logs must be emitted by (nil = any)
+
+	limit   int  // Number of logs after which range searches stop at the end of a block (0 = no limit)
+	limited bool // Whether the last range search stopped because of the limit
 
 	matcher *bloombits.Matcher
 }
@@ -97,12 +109,41 @@ func NewRangeFilter(backend Backend, begin, end int64, addresses []common.Addres
 // NewBlockFilter creates a new filter which directly inspects the contents of
 // a block to figure out whether it is interesting or not.
 func NewBlockFilter(backend Backend, block common.Hash, addresses []common.Address, topics [][]common.Hash) *Filter {
-	// Create a generic filter and convert it into a block filter
+	return NewBlocksFilter(backend, []common.Hash{block}, addresses, topics)
+}
+
+// NewBlocksFilter creates a new filter which directly inspects the contents of
+// specific blocks to figure out whether they are interesting or not.
+func NewBlocksFilter(backend Backend, blocks []common.Hash, addresses []common.Address, topics [][]common.Hash) *Filter {
+	// Create a generic filter and convert it into a blocks filter
 	filter := newFilter(backend, addresses, topics)
-	filter.block = block
+	filter.blocks = append([]common.Hash{}, blocks...)
 	return filter
 }
 
+// NewTxFilter creates a new filter which inspects the blocks containing the given
+// transactions, returning the matching logs emitted by them. An error is returned
+// if a transaction is missing from the local transaction index, e.g. because it
+// was never included or the index was pruned.
+func NewTxFilter(backend Backend, txs []common.Hash, addresses []common.Address, topics [][]common.Hash) (*Filter, error) {
+	db := backend.ChainDb()
+
+	var blocks []common.Hash
+	for _, tx := range txs {
+		block, _, _ := rawdb.ReadTxLookupEntry(db, tx)
+		if block == (common.Hash{}) {
+			if tail := rawdb.ReadTxIndexTail(db); tail != nil && *tail > 0 {
+				return nil, fmt.Errorf("transaction %x not found, only blocks since #%d are indexed", tx, *tail)
+			}
+			return nil, fmt.Errorf("transaction %x not found", tx)
+		}
+		blocks = append(blocks, block)
+	}
+	filter := NewBlocksFilter(backend, blocks, addresses, topics)
+	filter.SetTxHashes(txs)
+	return filter, nil
+}
+
 // newFilter creates a generic filter that can either filter based on a block hash,
 // or based on range queries. The search criteria needs to be explicitly set.
 func newFilter(backend Backend, addresses []common.Address, topics [][]common.Hash) *Filter {
@@ -114,21 +155,46 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 	}
 }
 
+// SetTxHashes restricts the logs returned by the filter to those emitted by the
+// given transactions. Nil removes the restriction.
+func (f *Filter) SetTxHashes(txs []common.Hash) {
+	if txs == nil {
+		f.txs = nil
+		return
+	}
+	f.txs = make(map[common.Hash]bool, len(txs))
+	for _, tx := range txs {
+		f.txs[tx] = true
+	}
+}
+
+// SetLimit makes range searches stop at the end of the first block at which at
+// least limit logs were found. The start of the filter is updated, so that the
+// search continues from the next block on a subsequent call to Logs. Zero
+// removes the limit.
+func (f *Filter) SetLimit(limit int) {
+	f.limit = limit
+}
+
+// limitReached checks whether the given number of logs found reaches the limit
+// of the filter, flagging the search as limited if so.
+func (f *Filter) limitReached(found int) bool {
+	if f.limit > 0 && found >= f.limit {
+		f.limited = true
+	}
+	return f.limited
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 //
 // TODO 注意: 这里会用到 BloomIndex
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
-	// If we're doing singleton block filtering, execute and return
-	if f.block != (common.Hash{}) {
-		header, err := f.backend.HeaderByHash(ctx, f.block)
-		if err != nil {
-			return nil, err
-		}
-		if header == nil {
-			return nil, errors.New("unknown block")
-		}
-		return f.blockLogs(ctx, header)
+	f.limited = false
+
+	// If we're filtering specific blocks, execute and return
+	if f.blocks != nil {
+		return f.blocksLogs(ctx)
 	}
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
@@ -156,13 +222,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		} else {
 			logs, err = f.indexedLogs(ctx, indexed-1)
 		}
-		if err != nil {
+		if err != nil || f.limited {
 			return logs, err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
-	return logs, err
+	return f.unindexedLogs(ctx, end, logs)
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
@@ -186,6 +250,7 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	var logs []*types.Log
 
 	for {
+		var batch []uint64
 		select {
 		case number, ok := <-matches:
 			// Abort if all matches have been fulfilled
@@ -196,35 +261,123 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				}
 				return logs, err
 			}
-			f.begin = int64(number) + 1
-
-			// Retrieve the suggested block and pull any truly matching logs
-			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
-			if header == nil || err != nil {
-				return logs, err
-			}
-			found, err := f.checkMatches(ctx, header)
-			if err != nil {
-				return logs, err
-			}
-			logs = append(logs, found...)
+			batch = append(batch, number)
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
 		}
+		// Gather any further matches already available to retrieve them together
+	gather:
+		for len(batch) < matchBatchSize {
+			select {
+			case number, ok := <-matches:
+				if !ok {
+					break gather
+				}
+				batch = append(batch, number)
+			default:
+				break gather
+			}
+		}
+		// Retrieve the suggested blocks and pull any truly matching logs
+		for _, res := range f.matchedLogs(ctx, batch) {
+			f.begin = int64(res.number) + 1
+			if res.header == nil || res.err != nil {
+				return logs, res.err
+			}
+			if logs = append(logs, res.logs...); f.limitReached(len(logs)) {
+				return logs, nil
+			}
+		}
 	}
 }
 
-// indexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
+// matchResult is the outcome of checking a single bloom matched block.
+type matchResult struct {
+	number uint64
+	header *types.Header
+	logs   []*types.Log
+	err    error
+}
+
+// matchedLogs retrieves the headers and the matching logs of a batch of bloom
+// matched blocks concurrently, returning the results in block order. Light
+// clients need several network round trips per block, which would otherwise
+// be done one after the other.
+func (f *Filter) matchedLogs(ctx context.Context, numbers []uint64) []matchResult {
+	results := make([]matchResult, len(numbers))
+
+	var wg sync.WaitGroup
+	for i, number := range numbers {
+		wg.Add(1)
+		go func(res *matchResult, number uint64) {
+			defer wg.Done()
+
+			res.number = number
+			res.header, res.err = f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if res.header == nil || res.err != nil {
+				return
+			}
+			res.logs, res.err = f.checkMatches(ctx, res.header)
+		}(&results[i], number)
+	}
+	wg.Wait()
+	return results
+}
 
+// unindexedLogs appends the logs matching the filter criteria to the ones already
+// found, based on raw block iteration and bloom matching.
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	for ; f.begin <= int64(end); f.begin++ {
+		// Stop scanning if the request was abandoned
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return logs, err
 		}
+		found, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return logs, err
+		}
+		if logs = append(logs, found...); f.limitReached(len(logs)) {
+			f.begin++
+			return logs, nil
+		}
+	}
+	return logs, nil
+}
+
+// blocksLogs returns the logs matching the filter criteria within the specific
+// blocks of the filter, ordered by block number.
+func (f *Filter) blocksLogs(ctx context.Context) ([]*types.Log, error) {
+	var (
+		headers = make([]*types.Header, 0, len(f.blocks))
+		seen    = make(map[common.Hash]bool)
+	)
+	for _, hash := range f.blocks {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		header, err := f.backend.HeaderByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, errors.New("unknown block")
+		}
+		headers = append(headers, header)
+	}
+	sort.SliceStable(headers, func(i, j int) bool {
+		return headers[i].Number.Cmp(headers[j].Number) < 0
+	})
+	var logs []*types.Log
+	for _, header := range headers {
 		found, err := f.blockLogs(ctx, header)
 		if err != nil {
 			return logs, err
@@ -272,11 +425,25 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 			}
 			logs = filterLogs(unfiltered, nil, nil, f.addresses, f.topics)
 		}
-		return logs, nil
+		return f.txLogs(logs), nil
 	}
 	return nil, nil
 }
 
+// txLogs drops the logs not emitted by the transactions of the filter, if any.
+func (f *Filter) txLogs(logs []*types.Log) []*types.Log {
+	if f.txs == nil {
+		return logs
+	}
+	var ret []*types.Log
+	for _, log := range logs {
+		if f.txs[log.TxHash] {
+			ret = append(ret, log)
+		}
+	}
+	return ret
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
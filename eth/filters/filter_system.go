@@ -196,6 +196,10 @@ func (es *EventSystem) subscribe(sub *subscription) *Subscription {
 // given criteria to the given logs channel. Default value for the from and to
 // block is "latest". If the fromBlock > toBlock an error is returned.
 func (es *EventSystem) SubscribeLogs(crit ethereum.FilterQuery, logs chan []*types.Log) (*Subscription, error) {
+	// New logs can't be matched against specific blocks or transactions
+	if len(crit.BlockHashes) > 0 || len(crit.TxHashes) > 0 {
+		return nil, fmt.Errorf("cannot filter new logs by block or transaction hashes")
+	}
 	var from, to rpc.BlockNumber
 	if crit.FromBlock == nil {
 		from = rpc.LatestBlockNumber
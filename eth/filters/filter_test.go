@@ -18,9 +18,12 @@ package filters
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/go-ethereum-analysis/common"
@@ -230,3 +233,221 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// Tests that paginated log queries return all matching logs exactly once, with
+// pages splitting blocks if needed.
+func TestLogPagination(t *testing.T) {
+	var (
+		db         = ethdb.NewMemDatabase()
+		mux        = new(event.TypeMux)
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		api        = NewPublicFilterAPI(backend, false)
+		key, _     = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr       = crypto.PubkeyToAddress(key.PublicKey)
+	)
+	// Create blocks with a varying number of logs, none in every third block
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		for j := 0; j < i%3; j++ {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, BlockNumber: uint64(i + 1), Index: uint(j)})
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	crit := FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr}}
+
+	want, err := api.GetLogs(context.Background(), crit)
+	if err != nil {
+		t.Fatalf("failed to retrieve all logs: %v", err)
+	}
+	if len(want) != 19 {
+		t.Fatalf("log count mismatch: have %d, want 19", len(want))
+	}
+	for _, limit := range []int{1, 2, 3, 7, 20, 100} {
+		var (
+			logs  []*types.Log
+			page  = LogPageRequest{Limit: limit}
+			pages int
+		)
+		for {
+			res, err := api.GetLogsPage(context.Background(), crit, page)
+			if err != nil {
+				t.Fatalf("limit %d: failed to retrieve page %d: %v", limit, pages, err)
+			}
+			if len(res.Logs) > limit {
+				t.Fatalf("limit %d: page %d too large: %d logs", limit, pages, len(res.Logs))
+			}
+			logs = append(logs, res.Logs...)
+			pages++
+
+			if res.Cursor == nil {
+				break
+			}
+			// Pass the cursor through its encoding, as clients do
+			token, err := json.Marshal(res.Cursor)
+			if err != nil {
+				t.Fatalf("limit %d: failed to encode cursor: %v", limit, err)
+			}
+			page = LogPageRequest{Limit: limit, Cursor: new(LogCursor)}
+			if err := json.Unmarshal(token, page.Cursor); err != nil {
+				t.Fatalf("limit %d: failed to decode cursor %s: %v", limit, token, err)
+			}
+		}
+		if !reflect.DeepEqual(logs, want) {
+			t.Errorf("limit %d: paginated logs mismatch: have %d logs, want %d", limit, len(logs), len(want))
+		}
+		if wantPages := (len(want) + limit - 1) / limit; pages != wantPages {
+			t.Errorf("limit %d: page count mismatch: have %d, want %d", limit, pages, wantPages)
+		}
+	}
+	// Invalid limits are rejected
+	for _, limit := range []int{0, -1, maxLogPageSize + 1} {
+		if _, err := api.GetLogsPage(context.Background(), crit, LogPageRequest{Limit: limit}); err == nil {
+			t.Errorf("limit %d: page retrieved", limit)
+		}
+	}
+}
+
+// Tests that logs can be filtered by specific blocks and transactions.
+func TestHashFilters(t *testing.T) {
+	var (
+		db         = ethdb.NewMemDatabase()
+		mux        = new(event.TypeMux)
+		txFeed     = new(event.Feed)
+		rmLogsFeed = new(event.Feed)
+		logsFeed   = new(event.Feed)
+		chainFeed  = new(event.Feed)
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		api        = NewPublicFilterAPI(backend, false)
+		key, _     = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr       = crypto.PubkeyToAddress(key.PublicKey)
+		other      = common.BytesToHash([]byte("other"))
+	)
+	// Create blocks with a transaction each, emitting a log next to a log of an
+	// unrelated transaction
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x1}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen.AddTx(tx)
+
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{
+			{Address: addr, BlockNumber: uint64(i + 1), TxHash: tx.Hash(), Index: 0},
+			{Address: addr, BlockNumber: uint64(i + 1), TxHash: other, Index: 1},
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		rawdb.WriteTxLookupEntries(db, block)
+	}
+	blockHash := func(number int) common.Hash { return chain[number-1].Hash() }
+	txHash := func(number int) common.Hash { return chain[number-1].Transactions()[0].Hash() }
+
+	type position struct {
+		block uint64
+		index uint
+	}
+	tests := []struct {
+		crit FilterCriteria
+		want []position
+	}{
+		// Blocks are deduplicated and ordered by number
+		{
+			crit: FilterCriteria{BlockHashes: []common.Hash{blockHash(5), blockHash(2), blockHash(2)}},
+			want: []position{{2, 0}, {2, 1}, {5, 0}, {5, 1}},
+		},
+		// Transactions on their own are looked up
+		{
+			crit: FilterCriteria{TxHashes: []common.Hash{txHash(7), txHash(3)}},
+			want: []position{{3, 0}, {7, 0}},
+		},
+		// Transactions restrict ranges and blocks
+		{
+			crit: FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(5), TxHashes: []common.Hash{txHash(3), txHash(7)}},
+			want: []position{{3, 0}},
+		},
+		{
+			crit: FilterCriteria{BlockHashes: []common.Hash{blockHash(4), blockHash(8)}, TxHashes: []common.Hash{txHash(3), other}},
+			want: []position{{4, 1}, {8, 1}},
+		},
+	}
+	for i, tt := range tests {
+		result, err := api.GetLogs(context.Background(), tt.crit)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve logs: %v", i, err)
+		}
+		have := []position{}
+		for _, log := range result {
+			have = append(have, position{log.BlockNumber, log.Index})
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: logs mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Pages through specific blocks don't repeat logs
+	var (
+		all  = FilterCriteria{BlockHashes: []common.Hash{blockHash(9), blockHash(1), blockHash(5)}}
+		page = LogPageRequest{Limit: 4}
+		logs []*types.Log
+	)
+	for {
+		res, err := api.GetLogsPage(context.Background(), all, page)
+		if err != nil {
+			t.Fatalf("failed to retrieve page: %v", err)
+		}
+		if logs = append(logs, res.Logs...); res.Cursor == nil {
+			break
+		}
+		page.Cursor = res.Cursor
+	}
+	if len(logs) != 6 || logs[0].BlockNumber != 1 || logs[5].BlockNumber != 9 {
+		t.Errorf("paginated logs mismatch: have %d logs", len(logs))
+	}
+	// Unknown blocks and transactions are reported
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{BlockHashes: []common.Hash{blockHash(1), other}}); err == nil {
+		t.Error("logs of unknown block retrieved")
+	}
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{TxHashes: []common.Hash{txHash(1), other}}); err == nil {
+		t.Error("logs of unknown transaction retrieved")
+	}
+	// Transactions dropped from a pruned index are reported as such
+	rawdb.DeleteTxLookupEntry(db, txHash(2))
+	rawdb.WriteTxIndexTail(db, 3)
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{TxHashes: []common.Hash{txHash(2)}}); err == nil || !strings.Contains(err.Error(), "only blocks since #3 are indexed") {
+		t.Errorf("unindexed transaction error mismatch: have %v", err)
+	}
+	// Queries naming too many hashes are rejected
+	many := make([]common.Hash, maxFilterHashes+1)
+	for i := range many {
+		many[i] = blockHash(1)
+	}
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{BlockHashes: many}); err == nil {
+		t.Error("logs of too many blocks retrieved")
+	}
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{TxHashes: many}); err == nil {
+		t.Error("logs of too many transactions retrieved")
+	}
+	// New logs can't be filtered by hashes
+	if _, err := api.NewFilter(FilterCriteria{TxHashes: []common.Hash{txHash(1)}}); err == nil {
+		t.Error("filter by transaction hashes installed")
+	}
+}
@@ -17,6 +17,8 @@
 package eth
 
 import (
+	"bytes"
+	"errors"
 	"math"
 	"math/big"
 	"math/rand"
@@ -34,7 +36,9 @@ import (
 	"github.com/go-ethereum-analysis/ethdb"
 	"github.com/go-ethereum-analysis/event"
 	"github.com/go-ethereum-analysis/p2p"
+	"github.com/go-ethereum-analysis/p2p/discover"
 	"github.com/go-ethereum-analysis/params"
+	"github.com/go-ethereum-analysis/rlp"
 )
 
 // Tests that protocol versions and modes of operations are matched up properly.
@@ -69,6 +73,7 @@ func TestProtocolCompatibility(t *testing.T) {
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders62(t *testing.T) { testGetBlockHeaders(t, 62) }
 func TestGetBlockHeaders63(t *testing.T) { testGetBlockHeaders(t, 63) }
+func TestGetBlockHeaders66(t *testing.T) { testGetBlockHeaders(t, 66) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxHashFetch+15, nil, nil)
@@ -207,8 +212,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			headers = append(headers, pm.blockchain.GetBlockByHash(hash).Header())
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x03, tt.query)
-		if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+		peer.sendRequest(0x03, uint64(i+1), tt.query)
+		if err := peer.expectReply(0x04, uint64(i+1), headers); err != nil {
 			t.Errorf("test %d: headers mismatch: %v", i, err)
 		}
 		// If the test used number origins, repeat with hashes as the too
@@ -216,8 +221,8 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 			if origin := pm.blockchain.GetBlockByNumber(tt.query.Origin.Number); origin != nil {
 				tt.query.Origin.Hash, tt.query.Origin.Number = origin.Hash(), 0
 
-				p2p.Send(peer.app, 0x03, tt.query)
-				if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+				peer.sendRequest(0x03, uint64(i+1), tt.query)
+				if err := peer.expectReply(0x04, uint64(i+1), headers); err != nil {
 					t.Errorf("test %d: headers mismatch: %v", i, err)
 				}
 			}
@@ -228,6 +233,7 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies62(t *testing.T) { testGetBlockBodies(t, 62) }
 func TestGetBlockBodies63(t *testing.T) { testGetBlockBodies(t, 63) }
+func TestGetBlockBodies66(t *testing.T) { testGetBlockBodies(t, 66) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxBlockFetch+15, nil, nil)
@@ -290,8 +296,8 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 			}
 		}
 		// Send the hash request and verify the response
-		p2p.Send(peer.app, 0x05, hashes)
-		if err := p2p.ExpectMsg(peer.app, 0x06, bodies); err != nil {
+		peer.sendRequest(0x05, uint64(i+1), hashes)
+		if err := peer.expectReply(0x06, uint64(i+1), bodies); err != nil {
 			t.Errorf("test %d: bodies mismatch: %v", i, err)
 		}
 	}
@@ -299,6 +305,7 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the node state database can be retrieved based on hashes.
 func TestGetNodeData63(t *testing.T) { testGetNodeData(t, 63) }
+func TestGetNodeData66(t *testing.T) { testGetNodeData(t, 66) }
 
 func testGetNodeData(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -348,7 +355,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 			hashes = append(hashes, common.BytesToHash(key))
 		}
 	}
-	p2p.Send(peer.app, 0x0d, hashes)
+	peer.sendRequest(0x0d, 1, hashes)
 	msg, err := peer.app.ReadMsg()
 	if err != nil {
 		t.Fatalf("failed to read node data response: %v", err)
@@ -357,9 +364,13 @@ func testGetNodeData(t *testing.T, protocol int) {
 		t.Fatalf("response packet code mismatch: have %x, want %x", msg.Code, 0x0c)
 	}
 	var data [][]byte
-	if err := msg.Decode(&data); err != nil {
+	id, err := peer.decodeTagged(msg, &data)
+	if err != nil {
 		t.Fatalf("failed to decode response node data: %v", err)
 	}
+	if peer.version >= eth66 && id != 1 {
+		t.Fatalf("response request id mismatch: have %d, want %d", id, 1)
+	}
 	// Verify that all hashes correspond to the requested data, and reconstruct a state tree
 	for i, want := range hashes {
 		if hash := crypto.Keccak256Hash(data[i]); hash != want {
@@ -391,6 +402,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
+func TestGetReceipt66(t *testing.T) { testGetReceipt(t, 66) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -442,8 +454,8 @@ func testGetReceipt(t *testing.T, protocol int) {
 		receipts = append(receipts, pm.blockchain.GetReceiptsByHash(block.Hash()))
 	}
 	// Send the hash request and verify the response
-	p2p.Send(peer.app, 0x0f, hashes)
-	if err := p2p.ExpectMsg(peer.app, 0x10, receipts); err != nil {
+	peer.sendRequest(0x0f, 1, hashes)
+	if err := peer.expectReply(0x10, 1, receipts); err != nil {
 		t.Errorf("receipts mismatch: %v", err)
 	}
 }
@@ -520,3 +532,40 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		}
 	}
 }
+
+// replyFailingRW is a message pipe end delivering the queued messages, but
+// failing to send any reply.
+type replyFailingRW struct {
+	msgs chan p2p.Msg
+}
+
+func (rw *replyFailingRW) ReadMsg() (p2p.Msg, error) { return <-rw.msgs, nil }
+func (rw *replyFailingRW) WriteMsg(p2p.Msg) error    { return errors.New("write failed") }
+
+// Tests that peers are penalized for the messages they send, but not for our
+// own failures to reply to them.
+func TestInvalidMessagePenalty(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 1, nil, nil)
+	defer pm.Stop()
+
+	rw := &replyFailingRW{msgs: make(chan p2p.Msg, 1)}
+	p := pm.newPeer(eth63, p2p.NewPeer(discover.NodeID{1}, "peer", nil), rw)
+
+	// A valid request whose reply fails to be sent
+	size, payload, _ := rlp.EncodeToReader(&getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: 1})
+	rw.msgs <- p2p.Msg{Code: GetBlockHeadersMsg, Size: uint32(size), Payload: payload}
+	if err := pm.handleMsg(p); err == nil {
+		t.Fatalf("failed reply not reported")
+	}
+	if score := p.Score(); score != 0 {
+		t.Fatalf("peer penalized for local failure: score %d", score)
+	}
+	// A malformed request
+	rw.msgs <- p2p.Msg{Code: GetBlockHeadersMsg, Size: 1, Payload: bytes.NewReader([]byte{0x01})}
+	if err := pm.handleMsg(p); err == nil {
+		t.Fatalf("malformed request accepted")
+	}
+	if score := p.Score(); score != scoreInvalidMessage {
+		t.Fatalf("peer score mismatch: have %d, want %d", score, scoreInvalidMessage)
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/go-ethereum-analysis/p2p"
 	"github.com/go-ethereum-analysis/p2p/discover"
 	"github.com/go-ethereum-analysis/params"
+	"github.com/go-ethereum-analysis/rlp"
 )
 
 var (
@@ -108,6 +109,19 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// Get retrieves the transaction from the pool with the given hash.
+func (p *testTxPool) Get(hash common.Hash) *types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, tx := range p.pool {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
@@ -194,6 +208,31 @@ func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesi
 	}
 }
 
+// sendRequest sends a data retrieval request to the remote protocol manager,
+// tagging it with the given id on eth/66 and above.
+func (p *testPeer) sendRequest(msgcode uint64, id uint64, data interface{}) error {
+	return p2p.Send(p.app, msgcode, p.tag(id, data))
+}
+
+// expectReply checks that the remote protocol manager replied with the given
+// data, tagged with the given request id on eth/66 and above.
+func (p *testPeer) expectReply(msgcode uint64, id uint64, data interface{}) error {
+	return p2p.ExpectMsg(p.app, msgcode, p.tag(id, data))
+}
+
+// tag wraps a message payload into an eth/66 envelope if the peer runs a
+// protocol version tagging retrievals with request ids.
+func (p *testPeer) tag(id uint64, data interface{}) interface{} {
+	if p.version < eth66 {
+		return data
+	}
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		panic(err)
+	}
+	return &taggedPacket{RequestId: id, Payload: payload}
+}
+
 // close terminates the local side of the peer, notifying the remote protocol
 // manager of termination.
 func (p *testPeer) close() {
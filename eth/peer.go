@@ -45,6 +45,11 @@ const (
 	// contain a single transaction, or thousands.
 	maxQueuedTxs = 128
 
+	// maxQueuedTxAnns is the maximum number of transaction announcements to queue up
+	// before dropping broadcasts. Similarly to transaction lists, an announcement
+	// might contain a single hash, or thousands.
+	maxQueuedTxAnns = 128
+
 	// maxQueuedProps is the maximum number of block propagations to queue up before
 	// dropping broadcasts. There's not much point in queueing stale blocks, so a few
 	// that might cover uncles should be enough.
@@ -56,6 +61,12 @@ const (
 	maxQueuedAnns = 4
 
 	handshakeTimeout = 5 * time.Second
+
+	// maxTxRewardPerInterval is the maximum reputation a peer can gain by
+	// propagating new transactions during a single txRewardInterval, preventing
+	// it from farming score by flooding cheap transactions.
+	maxTxRewardPerInterval = 10
+	txRewardInterval       = time.Minute
 )
 
 // PeerInfo represents a short summary of the Ethereum sub-protocol metadata known
@@ -87,26 +98,31 @@ type peer struct {
 	td   *big.Int
 	lock sync.RWMutex
 
-	knownTxs    mapset.Set                // Set of transaction hashes known to be known by this peer  该节点的某些已知的TxHash
-	knownBlocks mapset.Set                // Set of block hashes known to be known by this peer  该节点的某些已知的blockHash
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	txRewardStart time.Time // Start of the current transaction reward interval
+	txRewarded    int64     // Reputation already rewarded for transactions in the interval
+
+	knownTxs     mapset.Set                // Set of transaction hashes known to be known by this peer  该节点的某些已知的TxHash
+	knownBlocks  mapset.Set                // Set of block hashes known to be known by this peer  该节点的某些已知的blockHash
+	queuedTxs    chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedHashes chan []common.Hash        // Queue of transaction hashes to announce to the peer
+	queuedProps  chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns   chan *types.Block         // Queue of blocks to announce to the peer
+	term         chan struct{}             // Termination channel to stop the broadcaster
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownTxs:    mapset.NewSet(),
-		knownBlocks: mapset.NewSet(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		id:           fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownTxs:     mapset.NewSet(),
+		knownBlocks:  mapset.NewSet(),
+		queuedTxs:    make(chan []*types.Transaction, maxQueuedTxs),
+		queuedHashes: make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:  make(chan *propEvent, maxQueuedProps),
+		queuedAnns:   make(chan *types.Block, maxQueuedAnns),
+		term:         make(chan struct{}),
 	}
 }
 
@@ -122,6 +138,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedHashes:
+			if err := p.SendPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -201,6 +223,24 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 	p.knownTxs.Add(hash)
 }
 
+// RewardTransactions raises the reputation of the peer for propagating useful
+// transactions, up to maxTxRewardPerInterval in every txRewardInterval.
+func (p *peer) RewardTransactions(reward int64) {
+	p.lock.Lock()
+	if now := time.Now(); now.Sub(p.txRewardStart) >= txRewardInterval {
+		p.txRewardStart, p.txRewarded = now, 0
+	}
+	if allowance := maxTxRewardPerInterval - p.txRewarded; reward > allowance {
+		reward = allowance
+	}
+	p.txRewarded += reward
+	p.lock.Unlock()
+
+	if reward > 0 {
+		p.AdjustScore(reward)
+	}
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
@@ -223,6 +263,39 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// SendPooledTransactionHashes announces the availability of a batch of
+// transactions through a hash notification, leaving it up to the remote peer
+// to retrieve the ones it doesn't know about.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// AsyncSendPooledTransactionHashes queues a batch of transaction hashes for
+// announcement to a remote peer. If the peer's announcement queue is full, the
+// event is silently dropped.
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedHashes <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		p.Log().Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+// SendPooledTransactionsRLP sends a batch of transactions requested by the remote
+// peer from an already RLP encoded format.
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -268,71 +341,173 @@ func (p *peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
 
 // SendBlockHeaders sends a batch of block headers to the remote peer.
 func (p *peer) SendBlockHeaders(headers []*types.Header) error {
-	return p2p.Send(p.rw, BlockHeadersMsg, headers)
+	return p.ReplyBlockHeaders(0, headers)
+}
+
+// ReplyBlockHeaders sends a batch of block headers to the remote peer in reply
+// to the request with the given id.
+func (p *peer) ReplyBlockHeaders(id uint64, headers []*types.Header) error {
+	return p.sendTagged(BlockHeadersMsg, id, headers)
 }
 
 // SendBlockBodies sends a batch of block contents to the remote peer.
 func (p *peer) SendBlockBodies(bodies []*blockBody) error {
-	return p2p.Send(p.rw, BlockBodiesMsg, blockBodiesData(bodies))
+	return p.sendTagged(BlockBodiesMsg, 0, blockBodiesData(bodies))
 }
 
 // SendBlockBodiesRLP sends a batch of block contents to the remote peer from
 // an already RLP encoded format.
 func (p *peer) SendBlockBodiesRLP(bodies []rlp.RawValue) error {
-	return p2p.Send(p.rw, BlockBodiesMsg, bodies)
+	return p.ReplyBlockBodiesRLP(0, bodies)
+}
+
+// ReplyBlockBodiesRLP sends a batch of block contents to the remote peer from
+// an already RLP encoded format, in reply to the request with the given id.
+func (p *peer) ReplyBlockBodiesRLP(id uint64, bodies []rlp.RawValue) error {
+	return p.sendTagged(BlockBodiesMsg, id, bodies)
 }
 
 // SendNodeDataRLP sends a batch of arbitrary internal data, corresponding to the
 // hashes requested.
 func (p *peer) SendNodeData(data [][]byte) error {
-	return p2p.Send(p.rw, NodeDataMsg, data)
+	return p.ReplyNodeData(0, data)
+}
+
+// ReplyNodeData sends a batch of arbitrary internal data, corresponding to the
+// hashes requested by the request with the given id.
+func (p *peer) ReplyNodeData(id uint64, data [][]byte) error {
+	return p.sendTagged(NodeDataMsg, id, data)
 }
 
 // SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
 // ones requested from an already RLP encoded format.
 func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
-	return p2p.Send(p.rw, ReceiptsMsg, receipts)
+	return p.ReplyReceiptsRLP(0, receipts)
+}
+
+// ReplyReceiptsRLP sends a batch of transaction receipts, corresponding to the
+// ones requested by the request with the given id, from an already RLP encoded
+// format.
+func (p *peer) ReplyReceiptsRLP(id uint64, receipts []rlp.RawValue) error {
+	return p.sendTagged(ReceiptsMsg, id, receipts)
 }
 
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
 	p.Log().Debug("Fetching single header", "hash", hash)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
+	return p.sendTagged(GetBlockHeadersMsg, 0, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
 }
 
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromhash", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.sendTagged(GetBlockHeadersMsg, 0, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
 func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
-	p.Log().Debug("Fetching batch of headers", "count", amount, "fromnum", origin, "skip", skip, "reverse", reverse)
-	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+	return p.RequestTaggedHeadersByNumber(0, origin, amount, skip, reverse)
+}
+
+// RequestTaggedHeadersByNumber fetches a batch of blocks' headers corresponding
+// to the specified header query, based on the number of an origin block. On
+// eth/66 and above the request is tagged with the given id.
+func (p *peer) RequestTaggedHeadersByNumber(id uint64, origin uint64, amount int, skip int, reverse bool) error {
+	p.Log().Debug("Fetching batch of headers", "id", id, "count", amount, "fromnum", origin, "skip", skip, "reverse", reverse)
+	return p.sendTagged(GetBlockHeadersMsg, id, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
 func (p *peer) RequestBodies(hashes []common.Hash) error {
-	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
-	return p2p.Send(p.rw, GetBlockBodiesMsg, hashes)
+	return p.RequestTaggedBodies(0, hashes)
+}
+
+// RequestTaggedBodies fetches a batch of blocks' bodies corresponding to the
+// hashes specified. On eth/66 and above the request is tagged with the given id.
+func (p *peer) RequestTaggedBodies(id uint64, hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of block bodies", "id", id, "count", len(hashes))
+	return p.sendTagged(GetBlockBodiesMsg, id, hashes)
 }
 
 // RequestNodeData fetches a batch of arbitrary data from a node's known state
 // data, corresponding to the specified hashes.
 func (p *peer) RequestNodeData(hashes []common.Hash) error {
-	p.Log().Debug("Fetching batch of state data", "count", len(hashes))
-	return p2p.Send(p.rw, GetNodeDataMsg, hashes)
+	return p.RequestTaggedNodeData(0, hashes)
+}
+
+// RequestTaggedNodeData fetches a batch of arbitrary data from a node's known
+// state data, corresponding to the specified hashes. On eth/66 and above the
+// request is tagged with the given id.
+func (p *peer) RequestTaggedNodeData(id uint64, hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of state data", "id", id, "count", len(hashes))
+	return p.sendTagged(GetNodeDataMsg, id, hashes)
 }
 
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
 func (p *peer) RequestReceipts(hashes []common.Hash) error {
-	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
-	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
+	return p.RequestTaggedReceipts(0, hashes)
+}
+
+// RequestTaggedReceipts fetches a batch of transaction receipts from a remote
+// node. On eth/66 and above the request is tagged with the given id.
+func (p *peer) RequestTaggedReceipts(id uint64, hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of receipts", "id", id, "count", len(hashes))
+	return p.sendTagged(GetReceiptsMsg, id, hashes)
+}
+
+// sendTagged sends a data retrieval request or reply to the remote peer. From
+// eth/66 the message is wrapped into an envelope carrying the request id.
+func (p *peer) sendTagged(msgcode uint64, id uint64, data interface{}) error {
+	if p.version < eth66 {
+		return p2p.Send(p.rw, msgcode, data)
+	}
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return err
+	}
+	return p2p.Send(p.rw, msgcode, &taggedPacket{RequestId: id, Payload: payload})
+}
+
+// decodeTagged decodes a data retrieval reply into val, returning the id of the
+// request it answers. Replies of peers below eth/66 are untagged (id 0).
+func (p *peer) decodeTagged(msg p2p.Msg, val interface{}) (uint64, error) {
+	if p.version < eth66 {
+		return 0, msg.Decode(val)
+	}
+	var packet taggedPacket
+	if err := msg.Decode(&packet); err != nil {
+		return 0, err
+	}
+	return packet.RequestId, rlp.DecodeBytes(packet.Payload, val)
+}
+
+// requestStream opens the list of hashes carried by a data retrieval request
+// for streamed decoding, returning the id of the request (0 below eth/66).
+func (p *peer) requestStream(msg p2p.Msg) (*rlp.Stream, uint64, error) {
+	stream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := stream.List(); err != nil {
+		return nil, 0, err
+	}
+	var id uint64
+	if p.version >= eth66 {
+		if err := stream.Decode(&id); err != nil {
+			return nil, 0, err
+		}
+		if _, err := stream.List(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return stream, id, nil
+}
+
+// RequestTxs fetches a batch of transactions from a remote node.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
@@ -374,7 +549,6 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
-
 // 本地节点读取对端peer 发来的消息
 func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
@@ -369,6 +369,62 @@ func (ec *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]typ
 	return result, err
 }
 
+// IterateFilterLogs returns an iterator over the logs matching the filter query,
+// retrieving them in pages of at most limit logs. Unlike FilterLogs, it allows
+// going through more logs than the server returns at once. The server must
+// support paginated log queries.
+func (ec *Client) IterateFilterLogs(q ethereum.FilterQuery, limit int) *LogIterator {
+	return &LogIterator{ec: ec, arg: toFilterArg(q), limit: limit}
+}
+
+// LogIterator pages through the logs matching a filter query.
+type LogIterator struct {
+	ec     *Client
+	arg    interface{}
+	limit  int
+	cursor json.RawMessage // opaque continuation token of the next page
+	logs   []types.Log
+	done   bool
+	err    error
+}
+
+// Next retrieves the next page of logs. It returns false when there are no more
+// logs or the retrieval failed, which Err reports.
+func (it *LogIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	page := map[string]interface{}{"limit": it.limit}
+	if it.cursor != nil {
+		page["cursor"] = it.cursor
+	}
+	var result struct {
+		Logs   []types.Log     `json:"logs"`
+		Cursor json.RawMessage `json:"cursor"`
+	}
+	if it.err = it.ec.c.CallContext(ctx, &result, "eth_getLogsPage", it.arg, page); it.err != nil {
+		it.logs = nil
+		return false
+	}
+	it.logs = result.Logs
+	if len(result.Cursor) == 0 || string(result.Cursor) == "null" {
+		it.done = true
+	} else {
+		it.cursor = result.Cursor
+	}
+	return len(it.logs) > 0
+}
+
+// Logs returns the page of logs retrieved by the last call to Next.
+func (it *LogIterator) Logs() []types.Log {
+	return it.logs
+}
+
+// Err returns the error that made Next fail, if any.
+func (it *LogIterator) Err() error {
+	return it.err
+}
+
 // SubscribeFilterLogs subscribes to the results of a streaming filter query.
 func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
 	return ec.c.EthSubscribe(ctx, ch, "logs", toFilterArg(q))
@@ -376,13 +432,25 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuer
 
 func toFilterArg(q ethereum.FilterQuery) interface{} {
 	arg := map[string]interface{}{
-		"fromBlock": toBlockNumArg(q.FromBlock),
-		"toBlock":   toBlockNumArg(q.ToBlock),
-		"address":   q.Addresses,
-		"topics":    q.Topics,
+		"address": q.Addresses,
+		"topics":  q.Topics,
+	}
+	switch {
+	case q.BlockHash != nil:
+		arg["blockHash"] = *q.BlockHash
+	case len(q.BlockHashes) > 0:
+		arg["blockHashes"] = q.BlockHashes
+	case len(q.TxHashes) > 0 && q.FromBlock == nil && q.ToBlock == nil:
+		// The blocks containing the transactions are searched
+	default:
+		arg["fromBlock"] = toBlockNumArg(q.FromBlock)
+		arg["toBlock"] = toBlockNumArg(q.ToBlock)
+		if q.FromBlock == nil {
+			arg["fromBlock"] = "0x0"
+		}
 	}
-	if q.FromBlock == nil {
-		arg["fromBlock"] = "0x0"
+	if len(q.TxHashes) > 0 {
+		arg["transactionHashes"] = q.TxHashes
 	}
 	return arg
 }
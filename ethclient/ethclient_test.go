@@ -16,7 +16,26 @@
 
 package ethclient
 
-import "github.com/go-ethereum-analysis"
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-ethereum-analysis"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/consensus/ethash"
+	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/eth"
+	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/node"
+	"github.com/go-ethereum-analysis/params"
+)
 
 // Verify that Client implements the ethereum interfaces.
 var (
@@ -32,3 +51,102 @@ var (
 	// _ = ethereum.PendingStateEventer(&Client{})
 	_ = ethereum.PendingContractCaller(&Client{})
 )
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(1000000000000000000)
+
+	// logCode is the init code of a contract emitting five empty logs on creation.
+	logCode = common.FromHex(strings.Repeat("60006000a0", 5))
+)
+
+// newTestNode starts a networkless node running a chain whose blocks carry the
+// given number of log emitting transactions each.
+func newTestNode(t *testing.T, txsPerBlock []int) (*node.Node, []*types.Block) {
+	workspace, err := ioutil.TempDir("", "ethclient-test-")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	genesis := &core.Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: testBalance}},
+	}
+	var (
+		db    = ethdb.NewMemDatabase()
+		nonce uint64
+	)
+	blocks, _ := core.GenerateChain(genesis.Config, genesis.MustCommit(db), ethash.NewFaker(), db, len(txsPerBlock), func(i int, gen *core.BlockGen) {
+		for j := 0; j < txsPerBlock[i]; j++ {
+			tx, _ := types.SignTx(types.NewContractCreation(nonce, new(big.Int), 100000, big.NewInt(1), logCode), types.HomesteadSigner{}, testKey)
+			gen.AddTx(tx)
+			nonce++
+		}
+	})
+	stack, err := node.New(&node.Config{DataDir: workspace, Name: "ethclient-test"})
+	if err != nil {
+		os.RemoveAll(workspace)
+		t.Fatalf("failed to create node: %v", err)
+	}
+	config := &eth.Config{Genesis: genesis, Ethash: ethash.Config{PowMode: ethash.ModeFake}}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) { return eth.New(ctx, config) }); err != nil {
+		t.Fatalf("failed to register Ethereum protocol: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start test node: %v", err)
+	}
+	var ethservice *eth.Ethereum
+	stack.Service(&ethservice)
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import test chain: %v", err)
+	}
+	return stack, blocks
+}
+
+// Tests that the log iterator goes through all the logs matching a query in
+// pages, even if a single block carries more logs than fit into a page.
+func TestLogIterator(t *testing.T) {
+	stack, blocks := newTestNode(t, []int{2, 0, 1})
+	defer os.RemoveAll(stack.DataDir())
+	defer stack.Stop()
+
+	rpcclient, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	client := NewClient(rpcclient)
+	defer client.Close()
+
+	query := ethereum.FilterQuery{FromBlock: big.NewInt(0)}
+	want, err := client.FilterLogs(context.Background(), query)
+	if err != nil {
+		t.Fatalf("failed to retrieve all logs: %v", err)
+	}
+	if len(want) != 15 {
+		t.Fatalf("log count mismatch: have %d, want 15", len(want))
+	}
+	var (
+		it    = client.IterateFilterLogs(query, 4)
+		have  []types.Log
+		pages int
+	)
+	for it.Next(context.Background()) {
+		if logs := it.Logs(); len(logs) > 4 {
+			t.Fatalf("page %d too large: %d logs", pages, len(logs))
+		}
+		have = append(have, it.Logs()...)
+		pages++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("failed to iterate logs: %v", err)
+	}
+	if pages != 4 {
+		t.Errorf("page count mismatch: have %d, want 4", pages)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("iterated logs mismatch: have %d logs, want %d", len(have), len(want))
+	}
+	if have[0].BlockHash != blocks[0].Hash() || have[4].BlockHash != blocks[0].Hash() {
+		t.Errorf("first pages don't split the logs of block #1")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/go-ethereum-analysis"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/event"
+	"github.com/go-ethereum-analysis/rpc"
+)
+
+// backfillTimeout bounds the requests retrieving the events missed while a
+// resilient subscription was down.
+const backfillTimeout = 30 * time.Second
+
+// ResilientSubscribeNewHead subscribes to notifications about the current
+// blockchain head like SubscribeNewHead, but survives connection failures. The
+// subscription is re-established with a backoff of at most backoffMax between
+// attempts, and the heads imported while it was down are retrieved and delivered
+// before any new ones.
+func (ec *Client) ResilientSubscribeNewHead(ctx context.Context, ch chan<- *types.Header, backoffMax time.Duration) (ethereum.Subscription, error) {
+	var last *types.Header // last head delivered, accessed by one subscription at a time
+
+	return rpc.Resubscribe(ctx, backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		heads := make(chan *types.Header)
+		sub, err := ec.SubscribeNewHead(ctx, heads)
+		if err != nil {
+			return nil, err
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			// Deliver the heads missed since the last one seen. Heads already
+			// delivered are skipped when they arrive on the new subscription too.
+			backfilled := make(map[common.Hash]bool)
+			if last != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+				defer cancel()
+
+				head, err := ec.HeaderByNumber(ctx, nil)
+				if err != nil {
+					return err
+				}
+				for number := new(big.Int).Add(last.Number, common.Big1); number.Cmp(head.Number) <= 0; number.Add(number, common.Big1) {
+					header, err := ec.HeaderByNumber(ctx, number)
+					if err != nil {
+						return err
+					}
+					select {
+					case ch <- header:
+					case <-quit:
+						return nil
+					}
+					backfilled[header.Hash()] = true
+					last = header
+				}
+			}
+			// Forward the heads of the subscription until it fails
+			for {
+				select {
+				case header := <-heads:
+					if backfilled[header.Hash()] {
+						continue
+					}
+					select {
+					case ch <- header:
+					case <-quit:
+						return nil
+					}
+					last = header
+
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	})
+}
+
+// ResilientSubscribeFilterLogs subscribes to the results of a streaming filter
+// query like SubscribeFilterLogs, but survives connection failures. The
+// subscription is re-established with a backoff of at most backoffMax between
+// attempts, and the logs emitted while it was down are retrieved and delivered
+// before any new ones.
+func (ec *Client) ResilientSubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log, backoffMax time.Duration) (ethereum.Subscription, error) {
+	// Logs are backfilled from the block of the last log delivered, skipping the
+	// ones of that block delivered already. If there was no log yet, from the block
+	// after the head the subscription was established at.
+	type logKey struct {
+		block common.Hash
+		index uint
+	}
+	var (
+		from      *big.Int
+		delivered = make(map[logKey]bool)
+	)
+	deliver := func(log types.Log, quit <-chan struct{}) bool {
+		select {
+		case ch <- log:
+		case <-quit:
+			return false
+		}
+		if !log.Removed {
+			if number := new(big.Int).SetUint64(log.BlockNumber); from == nil || number.Cmp(from) > 0 {
+				from, delivered = number, make(map[logKey]bool)
+			}
+			delivered[logKey{log.BlockHash, log.Index}] = true
+		}
+		return true
+	}
+	return rpc.Resubscribe(ctx, backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		logs := make(chan types.Log)
+		sub, err := ec.SubscribeFilterLogs(ctx, q, logs)
+		if err != nil {
+			return nil, err
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+			defer cancel()
+
+			head, err := ec.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return err
+			}
+			backfilled := make(map[logKey]bool)
+			if from != nil && (q.ToBlock == nil || from.Cmp(q.ToBlock) <= 0) {
+				query := q
+				query.FromBlock, query.ToBlock = from, head.Number
+				if q.ToBlock != nil && q.ToBlock.Cmp(head.Number) < 0 {
+					query.ToBlock = q.ToBlock
+				}
+				past, err := ec.FilterLogs(ctx, query)
+				if err != nil {
+					return err
+				}
+				for _, log := range past {
+					key := logKey{log.BlockHash, log.Index}
+					if delivered[key] {
+						continue
+					}
+					if !deliver(log, quit) {
+						return nil
+					}
+					backfilled[key] = true
+				}
+			}
+			// Continue after the current head if no log was seen yet
+			if from == nil {
+				from = new(big.Int).Add(head.Number, common.Big1)
+			}
+			// Forward the logs of the subscription until it fails
+			for {
+				select {
+				case log := <-logs:
+					if !log.Removed && backfilled[logKey{log.BlockHash, log.Index}] {
+						continue
+					}
+					if !deliver(log, quit) {
+						return nil
+					}
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	})
+}
@@ -0,0 +1,338 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ethereum-analysis"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/event"
+	"github.com/go-ethereum-analysis/rpc"
+)
+
+// ResubChain is a fake chain served over the eth RPC namespace. It outlives the
+// servers exposing it, so that they can be restarted while the chain grows.
+type ResubChain struct {
+	headers []*types.Header
+	logs    [][]*types.Log // Logs emitted by each block
+	feed    event.Feed     // Block numbers of the newly added blocks
+	lock    sync.RWMutex
+}
+
+// newResubChain creates a fake chain consisting of a genesis block only.
+func newResubChain() *ResubChain {
+	return &ResubChain{
+		headers: []*types.Header{{Number: new(big.Int), Difficulty: common.Big1, Time: new(big.Int)}},
+		logs:    [][]*types.Log{nil},
+	}
+}
+
+// addBlock appends a new block emitting two logs to the chain and notifies the
+// subscribers about it.
+func (c *ResubChain) addBlock() {
+	c.lock.Lock()
+	parent := c.headers[len(c.headers)-1]
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		Difficulty: common.Big1,
+		Time:       new(big.Int).Add(parent.Time, common.Big1),
+	}
+	var logs []*types.Log
+	for i := 0; i < 2; i++ {
+		logs = append(logs, &types.Log{
+			Topics:      []common.Hash{},
+			Data:        []byte{},
+			BlockNumber: header.Number.Uint64(),
+			BlockHash:   header.Hash(),
+			Index:       uint(i),
+		})
+	}
+	c.headers = append(c.headers, header)
+	c.logs = append(c.logs, logs)
+	c.lock.Unlock()
+
+	c.feed.Send(header.Number.Uint64())
+}
+
+// ResubFilter is the subset of the filter criteria supported by the fake chain.
+type ResubFilter struct {
+	FromBlock rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   rpc.BlockNumber `json:"toBlock"`
+}
+
+// GetBlockByNumber returns the header of the requested block.
+func (c *ResubChain) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if number < 0 {
+		return c.headers[len(c.headers)-1]
+	}
+	if int(number) >= len(c.headers) {
+		return nil
+	}
+	return c.headers[number]
+}
+
+// GetLogs returns the logs of the blocks in the requested range.
+func (c *ResubChain) GetLogs(crit ResubFilter) []*types.Log {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	from, to := int(crit.FromBlock), int(crit.ToBlock)
+	if to < 0 || to >= len(c.logs) {
+		to = len(c.logs) - 1
+	}
+	logs := []*types.Log{}
+	for number := from; number <= to; number++ {
+		logs = append(logs, c.logs[number]...)
+	}
+	return logs
+}
+
+// NewHeads sends a notification for each new block added to the chain.
+func (c *ResubChain) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return c.subscribe(ctx, func(number uint64) []interface{} {
+		return []interface{}{c.headers[number]}
+	})
+}
+
+// Logs sends a notification for each log emitted by new blocks added to the chain.
+func (c *ResubChain) Logs(ctx context.Context, crit ResubFilter) (*rpc.Subscription, error) {
+	return c.subscribe(ctx, func(number uint64) []interface{} {
+		var items []interface{}
+		for _, log := range c.logs[number] {
+			items = append(items, log)
+		}
+		return items
+	})
+}
+
+// subscribe creates a subscription notifying the items the given function maps
+// each new block to.
+func (c *ResubChain) subscribe(ctx context.Context, items func(uint64) []interface{}) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		blocks := make(chan uint64)
+		sub := c.feed.Subscribe(blocks)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case number := <-blocks:
+				c.lock.RLock()
+				notifs := items(number)
+				c.lock.RUnlock()
+
+				for _, item := range notifs {
+					notifier.Notify(rpcSub.ID, item)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// resubServer is a restartable websocket RPC server exposing a fake chain.
+type resubServer struct {
+	chain    *ResubChain
+	addr     string
+	server   *rpc.Server
+	listener net.Listener
+}
+
+// start boots up the RPC server, on the address used previously if restarted.
+func (s *resubServer) start(t *testing.T) {
+	addr := s.addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", addr, err)
+	}
+	s.server = rpc.NewServer()
+	if err := s.server.RegisterName("eth", s.chain); err != nil {
+		t.Fatalf("failed to register fake chain: %v", err)
+	}
+	s.addr, s.listener = listener.Addr().String(), listener
+	go http.Serve(listener, s.server.WebsocketHandler([]string{"*"}))
+}
+
+// stop tears down the RPC server, dropping all of its connections.
+func (s *resubServer) stop() {
+	s.listener.Close()
+	s.server.Stop()
+}
+
+// resubTimeout is the allowance for notifications to arrive, covering the
+// reconnections too.
+const resubTimeout = 5 * time.Second
+
+// Tests that the heads imported while a resilient head subscription was down
+// are delivered after it is re-established, exactly once and in order.
+func TestResilientSubscribeNewHead(t *testing.T) {
+	srv := &resubServer{chain: newResubChain()}
+	srv.start(t)
+	defer func() { srv.stop() }()
+
+	client, err := Dial("ws://" + srv.addr)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header)
+	sub, err := client.ResilientSubscribeNewHead(context.Background(), heads, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Notifications are only sent once the server activated the subscription
+	time.Sleep(100 * time.Millisecond)
+
+	srv.chain.addBlock()
+	srv.chain.addBlock()
+	checkResubHeads(t, sub, heads, 1, 2)
+
+	// Grow the chain while the server is down, the missed heads must be backfilled
+	srv.stop()
+	srv.chain.addBlock()
+	srv.chain.addBlock()
+	srv.chain.addBlock()
+	srv.start(t)
+
+	checkResubHeads(t, sub, heads, 3, 5)
+
+	// New heads must follow the backfilled ones without repeating any of them
+	time.Sleep(100 * time.Millisecond)
+	srv.chain.addBlock()
+	checkResubHeads(t, sub, heads, 6, 6)
+
+	select {
+	case head := <-heads:
+		t.Fatalf("unexpected head #%d", head.Number)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// checkResubHeads verifies that the given range of heads is delivered, in order.
+func checkResubHeads(t *testing.T, sub ethereum.Subscription, heads chan *types.Header, from, to uint64) {
+	t.Helper()
+
+	for number := from; number <= to; number++ {
+		select {
+		case head := <-heads:
+			if head.Number.Uint64() != number {
+				t.Fatalf("head number mismatch: have %d, want %d", head.Number, number)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(resubTimeout):
+			t.Fatalf("head #%d not delivered", number)
+		}
+	}
+}
+
+// Tests that the logs emitted while a resilient log subscription was down are
+// delivered after it is re-established, exactly once and in order, without any
+// of the logs delivered before the failure.
+func TestResilientSubscribeFilterLogs(t *testing.T) {
+	srv := &resubServer{chain: newResubChain()}
+	srv.start(t)
+	defer func() { srv.stop() }()
+
+	client, err := Dial("ws://" + srv.addr)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer client.Close()
+
+	logs := make(chan types.Log)
+	sub, err := client.ResilientSubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, logs, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Notifications are only sent once the server activated the subscription
+	time.Sleep(100 * time.Millisecond)
+
+	srv.chain.addBlock()
+	srv.chain.addBlock()
+	checkResubLogs(t, sub, logs, 1, 2)
+
+	// Emit logs while the server is down, the backfill starts at the block of
+	// the last log delivered, but must skip the logs of it already delivered
+	srv.stop()
+	srv.chain.addBlock()
+	srv.chain.addBlock()
+	srv.start(t)
+
+	checkResubLogs(t, sub, logs, 3, 4)
+
+	// New logs must follow the backfilled ones without repeating any of them
+	time.Sleep(100 * time.Millisecond)
+	srv.chain.addBlock()
+	checkResubLogs(t, sub, logs, 5, 5)
+
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log #%d of block #%d", log.Index, log.BlockNumber)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// checkResubLogs verifies that the logs of the given range of blocks are
+// delivered, in order.
+func checkResubLogs(t *testing.T, sub ethereum.Subscription, logs chan types.Log, from, to uint64) {
+	t.Helper()
+
+	for number := from; number <= to; number++ {
+		for index := uint(0); index < 2; index++ {
+			select {
+			case log := <-logs:
+				if log.BlockNumber != number || log.Index != index {
+					t.Fatalf("log mismatch: have #%d of block #%d, want #%d of block #%d", log.Index, log.BlockNumber, index, number)
+				}
+			case err := <-sub.Err():
+				t.Fatalf("subscription failed: %v", err)
+			case <-time.After(resubTimeout):
+				t.Fatalf("log #%d of block #%d not delivered", index, number)
+			}
+		}
+	}
+}
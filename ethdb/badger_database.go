@@ -0,0 +1,360 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/log"
+)
+
+const (
+	// badgerGCInterval is the time between two value log garbage collection runs.
+	badgerGCInterval = 10 * time.Minute
+
+	// badgerGCDiscardRatio is the fraction of stale data in a value log file
+	// above which the file is rewritten.
+	badgerGCDiscardRatio = 0.5
+)
+
+// BadgerDatabase is a persistent database backed by the Badger LSM engine,
+// which keeps large values out of the LSM tree to reduce write amplification.
+//
+// Badger does not allow empty keys.
+type BadgerDatabase struct {
+	fn string     // filename for reporting
+	db *badger.DB // Badger instance
+
+	quitLock sync.Mutex    // Mutex protecting the quit channel access
+	quitChan chan struct{} // Quit channel to stop the value log garbage collection
+	wg       sync.WaitGroup
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewBadgerDatabase returns a Badger wrapped object. File handles are managed
+// by Badger itself, the cache allowance sizes the in-memory tables.
+func NewBadgerDatabase(file string, cache int, handles int) (*BadgerDatabase, error) {
+	logger := log.New("database", file)
+
+	// Ensure we have some minimal caching
+	if cache < 16 {
+		cache = 16
+	}
+	logger.Info("Allocated memtables", "cache", cache)
+
+	// Badger only creates the leaf directory, unlike LevelDB
+	if err := os.MkdirAll(file, 0700); err != nil {
+		return nil, err
+	}
+	opts := badger.DefaultOptions(file).
+		WithMaxTableSize(int64(cache / 4 * 1024 * 1024)).
+		WithTruncate(true).
+		WithLogger(badgerLogger{logger})
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	bdb := &BadgerDatabase{
+		fn:       file,
+		db:       db,
+		quitChan: make(chan struct{}),
+		log:      logger,
+	}
+	bdb.wg.Add(1)
+	go bdb.collectGarbage()
+
+	return bdb, nil
+}
+
+// Path returns the path to the database directory.
+func (db *BadgerDatabase) Path() string {
+	return db.fn
+}
+
+// Put puts the given key / value to the database
+func (db *BadgerDatabase) Put(key []byte, value []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(common.CopyBytes(key), common.CopyBytes(value))
+	})
+}
+
+func (db *BadgerDatabase) Has(key []byte) (bool, error) {
+	err := db.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get returns the given key if it's present.
+func (db *BadgerDatabase) Get(key []byte) ([]byte, error) {
+	var dat []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		dat, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+// Delete deletes the key from the database
+func (db *BadgerDatabase) Delete(key []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(common.CopyBytes(key))
+	})
+}
+
+// DeleteRange deletes all the keys in the range [start, limit).
+func (db *BadgerDatabase) DeleteRange(start []byte, limit []byte) error {
+	txn := db.db.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	wb := db.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for it.Seek(start); it.Valid(); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		if limit != nil && bytes.Compare(key, limit) >= 0 {
+			break
+		}
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// NewIterator returns an iterator over the subset of database content with a
+// particular key prefix, starting at a particular initial key (or after, if it
+// does not exist).
+func (db *BadgerDatabase) NewIterator(prefix []byte, start []byte) Iterator {
+	txn := db.db.NewTransaction(false)
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = common.CopyBytes(prefix)
+
+	return &badgerIterator{
+		txn:   txn,
+		it:    txn.NewIterator(opts),
+		start: append(common.CopyBytes(prefix), start...),
+	}
+}
+
+// Stat returns a particular internal stat of the database. Badger only reports
+// the on-disk size of its LSM tree and value log, under "badger.size" or an
+// empty property.
+func (db *BadgerDatabase) Stat(property string) (string, error) {
+	if property != "" && property != "badger.size" {
+		return "", fmt.Errorf("unknown property %q", property)
+	}
+	lsm, vlog := db.db.Size()
+	return fmt.Sprintf("LSM size: %d bytes\nValue log size: %d bytes\n", lsm, vlog), nil
+}
+
+// Compact flattens the LSM tree. Badger can't compact key ranges, so the whole
+// database is compacted regardless of the requested range.
+func (db *BadgerDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.Flatten(runtime.NumCPU())
+}
+
+func (db *BadgerDatabase) Close() {
+	// Stop the garbage collector before closing the database
+	db.quitLock.Lock()
+	defer db.quitLock.Unlock()
+
+	if db.quitChan != nil {
+		close(db.quitChan)
+		db.wg.Wait()
+		db.quitChan = nil
+	}
+	if err := db.db.Close(); err == nil {
+		db.log.Info("Database closed")
+	} else {
+		db.log.Error("Failed to close database", "err", err)
+	}
+}
+
+// collectGarbage periodically rewrites value log files with mostly stale data.
+// Badger never reclaims this space on its own.
+func (db *BadgerDatabase) collectGarbage() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(badgerGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Keep rewriting as long as there are files worth it
+			for db.db.RunValueLogGC(badgerGCDiscardRatio) == nil {
+			}
+		case <-db.quitChan:
+			return
+		}
+	}
+}
+
+func (db *BadgerDatabase) NewBatch() Batch {
+	return &badgerBatch{db: db.db}
+}
+
+// badgerBatch buffers writes in memory and commits them through a Badger
+// write batch, which transparently splits them into multiple transactions if
+// they don't fit into one.
+type badgerBatch struct {
+	db     *badger.DB
+	writes []kv
+	size   int
+}
+
+func (b *badgerBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *badgerBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), nil, true})
+	b.size += 1
+	return nil
+}
+
+func (b *badgerBatch) Write() error {
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, kv := range b.writes {
+		var err error
+		if kv.del {
+			err = wb.Delete(kv.k)
+		} else {
+			err = wb.Set(kv.k, kv.v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func (b *badgerBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *badgerBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// badgerIterator adapts a Badger iterator, along with the read-only transaction
+// it lives in, to the Iterator interface.
+type badgerIterator struct {
+	txn     *badger.Txn
+	it      *badger.Iterator
+	start   []byte
+	started bool
+	err     error
+}
+
+func (it *badgerIterator) Next() bool {
+	if it.it == nil {
+		return false
+	}
+	if !it.started {
+		it.it.Seek(it.start)
+		it.started = true
+	} else if it.it.Valid() {
+		it.it.Next()
+	}
+	return it.it.Valid()
+}
+
+func (it *badgerIterator) Error() error {
+	return it.err
+}
+
+func (it *badgerIterator) Key() []byte {
+	if it.it == nil || !it.started || !it.it.Valid() {
+		return nil
+	}
+	return it.it.Item().KeyCopy(nil)
+}
+
+func (it *badgerIterator) Value() []byte {
+	if it.it == nil || !it.started || !it.it.Valid() {
+		return nil
+	}
+	val, err := it.it.Item().ValueCopy(nil)
+	if err != nil {
+		it.err = err
+		return nil
+	}
+	return val
+}
+
+func (it *badgerIterator) Release() {
+	if it.it != nil {
+		it.it.Close()
+		it.txn.Discard()
+		it.it, it.txn = nil, nil
+	}
+}
+
+// badgerLogger forwards Badger's internal logs to the database logger.
+type badgerLogger struct {
+	log log.Logger
+}
+
+func (l badgerLogger) Errorf(format string, args ...interface{}) {
+	l.log.Error(fmt.Sprintf(strings.TrimSpace(format), args...))
+}
+
+func (l badgerLogger) Warningf(format string, args ...interface{}) {
+	l.log.Warn(fmt.Sprintf(strings.TrimSpace(format), args...))
+}
+
+func (l badgerLogger) Infof(format string, args ...interface{}) {
+	l.log.Debug(fmt.Sprintf(strings.TrimSpace(format), args...))
+}
+
+func (l badgerLogger) Debugf(format string, args ...interface{}) {
+	l.log.Trace(fmt.Sprintf(strings.TrimSpace(format), args...))
+}
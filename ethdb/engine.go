@@ -0,0 +1,139 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultEngine is the database backend used if none is explicitly requested.
+const DefaultEngine = "leveldb"
+
+// engineMarker is the file recording which backend created a database directory.
+const engineMarker = "ENGINE"
+
+// OpenFunc opens (or creates) a persistent database at the given path, using
+// the given amount of cache memory (in megabytes) and file handles.
+type OpenFunc func(file string, cache int, handles int) (Database, error)
+
+var (
+	enginesLock sync.RWMutex
+	engines     = make(map[string]OpenFunc)
+)
+
+func init() {
+	RegisterEngine("leveldb", func(file string, cache int, handles int) (Database, error) {
+		return NewLDBDatabase(file, cache, handles)
+	})
+	RegisterEngine("badger", func(file string, cache int, handles int) (Database, error) {
+		return NewBadgerDatabase(file, cache, handles)
+	})
+	RegisterEngine("memory", func(file string, cache int, handles int) (Database, error) {
+		return NewMemDatabase(), nil
+	})
+}
+
+// RegisterEngine makes a database backend available under the given name. It
+// panics if the name is already taken.
+func RegisterEngine(name string, open OpenFunc) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("ethdb: engine %q registered twice", name))
+	}
+	engines[name] = open
+}
+
+// Engines returns the sorted names of all registered database backends.
+func Engines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the database at the given path with the requested backend. An
+// empty engine name selects DefaultEngine. Opening an existing database with a
+// different backend than the one that created it fails.
+func Open(engine string, file string, cache int, handles int) (Database, error) {
+	if engine == "" {
+		engine = DefaultEngine
+	}
+	enginesLock.RLock()
+	open, ok := engines[engine]
+	enginesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine %q (available: %v)", engine, Engines())
+	}
+	// In-memory databases don't touch the disk, nothing to check
+	if engine == "memory" || file == "" {
+		return open(file, cache, handles)
+	}
+	existing, err := detectEngine(file)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" && existing != engine {
+		return nil, fmt.Errorf("database %s was created by engine %q, can't open it with %q", file, existing, engine)
+	}
+	db, err := open(file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	if existing == "" {
+		if err := ioutil.WriteFile(filepath.Join(file, engineMarker), []byte(engine+"\n"), 0600); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// detectEngine returns the name of the backend that created the database at
+// the given path, or an empty string if there's no database yet. Directories
+// without an engine marker are recognized by the files LevelDB and Badger keep.
+func detectEngine(file string) (string, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(file, engineMarker))
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(blob)), nil
+	case !os.IsNotExist(err):
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(file, "CURRENT")); err == nil {
+		return "leveldb", nil
+	}
+	for _, name := range []string{"MANIFEST", "KEYREGISTRY"} {
+		if _, err := os.Stat(filepath.Join(file, name)); err == nil {
+			return "badger", nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,373 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/go-ethereum-analysis/ethdb"
+)
+
+// Tests that every registered database engine conforms to the semantics the
+// rest of the codebase relies on.
+func TestEngineConformance(t *testing.T) {
+	tests := map[string]func(ethdb.Database, *testing.T){
+		"PutGet":  testConformPutGet,
+		"Has":     testConformHas,
+		"Batch":   testConformBatch,
+		"Iterate": testConformIterate,
+		"Range":   testConformDeleteRange,
+		"Table":   testConformTable,
+	}
+	for _, engine := range ethdb.Engines() {
+		for name, test := range tests {
+			t.Run(engine+"/"+name, func(t *testing.T) {
+				db, remove := newTestEngineDB(t, engine)
+				defer remove()
+				test(db, t)
+			})
+		}
+	}
+}
+
+func TestOpenUnknownEngine(t *testing.T) {
+	if _, err := ethdb.Open("nonexistent", "", 0, 0); err == nil {
+		t.Fatalf("opened unknown engine")
+	}
+}
+
+func newTestEngineDB(t *testing.T, engine string) (ethdb.Database, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "ethdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create test dir: %v", err)
+	}
+	db, err := ethdb.Open(engine, dirname, 0, 0)
+	if err != nil {
+		os.RemoveAll(dirname)
+		t.Fatalf("failed to open %s database: %v", engine, err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+// conformKeys is the test key set. Empty keys are not universally supported.
+var conformKeys = []string{"a", "ab", "abc", "b", "\x00123\x00", "1251"}
+
+func testConformPutGet(db ethdb.Database, t *testing.T) {
+	for _, k := range conformKeys {
+		if _, err := db.Get([]byte(k)); err == nil {
+			t.Fatalf("missing key %q retrieved", k)
+		}
+		if err := db.Put([]byte(k), nil); err != nil {
+			t.Fatalf("empty put %q failed: %v", k, err)
+		}
+		if data, err := db.Get([]byte(k)); err != nil || len(data) != 0 {
+			t.Fatalf("empty get %q mismatch: have %x, %v", k, data, err)
+		}
+	}
+	for _, k := range conformKeys {
+		value := []byte("value-" + k)
+		if err := db.Put([]byte(k), value); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+		// Modifying the inserted or returned slices must not alter the database
+		value[0] = '!'
+		data, err := db.Get([]byte(k))
+		if err != nil || string(data) != "value-"+k {
+			t.Fatalf("get %q mismatch: have %q, %v", k, data, err)
+		}
+		data[0] = '!'
+		if data, _ := db.Get([]byte(k)); string(data) != "value-"+k {
+			t.Fatalf("get %q mutated: have %q", k, data)
+		}
+	}
+	for _, k := range conformKeys {
+		if err := db.Delete([]byte(k)); err != nil {
+			t.Fatalf("delete %q failed: %v", k, err)
+		}
+		if _, err := db.Get([]byte(k)); err == nil {
+			t.Fatalf("deleted key %q retrieved", k)
+		}
+	}
+	// Deleting a missing key is not an error
+	if err := db.Delete([]byte("missing")); err != nil {
+		t.Fatalf("missing key delete failed: %v", err)
+	}
+}
+
+func testConformHas(db ethdb.Database, t *testing.T) {
+	key := []byte("key")
+	if has, err := db.Has(key); err != nil || has {
+		t.Fatalf("missing key reported: %v, %v", has, err)
+	}
+	if err := db.Put(key, []byte("value")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if has, err := db.Has(key); err != nil || !has {
+		t.Fatalf("existing key not reported: %v, %v", has, err)
+	}
+	if err := db.Delete(key); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if has, err := db.Has(key); err != nil || has {
+		t.Fatalf("deleted key reported: %v, %v", has, err)
+	}
+}
+
+func testConformBatch(db ethdb.Database, t *testing.T) {
+	if err := db.Put([]byte("deleted"), []byte("value")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	batch := db.NewBatch()
+	for _, k := range conformKeys {
+		if err := batch.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatalf("batch put %q failed: %v", k, err)
+		}
+	}
+	if err := batch.Delete([]byte("deleted")); err != nil {
+		t.Fatalf("batch delete failed: %v", err)
+	}
+	if batch.ValueSize() == 0 {
+		t.Fatalf("batch size not tracked")
+	}
+	// Nothing may be visible before the batch is written
+	for _, k := range conformKeys {
+		if has, _ := db.Has([]byte(k)); has {
+			t.Fatalf("unwritten batch key %q visible", k)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for _, k := range conformKeys {
+		if data, err := db.Get([]byte(k)); err != nil || string(data) != k {
+			t.Fatalf("batch key %q mismatch: have %q, %v", k, data, err)
+		}
+	}
+	if has, _ := db.Has([]byte("deleted")); has {
+		t.Fatalf("batch deletion not applied")
+	}
+	// A reset batch must be reusable and must not replay old writes
+	batch.Reset()
+	if batch.ValueSize() != 0 {
+		t.Fatalf("reset batch size mismatch: have %d, want 0", batch.ValueSize())
+	}
+	if err := db.Delete([]byte(conformKeys[0])); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := batch.Put([]byte("reused"), []byte("value")); err != nil {
+		t.Fatalf("reused batch put failed: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("reused batch write failed: %v", err)
+	}
+	if has, _ := db.Has([]byte(conformKeys[0])); has {
+		t.Fatalf("reset batch replayed old writes")
+	}
+	if has, _ := db.Has([]byte("reused")); !has {
+		t.Fatalf("reused batch write not applied")
+	}
+}
+
+func testConformIterate(db ethdb.Database, t *testing.T) {
+	for _, k := range conformKeys {
+		if err := db.Put([]byte(k), []byte("value-"+k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		start  string
+		keys   []string
+	}{
+		{"", "", conformKeys},
+		{"", "ab", []string{"ab", "abc", "b"}},
+		{"a", "", []string{"a", "ab", "abc"}},
+		{"a", "b", []string{"ab", "abc"}},
+		{"a", "bb", []string{"abc"}},
+		{"a", "c", nil},
+		{"ab", "", []string{"ab", "abc"}},
+		{"b", "", []string{"b"}},
+		{"c", "", nil},
+	}
+	for _, tt := range tests {
+		it := db.NewIterator([]byte(tt.prefix), []byte(tt.start))
+		have := collectKeys(t, it)
+		for _, k := range have {
+			if data, _ := db.Get([]byte(k)); string(data) != "value-"+k {
+				t.Errorf("prefix %q, start %q: value mismatch for key %q: %q", tt.prefix, tt.start, k, data)
+			}
+		}
+		checkKeys(t, fmt.Sprintf("prefix %q, start %q", tt.prefix, tt.start), have, tt.keys)
+	}
+}
+
+func testConformDeleteRange(db ethdb.Database, t *testing.T) {
+	tests := []struct {
+		start, limit []byte
+		keys         []string
+	}{
+		{[]byte("a"), []byte("abc"), []string{"\x00123\x00", "1251", "abc", "b"}},
+		{[]byte("abc"), nil, []string{"\x00123\x00", "1251", "a", "ab"}},
+		{nil, []byte("a"), []string{"a", "ab", "abc", "b"}},
+		{nil, nil, nil},
+	}
+	for _, tt := range tests {
+		for _, k := range conformKeys {
+			if err := db.Put([]byte(k), []byte(k)); err != nil {
+				t.Fatalf("put %q failed: %v", k, err)
+			}
+		}
+		if err := db.DeleteRange(tt.start, tt.limit); err != nil {
+			t.Fatalf("range %q-%q: delete failed: %v", tt.start, tt.limit, err)
+		}
+		checkKeys(t, fmt.Sprintf("range %q-%q", tt.start, tt.limit), collectKeys(t, db.NewIterator(nil, nil)), tt.keys)
+	}
+	// Compaction must succeed over the whole key space as well as over ranges
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatalf("full compaction failed: %v", err)
+	}
+	if err := db.Compact([]byte("a"), []byte("b")); err != nil {
+		t.Fatalf("range compaction failed: %v", err)
+	}
+}
+
+func testConformTable(db ethdb.Database, t *testing.T) {
+	for _, k := range conformKeys {
+		if err := db.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	table := ethdb.NewTable(db, "a")
+
+	// Table iteration must strip the prefix and stay within the table
+	checkKeys(t, "table", collectKeys(t, table.NewIterator(nil, nil)), []string{"", "b", "bc"})
+	checkKeys(t, "table prefix", collectKeys(t, table.NewIterator([]byte("b"), []byte("c"))), []string{"bc"})
+
+	// Open ended range deletions must not leak out of the table
+	if err := table.DeleteRange([]byte("b"), nil); err != nil {
+		t.Fatalf("table range delete failed: %v", err)
+	}
+	checkKeys(t, "database", collectKeys(t, db.NewIterator(nil, nil)), []string{"\x00123\x00", "1251", "a", "b"})
+}
+
+// collectKeys drains an iterator, returning the keys in iteration order.
+func collectKeys(t *testing.T, it ethdb.Iterator) []string {
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		t.Errorf("iteration failed: %v", err)
+	}
+	return keys
+}
+
+// checkKeys verifies that the iterated keys are the expected ones, in ascending
+// order.
+func checkKeys(t *testing.T, context string, have []string, want []string) {
+	want = append([]string{}, want...)
+	sort.Strings(want)
+
+	if len(have) != len(want) {
+		t.Errorf("%s: key count mismatch: have %q, want %q", context, have, want)
+		return
+	}
+	for i := range have {
+		if have[i] != want[i] {
+			t.Errorf("%s: key order mismatch: have %q, want %q", context, have, want)
+			return
+		}
+	}
+}
+
+// Tests that an existing database can't be opened with a different engine than
+// the one that created it, and that nothing is written to it on the attempt.
+func TestOpenEngineMismatch(t *testing.T) {
+	for _, created := range []string{"leveldb", "badger"} {
+		for _, opened := range []string{"leveldb", "badger"} {
+			if created == opened {
+				continue
+			}
+			dirname, err := ioutil.TempDir(os.TempDir(), "ethdb_test_")
+			if err != nil {
+				t.Fatalf("failed to create test dir: %v", err)
+			}
+			defer os.RemoveAll(dirname)
+
+			db, err := ethdb.Open(created, dirname, 0, 0)
+			if err != nil {
+				t.Fatalf("%s: failed to create database: %v", created, err)
+			}
+			if err := db.Put([]byte("key"), []byte("value")); err != nil {
+				t.Fatalf("%s: failed to store key: %v", created, err)
+			}
+			db.Close()
+
+			before, _ := ioutil.ReadDir(dirname)
+			if db, err := ethdb.Open(opened, dirname, 0, 0); err == nil {
+				db.Close()
+				t.Errorf("%s database opened with %s", created, opened)
+			}
+			if after, _ := ioutil.ReadDir(dirname); len(after) != len(before) {
+				t.Errorf("%s database modified by %s: %d files before, %d after", created, opened, len(before), len(after))
+			}
+			// The original engine still opens it
+			if db, err = ethdb.Open(created, dirname, 0, 0); err != nil {
+				t.Fatalf("%s: failed to reopen database: %v", created, err)
+			}
+			if _, err := db.Get([]byte("key")); err != nil {
+				t.Errorf("%s: stored key lost: %v", created, err)
+			}
+			db.Close()
+		}
+	}
+}
+
+// Tests that LevelDB directories created before engines were recorded are still
+// recognized.
+func TestOpenLegacyLevelDB(t *testing.T) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "ethdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create test dir: %v", err)
+	}
+	defer os.RemoveAll(dirname)
+
+	db, err := ethdb.NewLDBDatabase(dirname, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create leveldb database: %v", err)
+	}
+	db.Close()
+
+	if db, err := ethdb.Open("badger", dirname, 0, 0); err == nil {
+		db.Close()
+		t.Fatalf("legacy leveldb database opened with badger")
+	}
+	db2, err := ethdb.Open("leveldb", dirname, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open legacy leveldb database: %v", err)
+	}
+	db2.Close()
+}
@@ -34,6 +34,10 @@ type Deleter interface {
 type Database interface {
 	Putter
 	Deleter
+	RangeDeleter
+	Iteratee
+	Stater
+	Compacter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
 	Close()
@@ -50,3 +54,62 @@ type Batch interface {
 	// Reset resets the batch for reuse
 	Reset()
 }
+
+// Iterator iterates over a database's key/value pairs in ascending key order.
+// The iterator must be released after use.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair. It returns false if
+	// the iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs
+	// is not considered to be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair, or nil if done.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair, or nil if done.
+	Value() []byte
+
+	// Release releases associated resources.
+	Release()
+}
+
+// Iteratee wraps the NewIterator method of a backing data store.
+type Iteratee interface {
+	// NewIterator creates an iterator over the subset of database content with
+	// a particular key prefix, starting at a particular initial key (or after,
+	// if it does not exist). The start key is relative to the prefix.
+	NewIterator(prefix []byte, start []byte) Iterator
+}
+
+// RangeDeleter wraps the DeleteRange method of a backing data store.
+type RangeDeleter interface {
+	// DeleteRange deletes all the keys in the range [start, limit). A nil start
+	// is treated as a key before all keys, a nil limit as a key after all keys.
+	DeleteRange(start []byte, limit []byte) error
+}
+
+// Stater wraps the Stat method of a backing data store.
+type Stater interface {
+	// Stat returns a particular internal statistic of the database. An empty
+	// property requests the general statistics of the backend.
+	Stat(property string) (string, error)
+}
+
+// Meterer wraps the Meter method of a backing data store that can report its
+// internal statistics to the metrics system.
+type Meterer interface {
+	// Meter configures the database metrics collectors under the given prefix.
+	Meter(prefix string)
+}
+
+// Compacter wraps the Compact method of a backing data store.
+type Compacter interface {
+	// Compact flattens the underlying data store for the given key range,
+	// discarding deleted and overwritten versions and rearranging the data to
+	// reduce the cost of accessing it. A nil start is treated as a key before
+	// all keys, a nil limit as a key after all keys.
+	Compact(start []byte, limit []byte) error
+}
@@ -22,7 +22,6 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"strings"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -42,8 +41,6 @@ import (
 	"github.com/go-ethereum-analysis/params"
 	"github.com/go-ethereum-analysis/rlp"
 	"github.com/go-ethereum-analysis/rpc"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -1017,17 +1014,17 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 }
 
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx), nil
 	}
-	// Transaction unknown, return as such
-	return nil
+	// Transaction unknown, return as such unless it might just not be indexed
+	return nil, txIndexError(s.b)
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1038,13 +1035,26 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	if tx, _, _, _ = rawdb.ReadTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, txIndexError(s.b)
 		}
 	}
 	// Serialize to RLP and return
 	return rlp.EncodeToBytes(tx)
 }
 
+// txIndexError returns an error explaining why a transaction may be missing from
+// the transaction index, or nil if the index covers the entire chain.
+func txIndexError(b Backend) error {
+	progress := b.TxIndexProgress()
+	if !progress.Done() {
+		return fmt.Errorf("transaction indexing is in progress, %d blocks remaining", progress.Remaining)
+	}
+	if progress.Tail > 0 {
+		return fmt.Errorf("transaction not found, only blocks since #%d are indexed", progress.Tail)
+	}
+	return nil
+}
+
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
@@ -1096,6 +1106,33 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	return fields, nil
 }
 
+// GetTransactionStatus returns whether the transaction with the given hash is
+// unknown, queued, pending or already included in the chain. For included
+// transactions the position in the canonical chain is returned too.
+func (s *PublicTransactionPoolAPI) GetTransactionStatus(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	status, lookup, err := s.b.GetTransactionStatus(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"status": txStatusNames[status],
+	}
+	if lookup != nil {
+		fields["blockHash"] = lookup.BlockHash
+		fields["blockNumber"] = hexutil.Uint64(lookup.BlockIndex)
+		fields["transactionIndex"] = hexutil.Uint64(lookup.Index)
+	}
+	return fields, nil
+}
+
+// txStatusNames maps the transaction pool statuses to their RPC representation.
+var txStatusNames = map[core.TxStatus]string{
+	core.TxStatusUnknown:  "unknown",
+	core.TxStatusQueued:   "queued",
+	core.TxStatusPending:  "pending",
+	core.TxStatusIncluded: "included",
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
 func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	// Look up the wallet containing the requested signer
@@ -1465,33 +1502,15 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 	return &PrivateDebugAPI{b: b}
 }
 
-// ChaindbProperty returns leveldb properties of the chain database.
+// ChaindbProperty returns internal properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
-	}
-	if property == "" {
-		property = "leveldb.stats"
-	} else if !strings.HasPrefix(property, "leveldb.") {
-		property = "leveldb." + property
-	}
-	return ldb.LDB().GetProperty(property)
+	return api.b.ChainDb().Stat(property)
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return fmt.Errorf("chaindbCompact does not work for memory databases")
-	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
-		if err != nil {
+		if err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1}); err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
 		}
@@ -1499,9 +1518,12 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+// SetHead rewinds the head of the blockchain to a previous block. If repair is
+// set and the state of the new head is missing, it is regenerated by
+// re-executing the blocks since the nearest available state, instead of
+// rewinding further back.
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64, repair *bool) error {
+	return api.b.SetHead(uint64(number), repair != nil && *repair)
 }
 
 // PublicNetAPI offers network related RPC methods
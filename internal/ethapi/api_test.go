@@ -0,0 +1,86 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/rawdb"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/ethdb"
+)
+
+// txIndexBackend is a Backend serving transactions from a database whose
+// transaction index only covers the blocks since a given tail.
+type txIndexBackend struct {
+	Backend // Not implemented, panics if anything else is used
+
+	db       ethdb.Database
+	progress core.TxIndexProgress
+}
+
+func (b *txIndexBackend) ChainDb() ethdb.Database                           { return b.db }
+func (b *txIndexBackend) GetPoolTransaction(common.Hash) *types.Transaction { return nil }
+func (b *txIndexBackend) TxIndexProgress() core.TxIndexProgress             { return b.progress }
+
+// Tests that transactions missing from a pruned index are reported as such,
+// while indexed ones are still found.
+func TestTransactionIndexError(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var txs []*types.Transaction
+	for i := 0; i < 4; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{0x11}, big.NewInt(111), 1111, big.NewInt(11111), nil)
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, []*types.Transaction{tx}, nil, nil)
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		txs = append(txs, tx)
+	}
+	// Index only the last two blocks
+	rawdb.IndexTransactions(db, 2, 4, nil)
+	rawdb.WriteTxIndexTail(db, 2)
+
+	api := NewPublicTransactionPoolAPI(&txIndexBackend{db: db, progress: core.TxIndexProgress{Limit: 2, Tail: 2}}, nil)
+
+	// Indexed transactions must be found
+	if tx, err := api.GetTransactionByHash(context.Background(), txs[3].Hash()); err != nil || tx == nil {
+		t.Fatalf("indexed transaction: have %v, %v, want transaction", tx, err)
+	}
+	// Transactions below the tail must be reported as unindexed
+	if tx, err := api.GetTransactionByHash(context.Background(), txs[0].Hash()); err == nil {
+		t.Fatalf("unindexed transaction: have %v, want error", tx)
+	}
+	if tx, err := api.GetRawTransactionByHash(context.Background(), txs[1].Hash()); err == nil {
+		t.Fatalf("unindexed raw transaction: have %x, want error", tx)
+	}
+	// Unknown transactions might be in the unindexed range, they must be reported too
+	if tx, err := api.GetTransactionByHash(context.Background(), common.Hash{0xff}); err == nil {
+		t.Fatalf("unknown transaction: have %v, want error", tx)
+	}
+	if tx, err := api.GetRawTransactionByHash(context.Background(), common.Hash{0xff}); err == nil {
+		t.Fatalf("unknown raw transaction: have %x, want error", tx)
+	}
+	// Without pruning, unknown transactions are not found without an error
+	api = NewPublicTransactionPoolAPI(&txIndexBackend{db: db}, nil)
+	if tx, err := api.GetTransactionByHash(context.Background(), common.Hash{0xff}); err != nil || tx != nil {
+		t.Fatalf("unknown transaction without pruning: have %v, %v, want nil, nil", tx, err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/go-ethereum-analysis/accounts"
 	"github.com/go-ethereum-analysis/common"
 	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/rawdb"
 	"github.com/go-ethereum-analysis/core/state"
 	"github.com/go-ethereum-analysis/core/types"
 	"github.com/go-ethereum-analysis/core/vm"
@@ -46,13 +47,14 @@ type Backend interface {
 	AccountManager() *accounts.Manager
 
 	// BlockChain API
-	SetHead(number uint64)
+	SetHead(number uint64, repair bool) error
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetTd(blockHash common.Hash) *big.Int
+	TxIndexProgress() core.TxIndexProgress
 	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error)
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
@@ -62,6 +64,7 @@ type Backend interface {
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
 	GetPoolTransactions() (types.Transactions, error)
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
+	GetTransactionStatus(ctx context.Context, txHash common.Hash) (core.TxStatus, *rawdb.TxLookupEntry, error)
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
@@ -0,0 +1,136 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/common/hexutil"
+	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/p2p/discover"
+)
+
+var (
+	errNoCheckpoint        = errors.New("no local checkpoint provided")
+	errNotActivated        = errors.New("checkpoint oracle is not activated")
+	errUnknownSectionIndex = errors.New("unknown section index")
+)
+
+// PrivateLightServerAPI provides an API to access the LES light server, such as
+// retrieving the locally generated checkpoints for the oracle signers.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new LES light server API.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server: server}
+}
+
+// LatestCheckpoint returns the latest checkpoint generated by the local node.
+func (api *PrivateLightServerAPI) LatestCheckpoint() (*light.TrustedCheckpoint, error) {
+	sections := api.server.checkpointSections()
+	if sections == 0 {
+		return nil, errNoCheckpoint
+	}
+	checkpoint := api.server.getLocalCheckpoint(sections - 1)
+	return &checkpoint, nil
+}
+
+// GetCheckpoint returns the checkpoint of the given section generated by the
+// local node.
+func (api *PrivateLightServerAPI) GetCheckpoint(index uint64) (*light.TrustedCheckpoint, error) {
+	if index >= api.server.checkpointSections() {
+		return nil, errUnknownSectionIndex
+	}
+	checkpoint := api.server.getLocalCheckpoint(index)
+	return &checkpoint, nil
+}
+
+// GetCheckpointContractAddress returns the address of the checkpoint oracle
+// contract the node announces checkpoints from.
+func (api *PrivateLightServerAPI) GetCheckpointContractAddress() (common.Address, error) {
+	if api.server.oracle == nil {
+		return common.Address{}, errNotActivated
+	}
+	return api.server.oracle.config.Address, nil
+}
+
+// ClientInfo is the state of a client known by the client pool.
+type ClientInfo struct {
+	Capacity   hexutil.Uint64 `json:"capacity"`
+	Priority   bool           `json:"priority"`
+	Connected  bool           `json:"connected"`
+	PosBalance hexutil.Uint64 `json:"posBalance"`
+	NegBalance hexutil.Uint64 `json:"negBalance"`
+}
+
+func newClientInfo(info clientPoolInfo) ClientInfo {
+	return ClientInfo{
+		Capacity:   hexutil.Uint64(info.capacity),
+		Priority:   info.priority,
+		Connected:  info.connected,
+		PosBalance: hexutil.Uint64(info.posBalance),
+		NegBalance: hexutil.Uint64(info.negBalance),
+	}
+}
+
+// TotalCapacity returns the total capacity available for serving clients.
+func (api *PrivateLightServerAPI) TotalCapacity() hexutil.Uint64 {
+	total, _ := api.server.protocolManager.clientPool.capacities()
+	return hexutil.Uint64(total)
+}
+
+// PriorityCapacity returns the capacity used by the connected priority clients.
+func (api *PrivateLightServerAPI) PriorityCapacity() hexutil.Uint64 {
+	_, priority := api.server.protocolManager.clientPool.capacities()
+	return hexutil.Uint64(priority)
+}
+
+// FreeClientCapacity returns the capacity assigned to free clients.
+func (api *PrivateLightServerAPI) FreeClientCapacity() hexutil.Uint64 {
+	return hexutil.Uint64(api.server.defParams.MinRecharge)
+}
+
+// ClientInfo returns the state of the given client.
+func (api *PrivateLightServerAPI) ClientInfo(id discover.NodeID) ClientInfo {
+	return newClientInfo(api.server.protocolManager.clientPool.info(id))
+}
+
+// ClientList returns the state of all clients known by the client pool.
+func (api *PrivateLightServerAPI) ClientList() map[discover.NodeID]ClientInfo {
+	infos := make(map[discover.NodeID]ClientInfo)
+	for id, info := range api.server.protocolManager.clientPool.infoList() {
+		infos[id] = newClientInfo(info)
+	}
+	return infos
+}
+
+// SetClientCapacity assigns a capacity to the given client, serving it as a
+// priority client while it has a positive balance. Zero capacity turns the
+// client into a free one.
+func (api *PrivateLightServerAPI) SetClientCapacity(id discover.NodeID, capacity hexutil.Uint64) error {
+	return api.server.protocolManager.clientPool.setCapacity(id, uint64(capacity))
+}
+
+// AddBalance adds the given amount to the positive balance of a client and
+// returns the updated balance.
+func (api *PrivateLightServerAPI) AddBalance(id discover.NodeID, amount hexutil.Uint64) (hexutil.Uint64, error) {
+	balance, err := api.server.protocolManager.clientPool.addBalance(id, uint64(amount))
+	return hexutil.Uint64(balance), err
+}
@@ -22,6 +22,7 @@ package les
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/go-ethereum-analysis/accounts"
@@ -57,9 +58,13 @@ func (b *LesApiBackend) CurrentBlock() *types.Block {
 	return types.NewBlockWithHeader(b.eth.BlockChain().CurrentHeader())
 }
 
-func (b *LesApiBackend) SetHead(number uint64) {
+func (b *LesApiBackend) SetHead(number uint64, repair bool) error {
+	if repair {
+		return errors.New("state repair not supported in light mode")
+	}
 	b.eth.protocolManager.downloader.Cancel()
 	b.eth.blockchain.SetHead(number)
+	return nil
 }
 
 
@@ -115,6 +120,12 @@ func (b *LesApiBackend) GetTd(hash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(hash)
 }
 
+// TxIndexProgress reports a complete index, light clients only index the
+// transactions they have sent themselves.
+func (b *LesApiBackend) TxIndexProgress() core.TxIndexProgress {
+	return core.TxIndexProgress{}
+}
+
 func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
 	state.SetBalance(msg.From(), math.MaxBig256)
 	context := core.NewEVMContext(msg, header, b.eth.blockchain, nil)
@@ -137,6 +148,15 @@ func (b *LesApiBackend) GetPoolTransaction(txHash common.Hash) *types.Transactio
 	return b.eth.txPool.GetTransaction(txHash)
 }
 
+func (b *LesApiBackend) GetTransactionStatus(ctx context.Context, txHash common.Hash) (core.TxStatus, *rawdb.TxLookupEntry, error) {
+	// 并发询问多个 server, 以多数派的答复为准
+	status, err := light.GetTransactionStatus(ctx, b.eth.odr, []common.Hash{txHash})
+	if err != nil {
+		return core.TxStatusUnknown, nil, err
+	}
+	return status[0].Status, status[0].Lookup, nil
+}
+
 func (b *LesApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.eth.txPool.GetNonce(ctx, addr)
 }
@@ -200,6 +200,12 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, leth.serverPool, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
+	// Verify the checkpoints announced by servers against the oracle, if configured
+	leth.oracle = newCheckpointOracle(config.CheckpointOracle, leth.getLocalCheckpoint)
+	leth.protocolManager.oracle = leth.oracle
+
+	// Accept the heads signed by the trusted servers in ultra light client mode
+	leth.protocolManager.ulc = newULC(config.ULC)
 
 	// light api backend
 	leth.ApiBackend = &LesApiBackend{leth, nil}
@@ -316,6 +322,13 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	// todo 启动 当前Client 中记录的 Serverpool
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
 
+	// Keep connected to the trusted servers in ultra light client mode
+	if s.protocolManager.ulc != nil {
+		for _, node := range s.protocolManager.ulc.servers {
+			srvr.AddPeer(node)
+		}
+	}
+
 	// todo 启动轻节点的 Client 端
 	s.protocolManager.Start(s.config.LightPeers)
 	return nil
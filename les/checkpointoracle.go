@@ -0,0 +1,115 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"github.com/go-ethereum-analysis/accounts/abi/bind"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle"
+	"github.com/go-ethereum-analysis/eth"
+	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/log"
+	"github.com/go-ethereum-analysis/params"
+)
+
+// checkpointOracle is responsible for offering the latest stable checkpoint
+// registered in the on-chain checkpoint oracle by its trusted signers. Servers
+// announce it to their clients during the handshake, while clients verify the
+// announced checkpoints against the signers before syncing from them.
+type checkpointOracle struct {
+	config   *params.CheckpointOracleConfig
+	contract *checkpointoracle.CheckpointOracle // Oracle contract binding, nil on clients
+
+	getLocal func(uint64) light.TrustedCheckpoint // Retrieves the local checkpoint of a section
+}
+
+// newCheckpointOracle creates a checkpoint oracle handler for the given config,
+// returning nil if no oracle is configured.
+func newCheckpointOracle(config *params.CheckpointOracleConfig, getLocal func(uint64) light.TrustedCheckpoint) *checkpointOracle {
+	if config == nil {
+		log.Info("Checkpoint oracle is not enabled")
+		return nil
+	}
+	if config.Threshold < 1 || uint64(len(config.Signers)) < config.Threshold {
+		log.Warn("Invalid checkpoint oracle config", "signers", len(config.Signers), "threshold", config.Threshold)
+		return nil
+	}
+	log.Info("Configured checkpoint oracle", "address", config.Address, "signers", len(config.Signers), "threshold", config.Threshold)
+	return &checkpointOracle{
+		config:   config,
+		getLocal: getLocal,
+	}
+}
+
+// start binds the oracle contract, allowing the local node to look up the
+// registered checkpoints.
+func (oracle *checkpointOracle) start(backend bind.ContractBackend) {
+	contract, err := checkpointoracle.NewCheckpointOracle(oracle.config.Address, backend)
+	if err != nil {
+		log.Error("Failed to bind checkpoint oracle", "err", err)
+		return
+	}
+	oracle.contract = contract
+}
+
+// newContractBackend creates a contract backend running against the local chain
+// of a full node.
+func newContractBackend(e *eth.Ethereum) bind.ContractBackend {
+	return eth.NewContractBackend(e.APIBackend)
+}
+
+// stableCheckpoint returns the latest checkpoint registered in the oracle along
+// with the signatures approving it, or nil if there is none or if it doesn't
+// match the locally generated one.
+func (oracle *checkpointOracle) stableCheckpoint() (*light.TrustedCheckpoint, [][]byte) {
+	if oracle.contract == nil {
+		return nil, nil
+	}
+	index, hash, height, err := oracle.contract.LatestCheckpoint(nil)
+	if err != nil {
+		log.Debug("Failed to retrieve latest checkpoint", "err", err)
+		return nil, nil
+	}
+	if height.Sign() == 0 {
+		return nil, nil
+	}
+	checkpoint := oracle.getLocal(index)
+	if checkpoint.Hash() != hash {
+		log.Debug("Registered checkpoint mismatch", "section", index, "have", checkpoint.Hash(), "want", hash)
+		return nil, nil
+	}
+	number := height.Uint64()
+	sigs, err := oracle.contract.CheckpointSignatures(&bind.FilterOpts{Start: number, End: &number}, index, hash)
+	if err != nil {
+		log.Debug("Failed to retrieve checkpoint signatures", "err", err)
+		return nil, nil
+	}
+	return &checkpoint, sigs
+}
+
+// verifyCheckpoint checks that a checkpoint was approved by enough trusted signers.
+func (oracle *checkpointOracle) verifyCheckpoint(checkpoint *light.TrustedCheckpoint, sigs [][]byte) bool {
+	if checkpoint.Empty() {
+		return false
+	}
+	ok, signers := checkpointoracle.VerifySigners(oracle.config, checkpoint.SectionIdx, checkpoint.Hash(), sigs)
+	if !ok {
+		log.Warn("Not enough signers to approve checkpoint", "section", checkpoint.SectionIdx, "threshold", oracle.config.Threshold)
+		return false
+	}
+	log.Debug("Verified checkpoint", "section", checkpoint.SectionIdx, "signers", len(signers))
+	return true
+}
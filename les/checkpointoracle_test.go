@@ -0,0 +1,88 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/contracts/checkpointoracle"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/params"
+)
+
+// signCheckpoint creates the oracle signatures of the given keys approving a
+// checkpoint.
+func signCheckpoint(oracle common.Address, checkpoint *light.TrustedCheckpoint, keys ...*ecdsa.PrivateKey) [][]byte {
+	var sigs [][]byte
+	for _, key := range keys {
+		sig, _ := crypto.Sign(checkpointoracle.SignatureHash(oracle, checkpoint.SectionIdx, checkpoint.Hash()).Bytes(), key)
+		sig[64] += 27
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// Tests that announced checkpoints are only accepted if enough trusted signers
+// approved them for the configured oracle.
+func TestCheckpointOracleVerify(t *testing.T) {
+	var (
+		keys    []*ecdsa.PrivateKey
+		signers []common.Address
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		signers = append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	stranger, _ := crypto.GenerateKey()
+
+	config := &params.CheckpointOracleConfig{
+		Address:   common.HexToAddress("0x0123456789abcdef0123456789abcdef01234567"),
+		Signers:   signers,
+		Threshold: 2,
+	}
+	oracle := newCheckpointOracle(config, nil)
+
+	checkpoint := &light.TrustedCheckpoint{
+		SectionIdx:  1,
+		SectionHead: common.HexToHash("0x01"),
+		CHTRoot:     common.HexToHash("0x02"),
+		BloomRoot:   common.HexToHash("0x03"),
+	}
+	tests := []struct {
+		sigs [][]byte
+		want bool
+	}{
+		{signCheckpoint(config.Address, checkpoint, keys[0], keys[2]), true},
+		{signCheckpoint(config.Address, checkpoint, keys...), true},
+		{signCheckpoint(config.Address, checkpoint, keys[1]), false},
+		{signCheckpoint(config.Address, checkpoint, keys[1], keys[1]), false},
+		{signCheckpoint(config.Address, checkpoint, keys[1], stranger), false},
+		{signCheckpoint(common.Address{}, checkpoint, keys...), false},
+	}
+	for i, tt := range tests {
+		if have := oracle.verifyCheckpoint(checkpoint, tt.sigs); have != tt.want {
+			t.Errorf("test %d: verification mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	if oracle.verifyCheckpoint(&light.TrustedCheckpoint{SectionIdx: 1}, signCheckpoint(config.Address, &light.TrustedCheckpoint{SectionIdx: 1}, keys...)) {
+		t.Errorf("empty checkpoint accepted")
+	}
+}
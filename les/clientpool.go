@@ -0,0 +1,456 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-ethereum-analysis/common/mclock"
+	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/log"
+	"github.com/go-ethereum-analysis/p2p/discover"
+	"github.com/go-ethereum-analysis/rlp"
+)
+
+const (
+	negBalanceExpTC  = time.Hour   // time constant of the exponential decay of negative balances
+	maxClientEntries = 10000       // maximum number of client records kept in memory
+	persistCycle     = time.Minute // maximum time balance charges are kept in memory only
+)
+
+var (
+	errPoolClosed         = errors.New("client pool is closed")
+	errNoPriorityCapacity = errors.New("not enough free capacity for the priority client")
+)
+
+// poolPeer is the interface of a connected client required by the client pool.
+type poolPeer interface {
+	ID() discover.NodeID
+	freeClientId() string
+	updateCapacity(uint64)
+}
+
+// clientPool implements a client database that serves priority clients with a
+// guaranteed capacity assigned by the node operator, while the remaining
+// capacity is shared among free clients using a freeClientPool.
+//
+// Every client has a positive and a negative balance. Requests served to a
+// priority client are charged from its positive balance; once it runs out, the
+// client is downgraded to a free one. Requests served for free increase the
+// negative balance which slowly decays over time.
+type clientPool struct {
+	db     ethdb.Database
+	lock   sync.Mutex
+	clock  mclock.Clock
+	closed bool
+
+	freePool      *freeClientPool
+	freeClientCap uint64 // capacity assigned to free clients
+	totalCap      uint64 // total capacity available for all connected clients
+	priorityCap   uint64 // sum capacity of the connected priority clients
+
+	clients   map[discover.NodeID]*clientInfo
+	lastSaved mclock.AbsTime // time the client records were last written to the database
+}
+
+// clientInfo represents a client known by the pool, either because it is
+// connected or because it has an assigned capacity or a balance.
+type clientInfo struct {
+	id       discover.NodeID
+	capacity uint64 // capacity assigned by the operator, zero for free clients
+
+	posBalance uint64
+	negBalance uint64
+	negTime    mclock.AbsTime // time of the last negative balance update
+
+	peer         poolPeer // nil if not connected
+	disconnectFn func()
+	priority     bool // connected as a priority client
+	inFreePool   bool // connected as a free client
+}
+
+// newClientPool creates a new client pool with the given total capacity. The
+// connection limit of the free client pool is always the number of free
+// clients fitting in the capacity not used by the priority clients.
+func newClientPool(db ethdb.Database, freeClientCap, totalCap uint64, clock mclock.Clock) *clientPool {
+	pool := &clientPool{
+		db:            db,
+		clock:         clock,
+		freePool:      newFreeClientPool(db, int(totalCap/freeClientCap), 10000, clock),
+		freeClientCap: freeClientCap,
+		totalCap:      totalCap,
+		clients:       make(map[discover.NodeID]*clientInfo),
+	}
+	pool.loadFromDb()
+	pool.lastSaved = clock.Now()
+	return pool
+}
+
+// stop shuts the client pool down and saves the assigned capacities and
+// positive balances.
+func (pool *clientPool) stop() {
+	pool.lock.Lock()
+	pool.closed = true
+	pool.saveToDb()
+	pool.lock.Unlock()
+	pool.freePool.stop()
+}
+
+// connect should be called after a successful handshake. Clients with an
+// assigned capacity and a positive balance are accepted as priority clients
+// if their capacity fits, all other clients are passed to the free pool.
+// Note: the disconnectFn callback should not block.
+func (pool *clientPool) connect(p poolPeer, disconnectFn func()) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return false
+	}
+	c := pool.clients[p.ID()]
+	if c == nil {
+		c = &clientInfo{id: p.ID()}
+		pool.clients[c.id] = c
+	} else if c.peer != nil {
+		log.Debug("Client already connected", "id", c.id)
+		return false
+	}
+	c.peer, c.disconnectFn = p, disconnectFn
+	if pool.setPriority(c) == nil {
+		log.Debug("Priority client accepted", "id", c.id, "capacity", c.capacity)
+		return true
+	}
+	if !pool.freePool.connect(p.freeClientId(), disconnectFn) {
+		c.peer, c.disconnectFn = nil, nil
+		pool.prune(c)
+		return false
+	}
+	c.inFreePool = true
+	return true
+}
+
+// disconnect should be called when a connection is terminated. If the
+// disconnection was initiated by the pool itself using disconnectFn then
+// calling disconnect is not necessary but permitted.
+func (pool *clientPool) disconnect(p poolPeer) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return
+	}
+	c := pool.clients[p.ID()]
+	if c == nil || c.peer != p {
+		return
+	}
+	switch {
+	case c.priority:
+		pool.dropPriority(c)
+	case c.inFreePool:
+		pool.freePool.disconnect(p.freeClientId())
+		c.inFreePool = false
+	}
+	c.peer, c.disconnectFn = nil, nil
+	pool.prune(c)
+	log.Debug("Client disconnected", "id", c.id)
+}
+
+// requestCost charges the cost of a served request to the client, from its
+// positive balance if it's a priority client and to its negative balance
+// otherwise.
+func (pool *clientPool) requestCost(p poolPeer, cost uint64) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	c := pool.clients[p.ID()]
+	if c == nil || c.peer != p {
+		return
+	}
+	// Charges are frequent, only persist the drained balances periodically
+	defer func() {
+		if time.Duration(pool.clock.Now()-pool.lastSaved) >= persistCycle {
+			pool.saveToDb()
+		}
+	}()
+	if c.priority {
+		if c.posBalance > cost {
+			c.posBalance -= cost
+			return
+		}
+		cost -= c.posBalance
+		c.posBalance = 0
+
+		log.Debug("Priority client balance exhausted", "id", c.id)
+		pool.dropPriority(c)
+		pool.connectFree(c)
+		pool.saveToDb()
+	}
+	c.negBalance = pool.negBalance(c) + cost
+	c.negTime = pool.clock.Now()
+}
+
+// setCapacity assigns a capacity to the given client, making it a priority
+// client while it has a positive balance. A zero capacity turns the client
+// into a free one.
+func (pool *clientPool) setCapacity(id discover.NodeID, capacity uint64) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return errPoolClosed
+	}
+	c := pool.clients[id]
+	if c == nil {
+		c = &clientInfo{id: id}
+		pool.clients[id] = c
+	}
+	if c.priority {
+		if capacity != 0 && pool.priorityCap-c.capacity+capacity > pool.totalCap {
+			return errNoPriorityCapacity
+		}
+		pool.dropPriority(c)
+	}
+	c.capacity = capacity
+	pool.saveToDb()
+
+	if c.peer == nil {
+		pool.prune(c)
+		return nil
+	}
+	if capacity == 0 || c.posBalance == 0 {
+		if !c.inFreePool {
+			pool.connectFree(c)
+		}
+		return nil
+	}
+	return pool.upgrade(c)
+}
+
+// addBalance adds the given amount to the positive balance of a client,
+// returning the updated balance. Connected clients with an assigned capacity
+// are upgraded to priority clients if possible.
+func (pool *clientPool) addBalance(id discover.NodeID, amount uint64) (uint64, error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.closed {
+		return 0, errPoolClosed
+	}
+	c := pool.clients[id]
+	if c == nil {
+		c = &clientInfo{id: id}
+		pool.clients[id] = c
+	}
+	if c.posBalance+amount < c.posBalance {
+		c.posBalance = math.MaxUint64
+	} else {
+		c.posBalance += amount
+	}
+	pool.saveToDb()
+
+	if c.peer != nil && !c.priority && c.capacity != 0 {
+		if err := pool.upgrade(c); err != nil {
+			log.Debug("Failed to upgrade client", "id", id, "err", err)
+		}
+	}
+	return c.posBalance, nil
+}
+
+// upgrade moves a connected free client to the priority clients.
+func (pool *clientPool) upgrade(c *clientInfo) error {
+	if pool.priorityCap+c.capacity > pool.totalCap {
+		return errNoPriorityCapacity
+	}
+	if c.inFreePool {
+		pool.freePool.disconnect(c.peer.freeClientId())
+		c.inFreePool = false
+	}
+	return pool.setPriority(c)
+}
+
+// setPriority marks a connected client as a priority client if it is eligible
+// and its capacity fits into the total capacity.
+func (pool *clientPool) setPriority(c *clientInfo) error {
+	if c.capacity == 0 || c.posBalance == 0 {
+		return errNoPriorityCapacity
+	}
+	if pool.priorityCap+c.capacity > pool.totalCap {
+		return errNoPriorityCapacity
+	}
+	c.priority = true
+	pool.priorityCap += c.capacity
+	pool.updateFreeLimit()
+	c.peer.updateCapacity(c.capacity)
+	return nil
+}
+
+// dropPriority releases the capacity of a connected priority client.
+func (pool *clientPool) dropPriority(c *clientInfo) {
+	c.priority = false
+	pool.priorityCap -= c.capacity
+	pool.updateFreeLimit()
+}
+
+// connectFree moves a connected client to the free client pool, disconnecting
+// it if the free pool rejects it.
+func (pool *clientPool) connectFree(c *clientInfo) {
+	if pool.freePool.connect(c.peer.freeClientId(), c.disconnectFn) {
+		c.inFreePool = true
+		c.peer.updateCapacity(pool.freeClientCap)
+		return
+	}
+	log.Debug("Downgraded client rejected", "id", c.id)
+	c.disconnectFn()
+}
+
+// updateFreeLimit recalculates the connection limit of the free client pool
+// after the capacity used by the priority clients has changed.
+func (pool *clientPool) updateFreeLimit() {
+	pool.freePool.setConnectedLimit(int((pool.totalCap - pool.priorityCap) / pool.freeClientCap))
+}
+
+// negBalance returns the current negative balance of a client, applying the
+// exponential decay since the last update.
+func (pool *clientPool) negBalance(c *clientInfo) uint64 {
+	if c.negBalance == 0 {
+		return 0
+	}
+	dt := time.Duration(pool.clock.Now() - c.negTime)
+	return uint64(float64(c.negBalance) * math.Exp(-float64(dt)/float64(negBalanceExpTC)))
+}
+
+// prune removes the record of a disconnected client if there's nothing worth
+// remembering about it. Free clients are kept for the sake of their negative
+// balance until the number of records grows too large.
+func (pool *clientPool) prune(c *clientInfo) {
+	if c.peer != nil || c.capacity != 0 || c.posBalance != 0 {
+		return
+	}
+	if pool.negBalance(c) == 0 || len(pool.clients) > maxClientEntries {
+		delete(pool.clients, c.id)
+	}
+}
+
+// clientPoolInfo is the state of a client known by the pool.
+type clientPoolInfo struct {
+	capacity   uint64
+	priority   bool
+	connected  bool
+	posBalance uint64
+	negBalance uint64
+}
+
+// info returns the current state of a client.
+func (pool *clientPool) info(id discover.NodeID) clientPoolInfo {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	c := pool.clients[id]
+	if c == nil {
+		return clientPoolInfo{}
+	}
+	return pool.clientInfo(c)
+}
+
+// infoList returns the state of all clients known by the pool.
+func (pool *clientPool) infoList() map[discover.NodeID]clientPoolInfo {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	infos := make(map[discover.NodeID]clientPoolInfo, len(pool.clients))
+	for id, c := range pool.clients {
+		infos[id] = pool.clientInfo(c)
+	}
+	return infos
+}
+
+func (pool *clientPool) clientInfo(c *clientInfo) clientPoolInfo {
+	capacity := pool.freeClientCap
+	if c.priority {
+		capacity = c.capacity
+	}
+	return clientPoolInfo{
+		capacity:   capacity,
+		priority:   c.priority,
+		connected:  c.peer != nil,
+		posBalance: c.posBalance,
+		negBalance: pool.negBalance(c),
+	}
+}
+
+// capacities returns the total capacity of the pool and the capacity used by
+// the connected priority clients.
+func (pool *clientPool) capacities() (total, priority uint64) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	return pool.totalCap, pool.priorityCap
+}
+
+// clientPoolStorage is the RLP representation of the pool's database storage
+type clientPoolStorage struct {
+	List []clientPoolEntry
+}
+
+// clientPoolEntry is the stored record of a client with an assigned capacity
+// or a positive balance.
+type clientPoolEntry struct {
+	ID         discover.NodeID
+	Capacity   uint64
+	PosBalance uint64
+}
+
+// loadFromDb restores the assigned capacities and positive balances from the
+// database storage (automatically called at initialization)
+func (pool *clientPool) loadFromDb() {
+	enc, err := pool.db.Get([]byte("clientPool"))
+	if err != nil {
+		return
+	}
+	var storage clientPoolStorage
+	if err := rlp.DecodeBytes(enc, &storage); err != nil {
+		log.Error("Failed to decode client list", "err", err)
+		return
+	}
+	for _, e := range storage.List {
+		log.Debug("Loaded priority client record", "id", e.ID, "capacity", e.Capacity, "balance", e.PosBalance)
+		pool.clients[e.ID] = &clientInfo{id: e.ID, capacity: e.Capacity, posBalance: e.PosBalance}
+	}
+}
+
+// saveToDb saves the assigned capacities and positive balances to the
+// database storage (called whenever they are changed by the operator, at most
+// persistCycle after balance charges and during shutdown)
+func (pool *clientPool) saveToDb() {
+	pool.lastSaved = pool.clock.Now()
+
+	var storage clientPoolStorage
+	for _, c := range pool.clients {
+		if c.capacity != 0 || c.posBalance != 0 {
+			storage.List = append(storage.List, clientPoolEntry{ID: c.id, Capacity: c.capacity, PosBalance: c.posBalance})
+		}
+	}
+	enc, err := rlp.EncodeToBytes(storage)
+	if err != nil {
+		log.Error("Failed to encode client list", "err", err)
+	} else {
+		pool.db.Put([]byte("clientPool"), enc)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common/mclock"
+	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/les/flowcontrol"
+	"github.com/go-ethereum-analysis/p2p"
+	"github.com/go-ethereum-analysis/p2p/discover"
+)
+
+// poolTestPeer is a client connected to the client pool in tests.
+type poolTestPeer struct {
+	id           discover.NodeID
+	capacity     uint64
+	disconnected bool
+}
+
+func newPoolTestPeer(i int, freeCap uint64) *poolTestPeer {
+	p := &poolTestPeer{capacity: freeCap}
+	p.id[0], p.id[1] = byte(i>>8), byte(i)
+	return p
+}
+
+func (p *poolTestPeer) ID() discover.NodeID           { return p.id }
+func (p *poolTestPeer) freeClientId() string          { return fmt.Sprintf("addr #%x", p.id[:2]) }
+func (p *poolTestPeer) updateCapacity(cap uint64)     { p.capacity = cap }
+func (p *poolTestPeer) disconnectFn() func()          { return func() { p.disconnected = true } }
+func (p *poolTestPeer) connect(pool *clientPool) bool { return pool.connect(p, p.disconnectFn()) }
+
+const (
+	testFreeCap  = 10
+	testTotalCap = 100
+)
+
+// Tests that priority clients are accepted over free ones and that their
+// capacity is reserved until their balance runs out.
+func TestClientPoolPriority(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		db    = ethdb.NewMemDatabase()
+		pool  = newClientPool(db, testFreeCap, testTotalCap, &clock)
+		peers []*poolTestPeer
+	)
+	for i := 0; i < testTotalCap/testFreeCap; i++ {
+		p := newPoolTestPeer(i, testFreeCap)
+		if !p.connect(pool) {
+			t.Fatalf("free peer #%d rejected", i)
+		}
+		peers = append(peers, p)
+		clock.Run(1)
+	}
+	// Without capacity or balance a new client is a free one and gets rejected
+	priority := newPoolTestPeer(1000, testFreeCap)
+	if priority.connect(pool) {
+		t.Fatalf("free peer accepted over the connection limit")
+	}
+	// With both capacity and balance set the client is accepted, kicking out free ones
+	if err := pool.setCapacity(priority.id, 30); err != nil {
+		t.Fatalf("failed to set capacity: %v", err)
+	}
+	if priority.connect(pool) {
+		t.Fatalf("priority peer accepted without balance")
+	}
+	if _, err := pool.addBalance(priority.id, 1000); err != nil {
+		t.Fatalf("failed to add balance: %v", err)
+	}
+	if !priority.connect(pool) {
+		t.Fatalf("priority peer rejected")
+	}
+	if priority.capacity != 30 {
+		t.Errorf("priority capacity mismatch: have %d, want %d", priority.capacity, 30)
+	}
+	kicked := 0
+	for _, p := range peers {
+		if p.disconnected {
+			pool.disconnect(p)
+			kicked++
+		}
+	}
+	if kicked != 3 {
+		t.Errorf("kicked free peer count mismatch: have %d, want %d", kicked, 3)
+	}
+	if _, used := pool.capacities(); used != 30 {
+		t.Errorf("priority capacity sum mismatch: have %d, want %d", used, 30)
+	}
+	// Capacities exceeding the total are refused
+	if err := pool.setCapacity(priority.id, testTotalCap+1); err != errNoPriorityCapacity {
+		t.Errorf("oversized capacity error mismatch: have %v, want %v", err, errNoPriorityCapacity)
+	}
+	// Served requests are charged from the balance, downgrading the client once exhausted
+	pool.requestCost(priority, 400)
+	if info := pool.info(priority.id); !info.priority || info.posBalance != 600 {
+		t.Fatalf("client state mismatch after charging: priority %v, balance %d", info.priority, info.posBalance)
+	}
+	pool.requestCost(priority, 700)
+	info := pool.info(priority.id)
+	if info.priority || info.posBalance != 0 || info.negBalance != 100 {
+		t.Fatalf("client state mismatch after exhausting: priority %v, balance %d/%d", info.priority, info.posBalance, info.negBalance)
+	}
+	if priority.disconnected || priority.capacity != testFreeCap {
+		t.Errorf("downgraded client mismatch: disconnected %v, capacity %d", priority.disconnected, priority.capacity)
+	}
+	// Negative balances decay over time
+	clock.Run(negBalanceExpTC)
+	if info := pool.info(priority.id); info.negBalance != 36 {
+		t.Errorf("decayed negative balance mismatch: have %d, want %d", info.negBalance, 36)
+	}
+	// Topping the balance up restores the priority of the connected client
+	if _, err := pool.addBalance(priority.id, 500); err != nil {
+		t.Fatalf("failed to add balance: %v", err)
+	}
+	if info := pool.info(priority.id); !info.priority || priority.capacity != 30 {
+		t.Errorf("client not upgraded: priority %v, capacity %d", info.priority, priority.capacity)
+	}
+	// Assigned capacities and balances survive a restart
+	pool.disconnect(priority)
+	pool.stop()
+
+	pool = newClientPool(db, testFreeCap, testTotalCap, &clock)
+	defer pool.stop()
+
+	if info := pool.info(priority.id); info.posBalance != 500 || info.priority || info.connected {
+		t.Errorf("restored client mismatch: balance %d, priority %v, connected %v", info.posBalance, info.priority, info.connected)
+	}
+	priority.capacity = testFreeCap
+	if !priority.connect(pool) || priority.capacity != 30 {
+		t.Errorf("restored priority client mismatch: capacity %d", priority.capacity)
+	}
+}
+
+// Tests that balances are persisted while the pool is running, so that they
+// survive a crash without an orderly shutdown.
+func TestClientPoolPersistence(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		db    = ethdb.NewMemDatabase()
+		pool  = newClientPool(db, testFreeCap, testTotalCap, &clock)
+		peer  = newPoolTestPeer(1, testFreeCap)
+	)
+	defer pool.stop()
+
+	// stored returns the balance a pool restarted after a crash would see
+	stored := func() uint64 {
+		return newClientPool(db, testFreeCap, testTotalCap, &clock).info(peer.id).posBalance
+	}
+	// Operator changes are written out immediately
+	if err := pool.setCapacity(peer.id, 30); err != nil {
+		t.Fatalf("failed to set capacity: %v", err)
+	}
+	if _, err := pool.addBalance(peer.id, 1000); err != nil {
+		t.Fatalf("failed to add balance: %v", err)
+	}
+	if balance := stored(); balance != 1000 {
+		t.Fatalf("added balance not persisted: have %d, want %d", balance, 1000)
+	}
+	// Charges are written out at most persistCycle later
+	if !peer.connect(pool) {
+		t.Fatalf("priority peer rejected")
+	}
+	pool.requestCost(peer, 100)
+	if balance := stored(); balance != 1000 {
+		t.Fatalf("charge persisted too early: have %d, want %d", balance, 1000)
+	}
+	clock.Run(persistCycle)
+	pool.requestCost(peer, 100)
+	if balance := stored(); balance != 800 {
+		t.Fatalf("charged balance not persisted: have %d, want %d", balance, 800)
+	}
+}
+
+// Tests that capacity updates are only announced to clients which negotiated
+// flow control updates during the handshake.
+func TestCapacityUpdateNegotiation(t *testing.T) {
+	var id discover.NodeID
+	p := &peer{
+		Peer:        p2p.NewPeer(id, "test", nil),
+		announceChn: make(chan announceData, 1),
+		fcClient:    flowcontrol.NewClientNode(flowcontrol.NewClientManager(50, 10, 1000000000), &flowcontrol.ServerParams{BufLimit: testBufLimit, MinRecharge: 1}),
+	}
+	p.updateCapacity(30)
+	if len(p.announceChn) != 0 {
+		t.Fatalf("capacity update announced to a legacy client")
+	}
+	p.fcUpdates = true
+	p.updateCapacity(30)
+	select {
+	case announce := <-p.announceChn:
+		var mrr uint64
+		if err := announce.Update.decode().get("flowControl/MRR", &mrr); err != nil || mrr != 30 {
+			t.Errorf("announced recharge mismatch: have %d (%v), want %d", mrr, err, 30)
+		}
+	default:
+		t.Fatalf("capacity update not announced")
+	}
+}
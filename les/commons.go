@@ -41,6 +41,7 @@ type lesCommons struct {
 	chainDb                      ethdb.Database
 	protocolManager              *ProtocolManager
 	chtIndexer, bloomTrieIndexer *core.ChainIndexer
+	oracle                       *checkpointOracle
 }
 
 // NodeInfo represents a short summary of the Ethereum sub-protocol metadata
@@ -93,32 +94,8 @@ func (c *lesCommons) makeProtocols(versions []uint) []p2p.Protocol {
 // nodeInfo retrieves some protocol metadata about the running host node.
 func (c *lesCommons) nodeInfo() interface{} {
 	var cht light.TrustedCheckpoint
-	sections, _, _ := c.chtIndexer.Sections()
-	sections2, _, _ := c.bloomTrieIndexer.Sections()
-
-	if !c.protocolManager.lightSync {
-		// convert to client section size if running in server mode
-		sections /= light.CHTFrequencyClient / light.CHTFrequencyServer
-	}
-
-	if sections2 < sections {
-		sections = sections2
-	}
-	if sections > 0 {
-		sectionIndex := sections - 1
-		sectionHead := c.bloomTrieIndexer.SectionHead(sectionIndex)
-		var chtRoot common.Hash
-		if c.protocolManager.lightSync {
-			chtRoot = light.GetChtRoot(c.chainDb, sectionIndex, sectionHead)
-		} else {
-			chtRoot = light.GetChtV2Root(c.chainDb, sectionIndex, sectionHead)
-		}
-		cht = light.TrustedCheckpoint{
-			SectionIdx:  sectionIndex,
-			SectionHead: sectionHead,
-			CHTRoot:     chtRoot,
-			BloomRoot:   light.GetBloomTrieRoot(c.chainDb, sectionIndex, sectionHead),
-		}
+	if sections := c.checkpointSections(); sections > 0 {
+		cht = c.getLocalCheckpoint(sections - 1)
 	}
 
 	chain := c.protocolManager.blockchain
@@ -133,3 +110,39 @@ func (c *lesCommons) nodeInfo() interface{} {
 		CHT:        cht,
 	}
 }
+
+// checkpointSections returns the number of sections (in client section size)
+// for which both the CHT and the BloomTrie have been generated locally.
+func (c *lesCommons) checkpointSections() uint64 {
+	sections, _, _ := c.chtIndexer.Sections()
+	sections2, _, _ := c.bloomTrieIndexer.Sections()
+
+	if !c.protocolManager.lightSync {
+		// convert to client section size if running in server mode
+		sections /= light.CHTFrequencyClient / light.CHTFrequencyServer
+	}
+
+	if sections2 < sections {
+		sections = sections2
+	}
+	return sections
+}
+
+// getLocalCheckpoint returns the set of post-processed trie roots (CHT and
+// BloomTrie) associated with the given section index.
+func (c *lesCommons) getLocalCheckpoint(index uint64) light.TrustedCheckpoint {
+	sectionHead := c.bloomTrieIndexer.SectionHead(index)
+
+	var chtRoot common.Hash
+	if c.protocolManager.lightSync {
+		chtRoot = light.GetChtRoot(c.chainDb, index, sectionHead)
+	} else {
+		chtRoot = light.GetChtV2Root(c.chainDb, index, sectionHead)
+	}
+	return light.TrustedCheckpoint{
+		SectionIdx:  index,
+		SectionHead: sectionHead,
+		CHTRoot:     chtRoot,
+		BloomRoot:   light.GetBloomTrieRoot(c.chainDb, index, sectionHead),
+	}
+}
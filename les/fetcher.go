@@ -576,6 +576,10 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64) {
 		for hash, n := range fp.nodeByHash {
 
 			// 逐个教研检查,逐个对比td
+			// Ultra light clients only fetch the heads agreed on by the trusted servers
+			if f.pm.ulc != nil && !f.trustedHead(hash) {
+				continue
+			}
 			if !f.checkKnownNode(p, n) && !n.requested && (bestTd == nil || n.td.Cmp(bestTd) >= 0) {
 				// 计算从特定header开始向后下载的header的数量
 				amount := f.requestAmount(p, n)
@@ -591,6 +595,10 @@ func (f *lightFetcher) nextRequest() (*distReq, uint64) {
 	if bestTd == f.maxConfirmedTd {
 		return nil, 0
 	}
+	// Ultra light clients never sync, they fetch the trusted head only
+	if f.pm.ulc != nil && bestSyncing {
+		bestAmount, bestSyncing = 1, false
+	}
 
 
 	//
@@ -714,7 +722,11 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 	for i, header := range resp.headers {
 		headers[int(req.amount)-1-i] = header
 	}
-	if _, err := f.chain.InsertHeaderChain(headers, 1); err != nil {
+	if f.pm.ulc != nil {
+		if headers = f.insertTrustedHeaders(req, headers); headers == nil {
+			return false
+		}
+	} else if _, err := f.chain.InsertHeaderChain(headers, 1); err != nil {
 		if err == consensus.ErrFutureBlock {
 			return true
 		}
@@ -734,6 +746,54 @@ func (f *lightFetcher) processResponse(req fetchRequest, resp fetchResponse) boo
 	return true
 }
 
+// insertTrustedHeaders inserts the headers of a head agreed on by the trusted
+// servers of an ultra light client without verifying their seals. If their
+// ancestors are not known locally, the head is set directly with the total
+// difficulty the trusted servers agreed on, regardless of the one announced by
+// the serving peer. The headers actually inserted are returned, nil on failure.
+func (f *lightFetcher) insertTrustedHeaders(req fetchRequest, headers []*types.Header) []*types.Header {
+	first := headers[0]
+	if f.chain.GetTd(first.ParentHash, first.Number.Uint64()-1) != nil {
+		if _, err := f.chain.InsertHeaderChain(headers, 0); err != nil {
+			log.Debug("Failed to insert trusted header chain", "err", err)
+			return nil
+		}
+		return headers
+	}
+	td := f.trustedTd(req.hash)
+	if td == nil {
+		return nil
+	}
+	head := headers[len(headers)-1]
+	f.chain.InsertTrustedHead(head, td)
+	return []*types.Header{head}
+}
+
+// trustedHead returns whether enough trusted servers of an ultra light client
+// announced the given head with the same total difficulty.
+func (f *lightFetcher) trustedHead(hash common.Hash) bool {
+	return f.trustedTd(hash) != nil
+}
+
+// trustedTd returns the total difficulty of the given head agreed on by enough
+// trusted servers of an ultra light client, or nil if there's no such value.
+// Announcements of untrusted peers are ignored.
+func (f *lightFetcher) trustedTd(hash common.Hash) *big.Int {
+	agreed := make(map[string]int)
+	for p, fp := range f.peers {
+		if !p.isTrusted {
+			continue
+		}
+		if n := fp.nodeByHash[hash]; n != nil && n.td != nil {
+			key := n.td.String()
+			if agreed[key]++; f.pm.ulc.trustedFraction(agreed[key]) {
+				return new(big.Int).Set(n.td)
+			}
+		}
+	}
+	return nil
+}
+
 // newHeaders updates the block trees of all active peers according to a newly
 // downloaded and validated batch or headers
 func (f *lightFetcher) newHeaders(headers []*types.Header, tds []*big.Int) {
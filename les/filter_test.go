@@ -0,0 +1,157 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/bloombits"
+	"github.com/go-ethereum-analysis/core/rawdb"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/eth"
+	"github.com/go-ethereum-analysis/eth/filters"
+	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/light"
+)
+
+var (
+	// testEventTopic is the topic of the event logged by the test event emitter
+	// on its creation.
+	testEventTopic = common.HexToHash("0x57050ab73f6b9ebdd9f76b8d4997793f48cf956e965ee070551b9ca0bb71584e")
+
+	// filterTestBlocks is the length of the filter test chain, long enough for
+	// the server to serve the first CHT and BloomTrie sections.
+	filterTestBlocks = light.BloomTrieFrequency + light.HelperTrieConfirmations
+)
+
+// filterTestChainGen extends testChainGen by creating a new event emitter every
+// thousand blocks, both within and after the first bloom section.
+func filterTestChainGen(i int, block *core.BlockGen) {
+	testChainGen(i, block)
+
+	if i%1000 == 500 {
+		tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(testBankAddress), big.NewInt(0), 200000, big.NewInt(0), testEventEmitterCode), types.HomesteadSigner{}, testBankKey)
+		block.AddTx(tx)
+	}
+}
+
+// Tests that a light client filters the logs of thousands of blocks correctly,
+// searching the sections covered by its checkpoint with the bloom bits proven
+// by the server's BloomTrie.
+func TestLightFilterLogs(t *testing.T) {
+	// Assemble the server with a long chain and wait for its helper tries
+	db := ethdb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, filterTestBlocks, filterTestChainGen, nil, nil, db)
+
+	sectionHead := rawdb.ReadCanonicalHash(db, light.BloomTrieFrequency-1)
+	checkpoint := light.TrustedCheckpoint{SectionIdx: 0, SectionHead: sectionHead}
+	for deadline := time.Now().Add(time.Minute); ; {
+		checkpoint.CHTRoot = light.GetChtV2Root(db, 0, sectionHead)
+		checkpoint.BloomRoot = light.GetBloomTrieRoot(db, 0, sectionHead)
+		if checkpoint.CHTRoot != (common.Hash{}) && checkpoint.BloomRoot != (common.Hash{}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("helper tries not generated")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Assemble a light client trusting the first section and sync it up
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	ldb := ethdb.NewMemDatabase()
+	odr := NewLesOdr(ldb, rm)
+	odr.SetIndexers(light.NewChtIndexer(ldb, true, odr), light.NewBloomTrieIndexer(ldb, true, odr), eth.NewBloomIndexer(ldb, light.BloomTrieFrequency, light.HelperTrieConfirmations))
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+	lc := lpm.blockchain.(*light.LightChain)
+
+	_, err1, lpeer, err2 := newTestPeerPair("peer", lpv2, pm, lpm)
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 2 handshake error: %v", err)
+	}
+	lc.AddTrustedCheckpoint(checkpoint)
+	lpm.synchronise(lpeer)
+
+	// The fetcher might have started syncing on the head announcement already
+	for deadline := time.Now().Add(time.Minute); lc.CurrentHeader().Number.Uint64() < uint64(filterTestBlocks); {
+		if time.Now().After(deadline) {
+			t.Fatalf("light client head mismatch: have %d, want %d", lc.CurrentHeader().Number, filterTestBlocks)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	// Filter the whole chain through the light backend
+	leth := &LightEthereum{
+		lesCommons:    lesCommons{chainDb: ldb},
+		odr:           odr,
+		blockchain:    lc,
+		bloomIndexer:  odr.BloomIndexer(),
+		bloomRequests: make(chan chan *bloombits.Retrieval),
+		shutdownChan:  make(chan bool),
+	}
+	leth.startBloomHandlers()
+	defer close(leth.shutdownChan)
+
+	backend := &LesApiBackend{eth: leth}
+	if size, sections := backend.BloomStatus(); size != light.BloomTrieFrequency || sections != 1 {
+		t.Fatalf("bloom status mismatch: have %d/%d, want %d/%d", size, sections, light.BloomTrieFrequency, 1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	logs, err := filters.NewRangeFilter(backend, 0, -1, nil, [][]common.Hash{{testEventTopic}}).Logs(ctx)
+	if err != nil {
+		t.Fatalf("failed to filter logs: %v", err)
+	}
+	// Cross check the logs against the ones stored by the server
+	var want []*types.Log
+	for i := uint64(0); i <= uint64(filterTestBlocks); i++ {
+		hash := rawdb.ReadCanonicalHash(db, i)
+		for _, receipt := range rawdb.ReadReceipts(db, hash, i) {
+			for _, log := range receipt.Logs {
+				if len(log.Topics) > 0 && log.Topics[0] == testEventTopic {
+					want = append(want, log)
+				}
+			}
+		}
+	}
+	if len(want) <= filterTestBlocks/1000 {
+		t.Fatalf("too few server logs: have %d, want > %d", len(want), filterTestBlocks/1000)
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("log count mismatch: have %d, want %d", len(logs), len(want))
+	}
+	for i, log := range logs {
+		if log.BlockNumber != want[i].BlockNumber || log.BlockHash != want[i].BlockHash || log.TxHash != want[i].TxHash || log.Address != want[i].Address {
+			t.Errorf("log %d mismatch: have block %d tx %x address %x, want block %d tx %x address %x", i,
+				log.BlockNumber, log.TxHash, log.Address, want[i].BlockNumber, want[i].TxHash, want[i].Address)
+		}
+	}
+	// Logs outside the checkpoint section can only be found by the unindexed search
+	if last := logs[len(logs)-1].BlockNumber; last < light.BloomTrieFrequency {
+		t.Errorf("no logs found beyond the indexed sections: last block %d", last)
+	}
+}
@@ -114,6 +114,26 @@ func (peer *ClientNode) AcceptRequest() (uint64, bool) {
 	return peer.bufValue, peer.cm.accept(peer.cmNode, time)
 }
 
+// Params returns the current flow control parameters of the client node.
+func (peer *ClientNode) Params() *ServerParams {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	return peer.params
+}
+
+// UpdateParams changes the flow control parameters of the client node. The
+// buffer value is shifted by the change of the buffer limit so that the server
+// side value stays consistent with the estimate of the client.
+func (peer *ClientNode) UpdateParams(params *ServerParams) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBV(mclock.Now())
+	peer.bufValue = shiftBufValue(peer.bufValue, peer.params.BufLimit, params.BufLimit)
+	peer.params = params
+}
+
 func (peer *ClientNode) RequestProcessed(cost uint64) (bv, realCost uint64) {
 	peer.lock.Lock()
 	defer peer.lock.Unlock()
@@ -173,6 +193,28 @@ func (peer *ServerNode) recalcBLE(time mclock.AbsTime) {
 	peer.lastTime = time
 }
 
+// UpdateParams changes the flow control parameters announced by the server.
+func (peer *ServerNode) UpdateParams(params *ServerParams) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBLE(mclock.Now())
+	peer.bufEstimate = shiftBufValue(peer.bufEstimate, peer.params.BufLimit, params.BufLimit)
+	peer.params = params
+}
+
+// shiftBufValue adjusts a buffer value after its limit changed from oldLimit to
+// newLimit, keeping the amount missing from the full buffer unchanged.
+func shiftBufValue(value, oldLimit, newLimit uint64) uint64 {
+	if newLimit >= oldLimit {
+		return value + newLimit - oldLimit
+	}
+	if diff := oldLimit - newLimit; value > diff {
+		return value - diff
+	}
+	return 0
+}
+
 // safetyMargin is added to the flow control waiting time when estimated buffer value is low
 //
 // 当估计的缓冲区值较低时，将safetyMargin添加到流控制等待时间
@@ -111,7 +111,11 @@ func (f *freeClientPool) connect(address string, disconnectFn func()) bool {
 	}
 	e.linUsage = recentUsage - int64(now)
 	// check whether (linUsage+connectedBias) is smaller than the highest entry in the connected pool
-	if f.connPool.Size() == f.connectedLimit {
+	if f.connPool.Size() >= f.connectedLimit {
+		if f.connPool.Size() == 0 {
+			log.Debug("Client rejected", "address", address)
+			return false
+		}
 		i := f.connPool.PopItem().(*freeClientPoolEntry)
 		if e.linUsage+int64(connectedBias)-i.linUsage < 0 {
 			// kick it out and accept the new client
@@ -151,7 +155,7 @@ func (f *freeClientPool) disconnect(address string) {
 	}
 	e := f.addressMap[address]
 	now := f.clock.Now()
-	if !e.connected {
+	if e == nil || !e.connected {
 		log.Debug("Client already disconnected", "address", address)
 		return
 	}
@@ -163,6 +167,27 @@ func (f *freeClientPool) disconnect(address string) {
 	log.Debug("Client disconnected", "address", address)
 }
 
+// setConnectedLimit changes the maximum number of connected free clients,
+// kicking out the ones with the highest recent usage if necessary.
+func (f *freeClientPool) setConnectedLimit(limit int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.connectedLimit = limit
+	if f.closed {
+		return
+	}
+	now := f.clock.Now()
+	for f.connPool.Size() > f.connectedLimit {
+		i := f.connPool.PopItem().(*freeClientPoolEntry)
+		f.calcLogUsage(i, now)
+		i.connected = false
+		f.disconnPool.Push(i, -i.logUsage)
+		log.Debug("Client kicked out", "address", i.address)
+		i.disconnectFn()
+	}
+}
+
 // logOffset calculates the time-dependent offset for the logarithmic
 // representation of recent usage
 func (f *freeClientPool) logOffset(now mclock.AbsTime) int64 {
@@ -27,7 +27,6 @@ import (
 	"time"
 
 	"github.com/go-ethereum-analysis/common"
-	"github.com/go-ethereum-analysis/common/mclock"
 	"github.com/go-ethereum-analysis/consensus"
 	"github.com/go-ethereum-analysis/core"
 	"github.com/go-ethereum-analysis/core/rawdb"
@@ -101,11 +100,13 @@ type ProtocolManager struct {
 	odr         *LesOdr
 	// todo  只有是开启了支持轻节点连接 Server 端的全节点，才会对 pm.server 赋值
 	server      *LesServer
+	oracle      *checkpointOracle // Checkpoint oracle verifying announced checkpoints, nil if disabled
+	ulc         *ulc              // Ultra light client mode settings, nil if disabled
 
 	// todo  如果当前节点是轻节点Client 则,该值就有
 	// todo 里头记录的是和当前 client链接的 server 端 (与当前client链接的server全节点)
 	serverPool  *serverPool
-	clientPool  *freeClientPool
+	clientPool  *clientPool
 	lesTopic    discv5.Topic
 	// 请求分发器
 	reqDist     *requestDistributor
@@ -186,6 +187,16 @@ func (pm *ProtocolManager) removePeer(id string) {
 	pm.peers.Unregister(id)
 }
 
+// requestProcessed updates the flow control buffer of a client after serving
+// a request and charges the measured serving cost to its balance.
+func (pm *ProtocolManager) requestProcessed(p *peer, cost uint64) (bv, realCost uint64) {
+	bv, realCost = p.fcClient.RequestProcessed(cost)
+	if pm.clientPool != nil {
+		pm.clientPool.requestCost(p, realCost)
+	}
+	return bv, realCost
+}
+
 
 /**
 TODO 启动 轻节点的 pm (Server/Client)
@@ -200,7 +211,6 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	} else {
 
 		// todo 如果当前是 Server端的话
-		pm.clientPool = newFreeClientPool(pm.chainDb, maxPeers, 10000, mclock.System{})
 		go func() {
 			for range pm.newPeerCh {
 			}
@@ -282,7 +292,11 @@ func (pm *ProtocolManager) runPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWrit
 }
 
 func (pm *ProtocolManager) newPeer(pv int, nv uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
-	return newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	peer := newPeer(pv, nv, p, newMeteredMsgWriter(rw))
+	if pm.ulc != nil {
+		peer.isTrusted = pm.ulc.isTrusted(p.ID())
+	}
+	return peer
 }
 
 // handle is the callback invoked to manage the life cycle of a les peer. When
@@ -336,16 +350,14 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	 */
 	if !pm.lightSync && !p.Peer.Info().Network.Trusted {
-		addr, ok := p.RemoteAddr().(*net.TCPAddr)
 		// test peer address is not a tcp address, don't use client pool if can not typecast
 		//
 		// 测试 peer 的地址不是TCP地址，如果无法进行类型转换，请不要使用客户端池
-		if ok {
-			id := addr.IP.String()
-			if !pm.clientPool.connect(id, func() { go pm.removePeer(p.id) }) {
+		if _, ok := p.RemoteAddr().(*net.TCPAddr); ok && pm.clientPool != nil {
+			if !pm.clientPool.connect(p, func() { go pm.removePeer(p.id) }) {
 				return p2p.DiscTooManyPeers
 			}
-			defer pm.clientPool.disconnect(id)
+			defer pm.clientPool.disconnect(p)
 		}
 	}
 
@@ -471,14 +483,15 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		bufValue, _ := p.fcClient.AcceptRequest()
 
 		// 计算(资源)消耗的值
+		params := p.fcClient.Params()
 		cost := costs.baseCost + reqCnt*costs.reqCost
-		if cost > pm.server.defParams.BufLimit {
-			cost = pm.server.defParams.BufLimit
+		if cost > params.BufLimit {
+			cost = params.BufLimit
 		}
 
 		// 如果计算出的预计消耗 令牌 > 剩余可消耗令牌
 		if cost > bufValue {
-			recharge := time.Duration((cost - bufValue) * 1000000 / pm.server.defParams.MinRecharge)
+			recharge := time.Duration((cost - bufValue) * 1000000 / params.MinRecharge)
 			p.Log().Error("Request came too early", "recharge", common.PrettyDuration(recharge))
 			return true
 		}
@@ -558,6 +571,17 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&req); err != nil {
 			return errResp(ErrDecode, "%v: %v", msg, err)
 		}
+		// Announcements without a block carry updated flow control parameters
+		if req.Hash == (common.Hash{}) {
+			if p.fcServer == nil {
+				return errResp(ErrUnexpectedResponse, "")
+			}
+			if err := p.updateFlowControl(req.Update); err != nil {
+				return errResp(ErrDecode, "%v: %v", msg, err)
+			}
+			p.Log().Trace("Updated flow control parameters")
+			return nil
+		}
 
 		if p.requestAnnounceType == announceTypeSigned { // 这个也是, 因为目前没看到对这个 p.requestAnnounceType 赋值 这个的地方啊
 			if err := req.checkSignature(p.pubKey); err != nil {
@@ -696,7 +720,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 
 		// 计算对端client 在当前节点剩余的 资源 BV
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + query.Amount*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + query.Amount*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, query.Amount, rcost)
 
 		// reqId, BV, headers
@@ -786,7 +810,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				}
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendBlockBodiesRLP(req.ReqID, bv, bodies)
 
@@ -865,7 +889,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				}
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendCode(req.ReqID, bv, data)
 
@@ -951,7 +975,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(encoded)
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendReceiptsRLP(req.ReqID, bv, receipts)
 
@@ -1095,7 +1119,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 		// 调整当前Server节点中对端p的client 令牌桶
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 
 		// todo 将本节点组装好的proof发回client
@@ -1199,7 +1223,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				break
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		// nodes.NodeList(): 将 nodes 转化成 nodeList
 		return p.SendProofsV2(req.ReqID, bv, nodes.NodeList())
@@ -1381,7 +1405,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				}
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendHeaderProofs(req.ReqID, bv, proofs)
 
@@ -1486,7 +1510,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				break
 			}
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendHelperTrieProofs(req.ReqID, bv, HelperTrieResps{Proofs: nodes.NodeList(), AuxData: auxData})
 
@@ -1620,7 +1644,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// 将新的 txs 追加到 txpool remote 中
 		pm.txpool.AddRemotes(txs)
 
-		_, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		_, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 
 	/**
@@ -1680,7 +1704,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 		// 调节 各种资源
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		// TODO 将 tx的状态发送回去
 		// todo 下面的 `TxStatusMsg` 有用
@@ -1724,7 +1748,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if reject(uint64(reqCnt), MaxTxStatus) {
 			return errResp(ErrRequestRejected, "")
 		}
-		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		bv, rcost := pm.requestProcessed(p, costs.baseCost + uint64(reqCnt)*costs.reqCost)
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 
 		// 回应 tx Status
@@ -1734,8 +1758,6 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	/**
 	LPV2
 	Client 处理 jiaoyan tx status 的 resp
-
-	TODO  貌似没鸡吊 用
 	 */
 	case TxStatusMsg:
 		if pm.odr == nil {
@@ -1753,6 +1775,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		// 调整 server 的资源
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTxStatus,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Status,
+		}
 
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
@@ -19,6 +19,8 @@ package les
 import (
 	"context"
 
+	"github.com/go-ethereum-analysis/common"
+
 	"github.com/go-ethereum-analysis/core"
 	"github.com/go-ethereum-analysis/ethdb"
 	"github.com/go-ethereum-analysis/light"
@@ -91,8 +93,13 @@ const (
 	MsgProofsV2
 	MsgHeaderProofs
 	MsgHelperTrieProofs
+	MsgTxStatus
 )
 
+// txStatusQueryPeers is the number of servers queried in parallel for the status
+// of transactions.
+const txStatusQueryPeers = 3
+
 // Msg encodes a LES message that delivers reply data for a request
 type Msg struct {
 	MsgType int
@@ -111,6 +118,10 @@ todo 一) BloomTrieIndexer
 todo 二) ChtIndexer
  */
 func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err error) {
+	// Transaction status is not provable, ask several servers instead of one
+	if r, ok := req.(*light.TxStatusRequest); ok {
+		return odr.retrieveTxStatus(ctx, r)
+	}
 
 	// 如果是BloomTrieIndexer的话, 那么 req是 `BloomRequest`
 	// 如果是ChtIndexer的话, 那么 req是 `ChtRequest`
@@ -174,3 +185,89 @@ func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err erro
 	}
 	return
 }
+
+// retrieveTxStatus queries the status of transactions from several servers in
+// parallel. As the answers can't be proven, a status is only accepted if the
+// majority of the queried servers reported it.
+func (odr *LesOdr) retrieveTxStatus(ctx context.Context, req *light.TxStatusRequest) error {
+	var peers []*peer
+	for _, p := range odr.retriever.peers.AllPeers() {
+		if (*TxStatusRequest)(req).CanSend(p) {
+			peers = append(peers, p)
+		}
+		if len(peers) == txStatusQueryPeers {
+			break
+		}
+	}
+	if len(peers) == 0 {
+		return light.ErrNoPeers
+	}
+	results := make(chan []light.TxStatus, len(peers))
+	for _, p := range peers {
+		go func(p *peer) {
+			lreq := &TxStatusRequest{Hashes: req.Hashes}
+			reqID := genReqID()
+			rq := &distReq{
+				getCost: func(dp distPeer) uint64 {
+					return lreq.GetCost(dp.(*peer))
+				},
+				canSend: func(dp distPeer) bool {
+					return dp.(*peer) == p
+				},
+				request: func(dp distPeer) func() {
+					cost := lreq.GetCost(p)
+					p.fcServer.QueueRequest(reqID, cost)
+					return func() { lreq.Request(reqID, p) }
+				},
+			}
+			if err := odr.retriever.retrieve(ctx, reqID, rq, func(dp distPeer, msg *Msg) error { return lreq.Validate(odr.db, msg) }, odr.stop); err != nil {
+				log.Debug("Failed to retrieve transaction status", "peer", p.id, "err", err)
+				results <- nil
+				return
+			}
+			results <- lreq.Status
+		}(p)
+	}
+	var replies [][]light.TxStatus
+	for range peers {
+		if status := <-results; status != nil {
+			replies = append(replies, status)
+		}
+	}
+	if len(replies) == 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return light.ErrNoPeers
+	}
+	req.Status = reconcileTxStatus(len(req.Hashes), len(peers), replies)
+	return nil
+}
+
+// reconcileTxStatus picks the status reported by the majority of the queried
+// servers for each transaction, counting failed queries as dissent. If there's
+// no majority, the transaction status is reported as unknown.
+func reconcileTxStatus(count int, queried int, replies [][]light.TxStatus) []light.TxStatus {
+	type answer struct {
+		status core.TxStatus
+		block  common.Hash
+		index  uint64
+	}
+	result := make([]light.TxStatus, count)
+	for i := range result {
+		result[i].Status = core.TxStatusUnknown
+
+		votes := make(map[answer]int)
+		for _, reply := range replies {
+			ans := answer{status: reply[i].Status}
+			if reply[i].Lookup != nil {
+				ans.block, ans.index = reply[i].Lookup.BlockHash, reply[i].Lookup.Index
+			}
+			if votes[ans]++; 2*votes[ans] > queried {
+				result[i] = reply[i]
+				break
+			}
+		}
+	}
+	return result
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"github.com/go-ethereum-analysis/core/vm"
 	"github.com/go-ethereum-analysis/eth"
 	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/internal/ethapi"
 	"github.com/go-ethereum-analysis/light"
 	"github.com/go-ethereum-analysis/params"
 	"github.com/go-ethereum-analysis/rlp"
@@ -219,3 +221,113 @@ func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
 	test(5)
 }
+
+// Tests that a transaction status is only accepted if the majority of the
+// queried servers reported it.
+func TestReconcileTxStatus(t *testing.T) {
+	var (
+		unknown  = light.TxStatus{Status: core.TxStatusUnknown}
+		pending  = light.TxStatus{Status: core.TxStatusPending}
+		included = light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.TxLookupEntry{BlockHash: common.HexToHash("0x01"), BlockIndex: 1}}
+		forked   = light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.TxLookupEntry{BlockHash: common.HexToHash("0x02"), BlockIndex: 1}}
+	)
+	tests := []struct {
+		queried int
+		replies []light.TxStatus
+		want    light.TxStatus
+	}{
+		{1, []light.TxStatus{pending}, pending},
+		{3, []light.TxStatus{included, included, pending}, included},
+		{3, []light.TxStatus{pending, included, unknown}, unknown},
+		{3, []light.TxStatus{included, forked, forked}, forked},
+		{3, []light.TxStatus{included}, unknown},            // a single reply out of three servers queried
+		{3, []light.TxStatus{included, included}, included}, // a failed query doesn't prevent a majority
+		{4, []light.TxStatus{pending, pending, included, included}, unknown},
+	}
+	for i, tt := range tests {
+		replies := make([][]light.TxStatus, len(tt.replies))
+		for j, reply := range tt.replies {
+			replies[j] = []light.TxStatus{reply}
+		}
+		have := reconcileTxStatus(1, tt.queried, replies)[0]
+		if have.Status != tt.want.Status || (have.Lookup == nil) != (tt.want.Lookup == nil) || (have.Lookup != nil && *have.Lookup != *tt.want.Lookup) {
+			t.Errorf("test %d: status mismatch: have %+v, want %+v", i, have, tt.want)
+		}
+	}
+}
+
+// testStatusPool is a transaction pool reporting the same status for every
+// transaction.
+type testStatusPool struct {
+	lock   sync.Mutex
+	status core.TxStatus
+}
+
+func (p *testStatusPool) AddRemotes(txs []*types.Transaction) []error {
+	return make([]error, len(txs))
+}
+
+func (p *testStatusPool) Status(hashes []common.Hash) []core.TxStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	status := make([]core.TxStatus, len(hashes))
+	for i := range status {
+		status[i] = p.status
+	}
+	return status
+}
+
+func (p *testStatusPool) setStatus(status core.TxStatus) {
+	p.lock.Lock()
+	p.status = status
+	p.lock.Unlock()
+}
+
+// Tests that eth_getTransactionStatus on a light client reports the status the
+// majority of the connected servers agree on.
+func TestTransactionStatusRPC(t *testing.T) {
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	ldb := ethdb.NewMemDatabase()
+	odr := NewLesOdr(ldb, rm)
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+
+	pools := make([]*testStatusPool, txStatusQueryPeers)
+	for i := range pools {
+		pools[i] = &testStatusPool{status: core.TxStatusPending}
+		pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, ethdb.NewMemDatabase())
+		pm.txpool = pools[i]
+
+		_, err1, _, err2 := newTestPeerPair("peer", lpv2, pm, lpm)
+		select {
+		case <-time.After(time.Millisecond * 100):
+		case err := <-err1:
+			t.Fatalf("server %d handshake error: %v", i, err)
+		case err := <-err2:
+			t.Fatalf("client %d handshake error: %v", i, err)
+		}
+	}
+	api := ethapi.NewPublicTransactionPoolAPI(&LesApiBackend{eth: &LightEthereum{odr: odr}}, nil)
+	status := func() interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		fields, err := api.GetTransactionStatus(ctx, common.HexToHash("0x01"))
+		if err != nil {
+			t.Fatalf("failed to retrieve transaction status: %v", err)
+		}
+		return fields["status"]
+	}
+	// A single server disagreeing doesn't change the outcome
+	pools[0].setStatus(core.TxStatusQueued)
+	if have := status(); have != "pending" {
+		t.Errorf("majority status mismatch: have %v, want %v", have, "pending")
+	}
+	// Without a majority the transaction is reported as unknown
+	pools[1].setStatus(core.TxStatusUnknown)
+	if have := status(); have != "unknown" {
+		t.Errorf("disputed status mismatch: have %v, want %v", have, "unknown")
+	}
+}
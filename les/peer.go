@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"sync"
 	"time"
 
@@ -93,6 +94,12 @@ type peer struct {
 
 	// todo 记录req的消耗表
 	fcCosts        requestCostTable
+
+	checkpoint     *light.TrustedCheckpoint // Latest oracle checkpoint announced by the server, nil if none
+	checkpointSigs [][]byte                 // Signatures approving the announced checkpoint
+
+	isTrusted bool // Whether the server is trusted in ultra light client mode
+	fcUpdates bool // Whether the client accepts flow control updates in blockless announcements
 }
 
 func newPeer(version int, network uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -206,6 +213,62 @@ func (p *peer) SendAnnounce(request announceData) error {
 	return p2p.Send(p.rw, AnnounceMsg, request)
 }
 
+// freeClientId returns the identifier of the client used by the free client
+// pool, which is the IP address of the connection.
+func (p *peer) freeClientId() string {
+	if addr, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return p.id
+}
+
+// updateCapacity changes the flow control parameters of a connected client
+// according to its assigned capacity and announces them to the client.
+func (p *peer) updateCapacity(capacity uint64) {
+	// Clients not negotiating the updates would misread the blockless
+	// announcement, keep their handshake parameters instead.
+	if !p.fcUpdates {
+		p.Log().Debug("Client doesn't support flow control updates", "capacity", capacity)
+		return
+	}
+	params := &flowcontrol.ServerParams{
+		BufLimit:    capacity * bufLimitRatio,
+		MinRecharge: capacity,
+	}
+	p.fcClient.UpdateParams(params)
+
+	var update keyValueList
+	update = update.add("flowControl/BL", params.BufLimit)
+	update = update.add("flowControl/MRR", params.MinRecharge)
+	select {
+	case p.announceChn <- announceData{Td: new(big.Int), Update: update}:
+	default:
+		p.Log().Debug("Dropped flow control update", "capacity", capacity)
+	}
+}
+
+// updateFlowControl applies the flow control parameters updated by the server
+// in a blockless announcement.
+func (p *peer) updateFlowControl(update keyValueList) error {
+	recv := update.decode()
+	params := &flowcontrol.ServerParams{}
+	if err := recv.get("flowControl/BL", &params.BufLimit); err != nil {
+		return err
+	}
+	if err := recv.get("flowControl/MRR", &params.MinRecharge); err != nil {
+		return err
+	}
+	if params.BufLimit == 0 || params.MinRecharge == 0 {
+		return errResp(ErrUselessPeer, "invalid flow control parameters")
+	}
+	p.lock.Lock()
+	p.fcServerParams = params
+	p.lock.Unlock()
+
+	p.fcServer.UpdateParams(params)
+	return nil
+}
+
 // SendBlockHeaders sends a batch of block headers to the remote peer.
 func (p *peer) SendBlockHeaders(reqID, bv uint64, headers []*types.Header) error {
 	return sendResponse(p.rw, BlockHeadersMsg, reqID, bv, headers)
@@ -522,11 +585,24 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 
 
 		p.fcCosts = list.decode()
+
+		// Announce the latest checkpoint registered in the oracle, if any
+		if server.oracle != nil {
+			if checkpoint, sigs := server.oracle.stableCheckpoint(); checkpoint != nil {
+				send = send.add("checkpoint/value", checkpoint)
+				send = send.add("checkpoint/signatures", sigs)
+			}
+		}
 	} else {
 
 		// 设置为默认，直到实现“非常轻巧”客户端模式
-		p.requestAnnounceType = announceTypeSimple // set to default until "very light" client mode is implemented
+		p.requestAnnounceType = announceTypeSimple
+		if p.isTrusted {
+			// Ultra light clients only accept heads signed by the trusted servers
+			p.requestAnnounceType = announceTypeSigned
+		}
 		send = send.add("announceType", p.requestAnnounceType)
+		send = send.add("flowControl/updates", nil) // Blockless announcements carrying new flow control parameters are understood
 	}
 
 	/**
@@ -590,6 +666,8 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 			// todo 如果是 轻节点的server 端,则默认是: announceTypeSimple
 			p.announceType = announceTypeSimple
 		}
+		p.fcUpdates = recv.get("flowControl/updates", nil) == nil
+
 		// todo 则，确认 `对端节点实例 p` 是 client
 		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
 	} else {
@@ -616,6 +694,12 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 			return err
 		}
 		p.fcServerParams = params
+
+		// Oracle checkpoints are optional, servers without an oracle don't announce any
+		var checkpoint light.TrustedCheckpoint
+		if recv.get("checkpoint/value", &checkpoint) == nil && recv.get("checkpoint/signatures", &p.checkpointSigs) == nil {
+			p.checkpoint = &checkpoint
+		}
 		// todo 否则，确认 `对端节点实例 p` 是 server
 		p.fcServer = flowcontrol.NewServerNode(params)
 		p.fcCosts = MRC.decode()
@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/common/mclock"
 	"github.com/go-ethereum-analysis/core"
 	"github.com/go-ethereum-analysis/core/rawdb"
 	"github.com/go-ethereum-analysis/core/types"
@@ -35,9 +36,14 @@ import (
 	"github.com/go-ethereum-analysis/p2p"
 	"github.com/go-ethereum-analysis/p2p/discv5"
 	"github.com/go-ethereum-analysis/rlp"
+	"github.com/go-ethereum-analysis/rpc"
 )
 
 
+// bufLimitRatio is the fixed ratio between the buffer limit and the minimum
+// recharge rate of the clients, applied when their capacity changes.
+const bufLimitRatio = 6000
+
 /**
 轻节点 的server端实现 (即: 全节点对于 les 的server实现)
  */
@@ -141,10 +147,18 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 	/** TODO  只有是开启了支持轻节点连接 Server 端的全节点，才会对 pm.server 赋值 */
 	pm.server = srv
 
+	// Announce the checkpoints registered in the oracle contract, if configured
+	if srv.oracle = newCheckpointOracle(config.CheckpointOracle, srv.getLocalCheckpoint); srv.oracle != nil {
+		srv.oracle.start(newContractBackend(eth))
+	}
+
 	srv.defParams = &flowcontrol.ServerParams{
 		BufLimit:    300000000,
 		MinRecharge: 50000,
 	}
+	// Free clients are served with the default parameters, priority clients get
+	// the capacity assigned by the operator out of the same total capacity
+	pm.clientPool = newClientPool(eth.ChainDb(), srv.defParams.MinRecharge, srv.defParams.MinRecharge*uint64(config.LightPeers), mclock.System{})
 
 	// todo 只有当前节点是 les 的server 端下回有这个, 即一些关于 client 管理相关的
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
@@ -163,6 +177,18 @@ func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.makeProtocols(ServerProtocolVersions)
 }
 
+// APIs returns the collection of RPC services the les server offers.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+			Public:    false,
+		},
+	}
+}
+
 // Start starts the LES server
 //
 // todo 启动 轻节点 Server端
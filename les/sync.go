@@ -23,6 +23,7 @@ import (
 	"github.com/go-ethereum-analysis/core/rawdb"
 	"github.com/go-ethereum-analysis/eth/downloader"
 	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/log"
 )
 
 // syncer is responsible for periodically synchronising with the network, both
@@ -78,6 +79,15 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		return
 	}
 
+	// Jump ahead to the checkpoint announced by the server if the oracle approves it
+	if pm.oracle != nil && peer.checkpoint != nil {
+		pm.syncCheckpoint(peer)
+	}
+	// Ultra light clients don't download the headers, the fetcher jumps to the
+	// head announced by the trusted servers instead
+	if pm.ulc != nil {
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	pm.blockchain.(*light.LightChain).SyncCht(ctx)
@@ -87,3 +97,20 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	 */
 	pm.downloader.Synchronise(peer.id, peer.Head(), peer.Td(), downloader.LightSync)
 }
+
+// syncCheckpoint adds the checkpoint announced by a server as a trusted one if
+// it is newer than the locally known sections and enough oracle signers approved
+// it.
+func (pm *ProtocolManager) syncCheckpoint(peer *peer) {
+	chain := pm.blockchain.(*light.LightChain)
+
+	sections, _, _ := chain.Odr().ChtIndexer().Sections()
+	if peer.checkpoint.SectionIdx < sections {
+		return
+	}
+	if !pm.oracle.verifyCheckpoint(peer.checkpoint, peer.checkpointSigs) {
+		log.Warn("Rejected unapproved checkpoint", "peer", peer.id, "section", peer.checkpoint.SectionIdx)
+		return
+	}
+	chain.AddTrustedCheckpoint(*peer.checkpoint)
+}
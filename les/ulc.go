@@ -0,0 +1,74 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"github.com/go-ethereum-analysis/eth"
+	"github.com/go-ethereum-analysis/log"
+	"github.com/go-ethereum-analysis/p2p/discover"
+)
+
+// ulc holds the configuration of the ultra light client mode, in which new
+// heads are accepted once enough trusted servers signed their announcements.
+type ulc struct {
+	servers            []*discover.Node // Trusted servers to keep connected to
+	trustedKeys        map[discover.NodeID]struct{}
+	minTrustedFraction int
+}
+
+// newULC creates the ultra light client handler for the given config,
+// returning nil if the mode is not enabled or no trusted server is valid.
+func newULC(config *eth.ULCConfig) *ulc {
+	if config == nil {
+		return nil
+	}
+	var (
+		servers []*discover.Node
+		keys    = make(map[discover.NodeID]struct{})
+	)
+	for _, url := range config.TrustedServers {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			log.Warn("Failed to parse trusted server", "url", url, "err", err)
+			continue
+		}
+		servers = append(servers, node)
+		keys[node.ID] = struct{}{}
+	}
+	if len(keys) == 0 {
+		log.Warn("No valid trusted servers, ultra light client mode disabled")
+		return nil
+	}
+	log.Info("Ultra light client mode enabled", "servers", len(keys), "fraction", config.MinTrustedFraction)
+	return &ulc{
+		servers:            servers,
+		trustedKeys:        keys,
+		minTrustedFraction: config.MinTrustedFraction,
+	}
+}
+
+// isTrusted returns whether the given server is one of the trusted ones.
+func (u *ulc) isTrusted(id discover.NodeID) bool {
+	_, ok := u.trustedKeys[id]
+	return ok
+}
+
+// trustedFraction returns whether the given number of agreeing trusted servers
+// reaches the configured fraction of all trusted servers.
+func (u *ulc) trustedFraction(agreed int) bool {
+	return 100*agreed >= u.minTrustedFraction*len(u.trustedKeys)
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/consensus/ethash"
+	"github.com/go-ethereum-analysis/core"
+	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
+	"github.com/go-ethereum-analysis/eth"
+	"github.com/go-ethereum-analysis/ethdb"
+	"github.com/go-ethereum-analysis/light"
+	"github.com/go-ethereum-analysis/p2p/discover"
+	"github.com/go-ethereum-analysis/params"
+)
+
+// newTestULC creates an ultra light client config trusting the given number of
+// freshly generated servers.
+func newTestULC(t *testing.T, servers int, fraction int) (*ulc, []discover.NodeID) {
+	var (
+		urls []string
+		ids  []discover.NodeID
+	)
+	for i := 0; i < servers; i++ {
+		key, _ := crypto.GenerateKey()
+		id := discover.PubkeyID(&key.PublicKey)
+		urls = append(urls, discover.NewNode(id, net.IPv4(127, 0, 0, 1), 30303, 30303).String())
+		ids = append(ids, id)
+	}
+	u := newULC(&eth.ULCConfig{TrustedServers: append(urls, "invalid"), MinTrustedFraction: fraction})
+	if u == nil {
+		t.Fatalf("ultra light client mode not enabled")
+	}
+	return u, ids
+}
+
+// Tests that only the configured servers are trusted and that heads need the
+// configured fraction of them to agree.
+func TestULCTrustedServers(t *testing.T) {
+	u, ids := newTestULC(t, 4, 75)
+
+	if len(u.trustedKeys) != len(ids) {
+		t.Fatalf("trusted server count mismatch: have %d, want %d", len(u.trustedKeys), len(ids))
+	}
+	for i, id := range ids {
+		if !u.isTrusted(id) {
+			t.Errorf("server %d not trusted", i)
+		}
+	}
+	if u.isTrusted(discover.NodeID{1}) {
+		t.Errorf("unknown server trusted")
+	}
+	if newULC(&eth.ULCConfig{TrustedServers: []string{"invalid"}, MinTrustedFraction: 75}) != nil {
+		t.Errorf("ultra light client mode enabled without valid servers")
+	}
+	// Three out of four trusted servers are needed to accept a head
+	hash := common.HexToHash("0x01")
+	fetcher := &lightFetcher{
+		pm:    &ProtocolManager{ulc: u},
+		peers: make(map[*peer]*fetcherPeerInfo),
+	}
+	announce := func(trusted bool) {
+		fetcher.peers[&peer{isTrusted: trusted}] = &fetcherPeerInfo{
+			nodeByHash: map[common.Hash]*fetcherTreeNode{hash: {hash: hash, td: big.NewInt(1)}},
+		}
+	}
+	for i := 0; i < 2; i++ {
+		announce(true)
+	}
+	for i := 0; i < 5; i++ {
+		announce(false)
+	}
+	if fetcher.trustedHead(hash) {
+		t.Fatalf("head accepted from too few trusted servers")
+	}
+	announce(true)
+	if !fetcher.trustedHead(hash) {
+		t.Fatalf("head rejected from enough trusted servers")
+	}
+	if fetcher.trustedHead(common.HexToHash("0x02")) {
+		t.Fatalf("unannounced head accepted")
+	}
+}
+
+// Tests that the total difficulty of a trusted head is taken from the trusted
+// servers and not from the untrusted peer serving its headers.
+func TestULCTrustedHeadTd(t *testing.T) {
+	u, _ := newTestULC(t, 3, 60)
+
+	db := ethdb.NewMemDatabase()
+	(&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	chain, err := light.NewLightChain(NewLesOdr(db, nil), params.TestChainConfig, ethash.NewFaker())
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	fetcher := &lightFetcher{
+		pm:    &ProtocolManager{ulc: u},
+		chain: chain,
+		peers: make(map[*peer]*fetcherPeerInfo),
+	}
+	head := &types.Header{Number: big.NewInt(100), ParentHash: common.HexToHash("0xdeadbeef"), Difficulty: big.NewInt(1)}
+	hash := head.Hash()
+
+	announce := func(trusted bool, td int64) *peer {
+		p := &peer{isTrusted: trusted}
+		fetcher.peers[p] = &fetcherPeerInfo{
+			nodeByHash: map[common.Hash]*fetcherTreeNode{hash: {hash: hash, number: 100, td: big.NewInt(td)}},
+		}
+		return p
+	}
+	// Trusted servers disagreeing on the difficulty don't make the head trusted
+	announce(true, 1000)
+	announce(true, 2000)
+	untrusted := announce(false, 1000000)
+
+	if fetcher.trustedHead(hash) {
+		t.Fatalf("head accepted without agreement on its difficulty")
+	}
+	req := fetchRequest{hash: hash, amount: 1, peer: untrusted}
+	if fetcher.insertTrustedHeaders(req, []*types.Header{head}) != nil {
+		t.Fatalf("head inserted without agreement on its difficulty")
+	}
+	// Once enough trusted servers agree, their difficulty is used for the head
+	announce(true, 1000)
+	if inserted := fetcher.insertTrustedHeaders(req, []*types.Header{head}); len(inserted) != 1 {
+		t.Fatalf("trusted head not inserted")
+	}
+	if td := chain.GetTd(hash, 100); td == nil || td.Int64() != 1000 {
+		t.Errorf("trusted head difficulty mismatch: have %v, want %d", td, 1000)
+	}
+}
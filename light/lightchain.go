@@ -118,7 +118,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		todo ##########################
 
 		*/
-		bc.addTrustedCheckpoint(cp)
+		bc.AddTrustedCheckpoint(cp)
 	}
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
@@ -134,7 +134,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	return bc, nil
 }
 
-// addTrustedCheckpoint adds a trusted checkpoint to the blockchain
+// AddTrustedCheckpoint adds a trusted checkpoint to the blockchain
 //
 /**
 todo ##########################
@@ -154,7 +154,7 @@ todo ##########################
 todo ##########################
 
  */
-func (self *LightChain) addTrustedCheckpoint(cp TrustedCheckpoint) {
+func (self *LightChain) AddTrustedCheckpoint(cp TrustedCheckpoint) {
 	if self.odr.ChtIndexer() != nil {
 
 		// 设置 CHT root
@@ -440,6 +440,27 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	return i, err
 }
 
+// InsertTrustedHead sets the head of the chain to a header vouched for by
+// trusted servers, using the total difficulty they announced. The ancestors of
+// the header are not required to be present locally, they are retrieved on
+// demand if needed.
+func (self *LightChain) InsertTrustedHead(header *types.Header, td *big.Int) {
+	self.chainmu.Lock()
+	defer self.chainmu.Unlock()
+
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	self.mu.Lock()
+	rawdb.WriteHeader(self.chainDb, header)
+	rawdb.WriteTd(self.chainDb, hash, number, td)
+	rawdb.WriteCanonicalHash(self.chainDb, hash, number)
+	self.hc.SetCurrentHeader(header)
+	self.mu.Unlock()
+
+	log.Info("Updated trusted head header", "number", number, "hash", hash)
+	self.postChainEvents([]interface{}{core.ChainEvent{Block: types.NewBlockWithHeader(header), Hash: hash}})
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (self *LightChain) CurrentHeader() *types.Header {
@@ -240,3 +240,21 @@ func (req *BloomRequest) StoreResult(db ethdb.Database) {
 		rawdb.WriteBloomBits(db, req.BitIdx, sectionIdx, sectionHead, req.BloomBits[i])
 	}
 }
+
+// TxStatus describes the status of a transaction as reported by a server.
+type TxStatus struct {
+	Status core.TxStatus
+	Lookup *rawdb.TxLookupEntry
+	Error  string
+}
+
+// TxStatusRequest is the ODR request type for retrieving the status of
+// transactions. The answers of multiple servers are reconciled by majority.
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *TxStatusRequest) StoreResult(db ethdb.Database) {}
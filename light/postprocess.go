@@ -29,6 +29,7 @@ import (
 	"github.com/go-ethereum-analysis/core"
 	"github.com/go-ethereum-analysis/core/rawdb"
 	"github.com/go-ethereum-analysis/core/types"
+	"github.com/go-ethereum-analysis/crypto"
 	"github.com/go-ethereum-analysis/ethdb"
 	"github.com/go-ethereum-analysis/log"
 	"github.com/go-ethereum-analysis/params"
@@ -81,6 +82,22 @@ type TrustedCheckpoint struct {
 	SectionHead, CHTRoot, BloomRoot common.Hash
 }
 
+// Hash returns the hash of the checkpoint's four key fields (index, sectionHead,
+// chtRoot and bloomTrieRoot), which is what the checkpoint oracle signers sign.
+func (c *TrustedCheckpoint) Hash() common.Hash {
+	buf := make([]byte, 8+3*common.HashLength)
+	binary.BigEndian.PutUint64(buf, c.SectionIdx)
+	copy(buf[8:], c.SectionHead.Bytes())
+	copy(buf[8+common.HashLength:], c.CHTRoot.Bytes())
+	copy(buf[8+2*common.HashLength:], c.BloomRoot.Bytes())
+	return crypto.Keccak256Hash(buf)
+}
+
+// Empty returns an indicator whether the checkpoint is regarded as empty.
+func (c *TrustedCheckpoint) Empty() bool {
+	return c.SectionHead == (common.Hash{}) || c.CHTRoot == (common.Hash{}) || c.BloomRoot == (common.Hash{})
+}
+
 // trustedCheckpoints associates each known checkpoint with the genesis hash of the chain it belongs to
 //
 // trustedCheckpoints: 将每个已知 checkpoint 与其所属 chain 的 genesis hash 关联
@@ -116,8 +133,8 @@ var (
 	/**
 	todo 轻节点的 规范 hash trie 相关的key前缀
 	 */
-	chtPrefix             = []byte("chtRoot-") // chtPrefix + chtNum (uint64 big endian) -> trie root hash
-	ChtTablePrefix        = "cht-"  // todo 规范hash trie 的相关node 的key的prefix
+	chtPrefix             = rawdb.ChtPrefix              // chtPrefix + chtNum (uint64 big endian) -> trie root hash
+	ChtTablePrefix        = string(rawdb.ChtTablePrefix) // todo 规范hash trie 的相关node 的key的prefix
 )
 
 // ChtNode structures are stored in the Canonical Hash Trie in an RLP encoded format
@@ -204,7 +221,7 @@ func NewChtIndexer(db ethdb.Database, clientMode bool, odr OdrBackend) *core.Cha
 		// 需要 256 块才算确认
 		confirmReq = HelperTrieProcessConfirmations
 	}
-	idb := ethdb.NewTable(db, "chtIndex-")
+	idb := ethdb.NewTable(db, string(rawdb.ChtIndexTablePrefix))
 	trieTable := ethdb.NewTable(db, ChtTablePrefix)
 
 	/**
@@ -336,8 +353,8 @@ const (
 )
 
 var (
-	bloomTriePrefix      = []byte("bltRoot-") // bloomTriePrefix + bloomTrieNum (uint64 big endian) -> trie root hash
-	BloomTrieTablePrefix = "blt-"
+	bloomTriePrefix      = rawdb.BloomTriePrefix              // bloomTriePrefix + bloomTrieNum (uint64 big endian) -> trie root hash
+	BloomTrieTablePrefix = string(rawdb.BloomTrieTablePrefix)
 )
 
 // GetBloomTrieRoot reads the BloomTrie root assoctiated to the given section from the database
@@ -395,7 +412,7 @@ func NewBloomTrieIndexer(db ethdb.Database, clientMode bool, odr OdrBackend) *co
 		trieTable: trieTable,
 		triedb:    trie.NewDatabase(trieTable),
 	}
-	idb := ethdb.NewTable(db, "bltIndex-")
+	idb := ethdb.NewTable(db, string(rawdb.BloomTrieIndexPrefix))
 
 	if clientMode {
 
@@ -18,17 +18,20 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-ethereum-analysis/accounts"
 	"github.com/go-ethereum-analysis/accounts/keystore"
 	"github.com/go-ethereum-analysis/accounts/usbwallet"
 	"github.com/go-ethereum-analysis/common"
+	"github.com/go-ethereum-analysis/common/hexutil"
 	"github.com/go-ethereum-analysis/crypto"
 	"github.com/go-ethereum-analysis/log"
 	"github.com/go-ethereum-analysis/p2p"
@@ -42,6 +45,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the authenticated RPC secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -67,6 +71,10 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DatabaseEngine selects the key-value store backing the persistent databases
+	// in DataDir. If empty, ethdb.DefaultEngine (LevelDB) is used.
+	DatabaseEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -120,17 +128,27 @@ type Config struct {
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// HTTPMethods is a list of method patterns restricting the methods of the
+	// exposed modules, e.g. "eth_*" to allow and "!eth_sign*" to deny methods.
+	// If empty, all methods of the exposed modules are served.
+	HTTPMethods []string `toml:",omitempty"`
+
 	// HTTPTimeouts allows for customization of the timeout values used by the HTTP RPC
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
 
+	// HTTPLimits bounds the batch size, response size and execution time of the
+	// requests served by the HTTP RPC interface.
+	HTTPLimits rpc.Limits
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
 
 	// WSPort is the TCP port number on which to start the websocket RPC server. The
 	// default zero value is/ valid and will pick a port number randomly (useful for
-	// ephemeral nodes).
+	// ephemeral nodes). If the websocket endpoint equals the HTTP endpoint, both
+	// are served from the same listener.
 	WSPort int `toml:",omitempty"`
 
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
@@ -143,6 +161,10 @@ type Config struct {
 	// exposed.
 	WSModules []string `toml:",omitempty"`
 
+	// WSMethods is a list of method patterns restricting the methods of the
+	// modules exposed via the websocket RPC interface, see HTTPMethods.
+	WSMethods []string `toml:",omitempty"`
+
 	// WSExposeAll exposes all API modules via the WebSocket RPC interface rather
 	// than just the public ones.
 	//
@@ -150,6 +172,45 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// WSLimits bounds the batch size, response size and execution time of the
+	// requests served by the websocket RPC interface.
+	WSLimits rpc.Limits
+
+	// AuthHost is the host interface on which to start the authenticated RPC
+	// server, serving both HTTP and websocket requests. If this field is empty,
+	// no authenticated endpoint will be started.
+	AuthHost string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated RPC
+	// server.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthVirtualHosts is the list of virtual hostnames which are allowed on
+	// incoming authenticated requests.
+	AuthVirtualHosts []string `toml:",omitempty"`
+
+	// AuthModules is a list of API modules to expose via the authenticated RPC
+	// interface. If the module list is empty, all RPC API endpoints designated
+	// public will be exposed.
+	AuthModules []string `toml:",omitempty"`
+
+	// AuthMethods is a list of method patterns restricting the methods of the
+	// modules exposed via the authenticated RPC interface, see HTTPMethods.
+	AuthMethods []string `toml:",omitempty"`
+
+	// AuthLimits bounds the batch size, response size and execution time of the
+	// requests served by the authenticated RPC interface.
+	AuthLimits rpc.Limits
+
+	// JWTSecret is the path to the hex encoded 32 byte secret the JSON Web Tokens
+	// of authenticated RPC requests must be signed with. If empty, a secret file is
+	// generated in the data directory.
+	JWTSecret string `toml:",omitempty"`
+
+	// RPCSlowThreshold is the duration after which RPC method calls are logged as
+	// slow on all endpoints. Zero disables slow request logging.
+	RPCSlowThreshold time.Duration `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -229,6 +290,21 @@ func DefaultWSEndpoint() string {
 	return config.WSEndpoint()
 }
 
+// AuthEndpoint resolves the authenticated RPC endpoint based on the configured
+// host interface and port parameters.
+func (c *Config) AuthEndpoint() string {
+	if c.AuthHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.AuthHost, c.AuthPort)
+}
+
+// DefaultAuthEndpoint returns the authenticated RPC endpoint used by default.
+func DefaultAuthEndpoint() string {
+	config := &Config{AuthHost: DefaultAuthHost, AuthPort: DefaultAuthPort}
+	return config.AuthEndpoint()
+}
+
 // NodeName returns the devp2p node identifier.
 func (c *Config) NodeName() string {
 	name := c.name()
@@ -335,6 +411,59 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// JWTSecretKey retrieves the secret authenticated RPC requests must be signed
+// with, generating and persisting a new one if none exists yet. If the secret
+// was saved into a temporary file, the path of that file is also returned and
+// it's up to the caller to remove it.
+func (c *Config) JWTSecretKey() ([]byte, string, error) {
+	path := c.JWTSecret
+	if path == "" && c.DataDir != "" {
+		path = c.ResolvePath(datadirJWTSecret)
+	}
+	if path != "" {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			secret := common.FromHex(strings.TrimSpace(string(data)))
+			if len(secret) != 32 {
+				return nil, "", fmt.Errorf("invalid JWT secret in %s: need 32 hex encoded bytes", path)
+			}
+			return secret, "", nil
+		} else if !os.IsNotExist(err) {
+			return nil, "", err
+		}
+	}
+	// No secret found, generate a new one and persist it if not ephemeral
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	if path == "" {
+		// Ephemeral node, the secret still needs to reach the clients, but it
+		// must never end up in the logs. Temporary files are private (0600).
+		file, err := ioutil.TempFile("", datadirJWTSecret)
+		if err != nil {
+			return nil, "", err
+		}
+		_, err = file.WriteString(hexutil.Encode(secret))
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return nil, "", err
+		}
+		log.Warn("Generated ephemeral JWT secret", "path", file.Name())
+		return secret, file.Name(), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, "", err
+	}
+	if err := ioutil.WriteFile(path, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, "", err
+	}
+	log.Info("Generated JWT secret", "path", path)
+	return secret, "", nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.ResolvePath(datadirStaticNodes))
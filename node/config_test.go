@@ -148,3 +148,37 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that a generated JWT secret is persisted privately into the data
+// directory and loaded back on subsequent runs.
+func TestJWTSecretPersistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretfile := filepath.Join(dir, "unit-test", datadirJWTSecret)
+
+	// Configure a node with no secret and ensure one is generated and persisted
+	config := &Config{Name: "unit-test", DataDir: dir}
+	secret1, _, err := config.JWTSecretKey()
+	if err != nil {
+		t.Fatalf("failed to generate JWT secret: %v", err)
+	}
+	info, err := os.Stat(secretfile)
+	if err != nil {
+		t.Fatalf("JWT secret not persisted to data directory: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("JWT secret file permissions mismatch: have %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+	// Configure a new node and ensure the previously persisted secret is loaded
+	secret2, _, err := (&Config{Name: "unit-test", DataDir: dir}).JWTSecretKey()
+	if err != nil {
+		t.Fatalf("failed to load persisted JWT secret: %v", err)
+	}
+	if !bytes.Equal(secret1, secret2) {
+		t.Fatalf("persisted JWT secret mismatch: have %x, want %x", secret2, secret1)
+	}
+}
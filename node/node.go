@@ -67,6 +67,11 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	authEndpoint string       // Authenticated RPC endpoint (interface + port) to listen at (empty = disabled)
+	authListener net.Listener // Authenticated RPC listener socket to serve HTTP and websocket requests
+	authHandler  *rpc.Server  // Authenticated RPC request handler to process the API requests
+	authSecret   string       // if non-empty, the temporary JWT secret file that will be removed by stopAuth
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -135,6 +140,8 @@ func New(conf *Config) (*Node, error) {
 		httpEndpoint:      conf.HTTPEndpoint(),
 		// WS 端点
 		wsEndpoint:        conf.WSEndpoint(),
+		// 需要 JWT 认证的端点
+		authEndpoint:      conf.AuthEndpoint(),
 		// 这个是一个事件管理相关的
 		// 后续都是用 feed
 		eventmux:          new(event.TypeMux),
@@ -301,6 +308,20 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	// Parse the method filters of the network facing endpoints, they need to be
+	// in place before the endpoints start serving
+	httpFilter, err := rpc.NewMethodFilter(n.config.HTTPMethods)
+	if err != nil {
+		return err
+	}
+	wsFilter, err := rpc.NewMethodFilter(n.config.WSMethods)
+	if err != nil {
+		return err
+	}
+	authFilter, err := rpc.NewMethodFilter(n.config.AuthMethods)
+	if err != nil {
+		return err
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -309,12 +330,28 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
-		n.stopIPC()
-		n.stopInProc()
-		return err
+	if n.httpEndpoint != "" && n.httpEndpoint == n.wsEndpoint {
+		// HTTP and websocket configured on the same endpoint, share the listener
+		if err := n.startHTTPWS(n.httpEndpoint, apis, n.config.HTTPModules, httpFilter, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts, n.config.WSModules, wsFilter, n.config.WSOrigins, n.config.WSExposeAll); err != nil {
+			n.stopIPC()
+			n.stopInProc()
+			return err
+		}
+	} else {
+		if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, httpFilter, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
+			n.stopIPC()
+			n.stopInProc()
+			return err
+		}
+		if err := n.startWS(n.wsEndpoint, apis, n.config.WSModules, wsFilter, n.config.WSOrigins, n.config.WSExposeAll); err != nil {
+			n.stopHTTP()
+			n.stopIPC()
+			n.stopInProc()
+			return err
+		}
 	}
-	if err := n.startWS(n.wsEndpoint, apis, n.config.WSModules, n.config.WSOrigins, n.config.WSExposeAll); err != nil {
+	if err := n.startAuth(n.authEndpoint, apis, n.config.AuthModules, authFilter, n.config.AuthVirtualHosts, n.config.HTTPTimeouts); err != nil {
+		n.stopWS()
 		n.stopHTTP()
 		n.stopIPC()
 		n.stopInProc()
@@ -325,6 +362,13 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	return nil
 }
 
+// configureHandler applies the request limits and the slow request threshold
+// to the handler of a freshly started RPC endpoint.
+func (n *Node) configureHandler(handler *rpc.Server, limits rpc.Limits) {
+	handler.SetLimits(limits)
+	handler.SetSlowRequestThreshold(n.config.RPCSlowThreshold)
+}
+
 // startInProc initializes an in-process RPC endpoint.
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
@@ -335,6 +379,7 @@ func (n *Node) startInProc(apis []rpc.API) error {
 		}
 		n.log.Debug("InProc registered", "service", api.Service, "namespace", api.Namespace)
 	}
+	n.configureHandler(handler, rpc.Limits{})
 	n.inprocHandler = handler
 	return nil
 }
@@ -357,6 +402,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 		return err
 	}
 	n.ipcListener = listener
+	n.configureHandler(handler, rpc.Limits{})
 	n.ipcHandler = handler
 	n.log.Info("IPC endpoint opened", "url", n.ipcEndpoint)
 	return nil
@@ -377,12 +423,12 @@ func (n *Node) stopIPC() {
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
+func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, filter *rpc.MethodFilter, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, filter, cors, vhosts, timeouts)
 	if err != nil {
 		return err
 	}
@@ -390,6 +436,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
+	n.configureHandler(handler, n.config.HTTPLimits)
 	n.httpHandler = handler
 
 	return nil
@@ -409,13 +456,35 @@ func (n *Node) stopHTTP() {
 	}
 }
 
+// startHTTPWS initializes and starts the HTTP and websocket RPC endpoints on a
+// single listener. The listener is owned by the HTTP endpoint, stopping the
+// websocket endpoint only tears down its handler.
+func (n *Node) startHTTPWS(endpoint string, apis []rpc.API, httpModules []string, httpFilter *rpc.MethodFilter, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts, wsModules []string, wsFilter *rpc.MethodFilter, wsOrigins []string, exposeAll bool) error {
+	listener, httpHandler, wsHandler, err := rpc.StartHTTPWSEndpoint(endpoint, apis, httpModules, httpFilter, cors, vhosts, timeouts, wsModules, wsFilter, wsOrigins, exposeAll)
+	if err != nil {
+		return err
+	}
+	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
+	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
+	// All listeners booted successfully
+	n.httpEndpoint = endpoint
+	n.httpListener = listener
+	n.configureHandler(httpHandler, n.config.HTTPLimits)
+	n.httpHandler = httpHandler
+	n.wsEndpoint = endpoint
+	n.configureHandler(wsHandler, n.config.WSLimits)
+	n.wsHandler = wsHandler
+
+	return nil
+}
+
 // startWS initializes and starts the websocket RPC endpoint.
-func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrigins []string, exposeAll bool) error {
+func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, filter *rpc.MethodFilter, wsOrigins []string, exposeAll bool) error {
 	// Short circuit if the WS endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, filter, wsOrigins, exposeAll)
 	if err != nil {
 		return err
 	}
@@ -423,6 +492,7 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
 	n.wsListener = listener
+	n.configureHandler(handler, n.config.WSLimits)
 	n.wsHandler = handler
 
 	return nil
@@ -442,6 +512,56 @@ func (n *Node) stopWS() {
 	}
 }
 
+// startAuth initializes and starts the authenticated HTTP and websocket RPC
+// endpoint.
+func (n *Node) startAuth(endpoint string, apis []rpc.API, modules []string, filter *rpc.MethodFilter, vhosts []string, timeouts rpc.HTTPTimeouts) error {
+	// Short circuit if the authenticated endpoint isn't being exposed
+	if endpoint == "" {
+		return nil
+	}
+	secret, ephemeral, err := n.config.JWTSecretKey()
+	if err != nil {
+		return err
+	}
+	listener, handler, err := rpc.StartAuthEndpoint(endpoint, apis, modules, filter, vhosts, secret, timeouts)
+	if err != nil {
+		if ephemeral != "" {
+			os.Remove(ephemeral)
+		}
+		return err
+	}
+	n.log.Info("Authenticated RPC endpoint opened", "http", fmt.Sprintf("http://%s", listener.Addr()), "ws", fmt.Sprintf("ws://%s", listener.Addr()), "vhosts", strings.Join(vhosts, ","))
+	// All listeners booted successfully
+	n.authEndpoint = endpoint
+	n.authListener = listener
+	n.configureHandler(handler, n.config.AuthLimits)
+	n.authHandler = handler
+	n.authSecret = ephemeral
+
+	return nil
+}
+
+// stopAuth terminates the authenticated RPC endpoint.
+func (n *Node) stopAuth() {
+	if n.authListener != nil {
+		n.authListener.Close()
+		n.authListener = nil
+
+		n.log.Info("Authenticated RPC endpoint closed", "url", fmt.Sprintf("http://%s", n.authEndpoint))
+	}
+	if n.authHandler != nil {
+		n.authHandler.Stop()
+		n.authHandler = nil
+	}
+	// Remove the JWT secret if it was created ephemerally
+	if n.authSecret != "" {
+		if err := os.Remove(n.authSecret); err != nil && !os.IsNotExist(err) {
+			n.log.Warn("Failed to remove ephemeral JWT secret", "path", n.authSecret, "err", err)
+		}
+		n.authSecret = ""
+	}
+}
+
 // Stop terminates a running node along with all it's services. In the node was
 // not started, an error is returned.
 func (n *Node) Stop() error {
@@ -454,6 +574,7 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopAuth()
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
@@ -603,6 +724,12 @@ func (n *Node) WSEndpoint() string {
 	return n.wsEndpoint
 }
 
+// AuthEndpoint retrieves the current authenticated RPC endpoint used by the
+// protocol stack.
+func (n *Node) AuthEndpoint() string {
+	return n.authEndpoint
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -616,7 +743,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (ethdb.Database, er
 	if n.config.DataDir == "" {
 		return ethdb.NewMemDatabase(), nil
 	}
-	return ethdb.NewLDBDatabase(n.config.ResolvePath(name), cache, handles)
+	return ethdb.Open(n.config.DatabaseEngine, n.config.ResolvePath(name), cache, handles)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
@@ -74,6 +74,41 @@ func TestNodeLifeCycle(t *testing.T) {
 	}
 }
 
+// Tests that the JWT secret generated for an ephemeral node is removed when the
+// node is stopped.
+func TestNodeEphemeralJWTSecret(t *testing.T) {
+	keydir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary keystore directory: %v", err)
+	}
+	defer os.RemoveAll(keydir)
+
+	config := testNodeConfig()
+	config.KeyStoreDir = keydir
+	config.AuthHost = "127.0.0.1"
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	secretfile := stack.authSecret
+	if secretfile == "" {
+		t.Fatalf("no ephemeral JWT secret generated")
+	}
+	if _, err := os.Stat(secretfile); err != nil {
+		t.Fatalf("ephemeral JWT secret missing: %v", err)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop node: %v", err)
+	}
+	if _, err := os.Stat(secretfile); !os.IsNotExist(err) {
+		t.Fatalf("ephemeral JWT secret not removed: %v", err)
+	}
+}
+
 // Tests that if the data dir is already in use, an appropriate error is returned.
 func TestNodeUsedDataDir(t *testing.T) {
 	// Create a temporary folder to use as the data directory
@@ -44,8 +44,8 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	if ctx.config.DataDir == "" {
 		return ethdb.NewMemDatabase(), nil
 	}
-	// 否则，使用 磁盘 db
-	db, err := ethdb.NewLDBDatabase(ctx.config.ResolvePath(name), cache, handles)
+	// 否则，使用 配置的引擎 打开 磁盘 db
+	db, err := ethdb.Open(ctx.config.DatabaseEngine, ctx.config.ResolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
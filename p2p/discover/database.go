@@ -42,6 +42,7 @@ var (
 	nodeDBNilNodeID      = NodeID{}       // Special node ID to use as a nil element.
 	nodeDBNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
 	nodeDBCleanupCycle   = time.Hour      // Time period for running the expiration task.
+	nodeDBScoreHalfLife  = time.Hour      // Time period after which a stored reputation score is halved.
 	nodeDBVersion        = 5
 )
 
@@ -62,6 +63,10 @@ var (
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong      = nodeDBDiscoverRoot + ":lastpong"
 	nodeDBDiscoverFindFails = nodeDBDiscoverRoot + ":findfail"
+
+	nodeDBReputationRoot  = ":reputation"
+	nodeDBReputationScore = nodeDBReputationRoot + ":score"
+	nodeDBReputationTime  = nodeDBReputationRoot + ":updated"
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -242,7 +247,9 @@ func (db *nodeDB) expirer() {
 }
 
 // expireNodes iterates over the database and deletes all nodes that have not
-// been seen (i.e. received a pong from) for some allotted time.
+// been seen (i.e. received a pong from) for some allotted time. Reputation
+// scores not updated for the same allotted time are dropped too, even if the
+// node was never discovered (e.g. peers only connecting inbound).
 func (db *nodeDB) expireNodes() error {
 	threshold := time.Now().Add(-nodeDBNodeExpiration)
 
@@ -251,8 +258,14 @@ func (db *nodeDB) expireNodes() error {
 	defer it.Release()
 
 	for it.Next() {
-		// Skip the item if not a discovery node
 		id, field := splitKey(it.Key())
+		if field == nodeDBReputationScore {
+			if db.reputationUpdated(id).Before(threshold) {
+				db.updateReputation(id, 0)
+			}
+			continue
+		}
+		// Skip the item if not a discovery node
 		if field != nodeDBDiscoverRoot {
 			continue
 		}
@@ -303,6 +316,42 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// reputation retrieves the persisted reputation score of a node. The score
+// decays towards zero, halving every nodeDBScoreHalfLife since it was stored.
+func (db *nodeDB) reputation(id NodeID) int64 {
+	score := db.fetchInt64(makeKey(id, nodeDBReputationScore))
+	if score == 0 {
+		return 0
+	}
+	halvings := uint(time.Since(db.reputationUpdated(id)) / nodeDBScoreHalfLife)
+	if halvings >= 63 {
+		return 0
+	}
+	return score / (1 << halvings)
+}
+
+// reputationUpdated retrieves the time the reputation score of a node was last
+// stored.
+func (db *nodeDB) reputationUpdated(id NodeID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, nodeDBReputationTime)), 0)
+}
+
+// updateReputation persists the reputation score of a node along with the
+// current time. A zero score is the default for unknown nodes, so it removes
+// the entry instead.
+func (db *nodeDB) updateReputation(id NodeID, score int64) error {
+	if score == 0 {
+		if err := db.lvl.Delete(makeKey(id, nodeDBReputationTime), nil); err != nil {
+			return err
+		}
+		return db.lvl.Delete(makeKey(id, nodeDBReputationScore), nil)
+	}
+	if err := db.storeInt64(makeKey(id, nodeDBReputationTime), time.Now().Unix()); err != nil {
+		return err
+	}
+	return db.storeInt64(makeKey(id, nodeDBReputationScore), score)
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
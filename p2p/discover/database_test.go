@@ -144,6 +144,22 @@ func TestNodeDBFetchStore(t *testing.T) {
 	if stored := db.findFails(node.ID); stored != num {
 		t.Errorf("find-node fails: value mismatch: have %v, want %v", stored, num)
 	}
+	// Check fetch/store operations on a node reputation object
+	if stored := db.reputation(node.ID); stored != 0 {
+		t.Errorf("reputation: non-existing object: %v", stored)
+	}
+	if err := db.updateReputation(node.ID, -int64(num)); err != nil {
+		t.Errorf("reputation: failed to update: %v", err)
+	}
+	if stored := db.reputation(node.ID); stored != -int64(num) {
+		t.Errorf("reputation: value mismatch: have %v, want %v", stored, -num)
+	}
+	if err := db.updateReputation(node.ID, 0); err != nil {
+		t.Errorf("reputation: failed to reset: %v", err)
+	}
+	if stored := db.reputation(node.ID); stored != 0 {
+		t.Errorf("reputation: value not reset: %v", stored)
+	}
 	// Check fetch/store operations on an actual node object
 	if stored := db.node(node.ID); stored != nil {
 		t.Errorf("node: non-existing object: %v", stored)
@@ -348,6 +364,38 @@ func TestNodeDBExpiration(t *testing.T) {
 	}
 }
 
+func TestNodeDBReputationDecay(t *testing.T) {
+	db, _ := newNodeDB("", nodeDBVersion, NodeID{})
+	defer db.close()
+
+	// A fresh score is reported as is, an older one halved per elapsed half-life
+	id := MustHexID("0x1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439")
+	if err := db.updateReputation(id, -400); err != nil {
+		t.Fatalf("failed to store reputation: %v", err)
+	}
+	if score := db.reputation(id); score != -400 {
+		t.Errorf("fresh score mismatch: have %d, want %d", score, -400)
+	}
+	db.storeInt64(makeKey(id, nodeDBReputationTime), time.Now().Add(-2*nodeDBScoreHalfLife-time.Minute).Unix())
+	if score := db.reputation(id); score != -100 {
+		t.Errorf("decayed score mismatch: have %d, want %d", score, -100)
+	}
+	// Scores of nodes never discovered are dropped once stale
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if _, err := db.lvl.Get(makeKey(id, nodeDBReputationScore), nil); err != nil {
+		t.Errorf("recent score expired: %v", err)
+	}
+	db.storeInt64(makeKey(id, nodeDBReputationTime), time.Now().Add(-nodeDBNodeExpiration-time.Minute).Unix())
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if _, err := db.lvl.Get(makeKey(id, nodeDBReputationScore), nil); err == nil {
+		t.Errorf("stale score not expired")
+	}
+}
+
 func TestNodeDBSelfExpiration(t *testing.T) {
 	// Find a node in the tests that shouldn't expire, and assign it as self
 	var self NodeID
@@ -199,6 +199,18 @@ func (tab *Table) Close() {
 	}
 }
 
+// Reputation returns the persisted reputation score of the given node.
+func (tab *Table) Reputation(id NodeID) int64 {
+	return tab.db.reputation(id)
+}
+
+// UpdateReputation persists the reputation score of the given node, making it
+// survive reconnects and restarts. Stored scores decay over time and are
+// dropped once they haven't been updated for the node expiration period.
+func (tab *Table) UpdateReputation(id NodeID, score int64) error {
+	return tab.db.updateReputation(id, score)
+}
+
 // setFallbackNodes sets the initial points of contact. These nodes
 // are used to connect to the network if the table is empty and there
 // are no known nodes in the database.
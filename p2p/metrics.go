@@ -29,6 +29,12 @@ var (
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
+
+	rejectedRestrictMeter    = metrics.NewRegisteredMeter("p2p/RejectedInbound/Restrict", nil)
+	rejectedThrottleMeter    = metrics.NewRegisteredMeter("p2p/RejectedInbound/Throttle", nil)
+	rejectedRatioMeter       = metrics.NewRegisteredMeter("p2p/RejectedInbound/Ratio", nil)
+	rejectedIPLimitMeter     = metrics.NewRegisteredMeter("p2p/RejectedInbound/IPLimit", nil)
+	rejectedSubnetLimitMeter = metrics.NewRegisteredMeter("p2p/RejectedInbound/SubnetLimit", nil)
 )
 
 // meteredConn is a wrapper around a net.Conn that meters both the
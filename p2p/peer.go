@@ -23,6 +23,7 @@ import (
 	"net"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-ethereum-analysis/common/mclock"
@@ -44,6 +45,10 @@ const (
 	snappyProtocolVersion = 5
 
 	pingInterval = 15 * time.Second
+
+	// Bounds of the reputation score sub-protocols can build up for a peer.
+	maxPeerScore = 1000
+	minPeerScore = -1000
 )
 
 const (
@@ -100,6 +105,8 @@ type PeerEvent struct {
 
 // Peer represents a connected remote node.
 type Peer struct {
+	score int64 // Reputation score of the peer, accessed atomically (keep first for 64bit alignment)
+
 	// 一个和远端该节点的链接实例
 	rw      *conn
 
@@ -171,6 +178,31 @@ func (p *Peer) Inbound() bool {
 	return p.rw.is(inboundConn)
 }
 
+// Score returns the current reputation score of the peer.
+func (p *Peer) Score() int64 {
+	return atomic.LoadInt64(&p.score)
+}
+
+// AdjustScore adds delta to the reputation score of the peer, capping the
+// result between minPeerScore and maxPeerScore. Sub-protocols use it to reward
+// useful peers and to penalize misbehaving ones; when the server is full, the
+// lowest scoring peers are periodically evicted to make room for new ones.
+func (p *Peer) AdjustScore(delta int64) {
+	for {
+		old := atomic.LoadInt64(&p.score)
+		score := old + delta
+		if score > maxPeerScore {
+			score = maxPeerScore
+		}
+		if score < minPeerScore {
+			score = minPeerScore
+		}
+		if atomic.CompareAndSwapInt64(&p.score, old, score) {
+			return
+		}
+	}
+}
+
 func newPeer(conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
 	p := &Peer{
@@ -430,6 +462,7 @@ type PeerInfo struct {
 		Trusted       bool   `json:"trusted"`  // 节点的信任标识
 		Static        bool   `json:"static"`
 	} `json:"network"`
+	Score     int64                  `json:"score"`     // Reputation score accumulated by the sub-protocols
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
 
@@ -445,6 +478,7 @@ func (p *Peer) Info() *PeerInfo {
 		ID:        p.ID().String(),
 		Name:      p.Name(),
 		Caps:      caps,
+		Score:     p.Score(),
 		Protocols: make(map[string]interface{}),
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
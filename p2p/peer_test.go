@@ -215,6 +215,29 @@ func TestNewPeer(t *testing.T) {
 	p.Disconnect(DiscAlreadyConnected) // Should not hang
 }
 
+func TestPeerScore(t *testing.T) {
+	p := NewPeer(randomID(), "nodename", nil)
+	if score := p.Score(); score != 0 {
+		t.Fatalf("initial score mismatch: got %d, expected 0", score)
+	}
+	p.AdjustScore(10)
+	p.AdjustScore(-25)
+	if score := p.Score(); score != -15 {
+		t.Errorf("score mismatch: got %d, expected -15", score)
+	}
+	p.AdjustScore(3 * maxPeerScore)
+	if score := p.Score(); score != maxPeerScore {
+		t.Errorf("score not capped: got %d, expected %d", score, maxPeerScore)
+	}
+	p.AdjustScore(3 * minPeerScore)
+	if score := p.Score(); score != minPeerScore {
+		t.Errorf("score not floored: got %d, expected %d", score, minPeerScore)
+	}
+	if info := p.Info(); info.Score != minPeerScore {
+		t.Errorf("info score mismatch: got %d, expected %d", info.Score, minPeerScore)
+	}
+}
+
 func TestMatchProtocols(t *testing.T) {
 	tests := []struct {
 		Remote []Cap
@@ -19,6 +19,7 @@ package p2p
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -502,6 +503,78 @@ var eip8HandshakeRespTests = []handshakeAckTest{
 	},
 }
 
+// newTestFrameRWPair creates two frame readers/writers sharing an in-memory
+// connection, with matching secrets so that one can read what the other wrote.
+func newTestFrameRWPair() (*rlpxFrameRW, *rlpxFrameRW) {
+	var (
+		aesSecret      = make([]byte, 16)
+		macSecret      = make([]byte, 16)
+		egressMACinit  = make([]byte, 32)
+		ingressMACinit = make([]byte, 32)
+	)
+	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
+		rand.Read(s)
+	}
+	conn := new(bytes.Buffer)
+
+	s1 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewKeccak256(), IngressMAC: sha3.NewKeccak256()}
+	s1.EgressMAC.Write(egressMACinit)
+	s1.IngressMAC.Write(ingressMACinit)
+
+	s2 := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewKeccak256(), IngressMAC: sha3.NewKeccak256()}
+	s2.EgressMAC.Write(ingressMACinit)
+	s2.IngressMAC.Write(egressMACinit)
+
+	return newRLPXFrameRW(conn, s1), newRLPXFrameRW(conn, s2)
+}
+
+func TestRLPXFrameRWSnappy(t *testing.T) {
+	rw1, rw2 := newTestFrameRWPair()
+	rw1.snappy, rw2.snappy = true, true
+
+	// Highly redundant payloads must round trip and shrink on the wire
+	wmsg := []interface{}{strings.Repeat("block body ", 4096)}
+	wantPayload, _ := rlp.EncodeToBytes(wmsg)
+
+	if err := Send(rw1, 0x10, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if wire := rw1.conn.(*bytes.Buffer).Len(); wire >= len(wantPayload) {
+		t.Errorf("payload not compressed: %d bytes on the wire, %d plain", wire, len(wantPayload))
+	}
+	msg, err := rw2.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Code != 0x10 {
+		t.Fatalf("msg code mismatch: got %d, want %d", msg.Code, 0x10)
+	}
+	if msg.Size != uint32(len(wantPayload)) {
+		t.Errorf("msg size mismatch: got %d, want %d", msg.Size, len(wantPayload))
+	}
+	payload, _ := ioutil.ReadAll(msg.Payload)
+	if !bytes.Equal(payload, wantPayload) {
+		t.Fatalf("msg payload mismatch")
+	}
+}
+
+func TestRLPXFrameRWSnappyBomb(t *testing.T) {
+	rw1, rw2 := newTestFrameRWPair()
+	rw2.snappy = true
+
+	// Hand craft a snappy block claiming a decompressed size above the limit
+	bomb := make([]byte, binary.MaxVarintLen64)
+	bomb = bomb[:binary.PutUvarint(bomb, uint64(maxUint24)+1)]
+	bomb = append(bomb, make([]byte, 16)...)
+
+	if err := rw1.WriteMsg(Msg{Code: 0x10, Size: uint32(len(bomb)), Payload: bytes.NewReader(bomb)}); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if _, err := rw2.ReadMsg(); err != errPlainMessageTooLarge {
+		t.Fatalf("decompression bomb not rejected: have %v, want %v", err, errPlainMessageTooLarge)
+	}
+}
+
 func TestHandshakeForwardCompatibility(t *testing.T) {
 	var (
 		keyA, _       = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
@@ -50,6 +50,15 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Interval at which the lowest scoring peer is evicted if the server is full.
+	peerEvictionInterval = 30 * time.Second
+
+	// Reputation score at or below which reconnecting peers are refused.
+	peerBanScore = minPeerScore / 2
+
+	// Minimum time between two inbound connection attempts from the same IP.
+	inboundThrottleTime = 30 * time.Second
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -73,6 +82,14 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// MaxInboundPerIP is the maximum number of inbound peers which can be
+	// connected from the same IP address. Zero means no limit.
+	MaxInboundPerIP int `toml:",omitempty"`
+
+	// MaxInboundPerSubnet is the maximum number of inbound peers which can be
+	// connected from the same /24 (IPv4) or /64 (IPv6) subnet. Zero means no limit.
+	MaxInboundPerSubnet int `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
@@ -163,6 +180,8 @@ type Server struct {
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
 
+	inboundHistory expHeap // Recent inbound connection attempts, used by listenLoop only
+
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
 	peerOpDone chan struct{}
@@ -182,6 +201,13 @@ type Server struct {
 
 type peerOpFunc func(map[discover.NodeID]*Peer)
 
+// reputationStore is implemented by discovery tables which can persist the
+// reputation scores of peers across connections.
+type reputationStore interface {
+	Reputation(id discover.NodeID) int64
+	UpdateReputation(id discover.NodeID, score int64) error
+}
+
 type peerDrop struct {
 	*Peer
 	err       error
@@ -252,6 +278,15 @@ func (f connFlag) String() string {
 	return s
 }
 
+// remoteIP returns the IP address of the remote end, or nil if the connection
+// is not a TCP one.
+func (c *conn) remoteIP() net.IP {
+	if tcp, ok := c.fd.RemoteAddr().(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return nil
+}
+
 func (c *conn) is(f connFlag) bool {
 	flags := connFlag(atomic.LoadInt32((*int32)(&c.flags)))
 	return flags&f != 0
@@ -583,7 +618,10 @@ func (srv *Server) run(dialstate dialer) {
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
+		evict        = time.NewTicker(peerEvictionInterval)
 	)
+	defer evict.Stop()
+
 	// Put trusted nodes into a map to speed up checks.
 	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
 	for _, n := range srv.TrustedNodes {
@@ -663,6 +701,15 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.rw.set(trustedConn, false)
 			}
+		case <-evict.C:
+			// Make room for fresh peers by disconnecting the worst
+			// behaving one, but only if we're out of slots anyway.
+			if len(peers) >= srv.MaxPeers {
+				if p := lowestScoringPeer(peers); p != nil {
+					p.log.Debug("Evicting low reputation peer", "score", p.Score())
+					p.Disconnect(DiscUselessPeer)
+				}
+			}
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -694,6 +741,7 @@ running:
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.score = srv.reputation(c.id)
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -723,6 +771,7 @@ running:
 			if pd.Inbound() {
 				inboundCount--
 			}
+			srv.storeReputation(pd.Peer)
 		}
 	}
 
@@ -764,16 +813,107 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
+		rejectedRatioMeter.Mark(1)
+		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && !srv.inboundNetAllowed(peers, c):
 		return DiscTooManyPeers
 	case peers[c.id] != nil:
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case !c.is(trustedConn|staticDialedConn) && srv.reputation(c.id) <= peerBanScore:
+		return DiscUselessPeer
 	default:
 		return nil
 	}
 }
 
+// reputation returns the persisted reputation score of a node, or zero if the
+// node database is not available.
+func (srv *Server) reputation(id discover.NodeID) int64 {
+	if store, ok := srv.ntab.(reputationStore); ok {
+		return store.Reputation(id)
+	}
+	return 0
+}
+
+// storeReputation persists the score of a disconnecting peer. Only negative
+// scores are kept, good behaviour has to be proven again on every connection.
+func (srv *Server) storeReputation(p *Peer) {
+	store, ok := srv.ntab.(reputationStore)
+	if !ok {
+		return
+	}
+	score := p.Score()
+	if score > 0 {
+		score = 0
+	}
+	if err := store.UpdateReputation(p.ID(), score); err != nil {
+		p.log.Warn("Failed to store peer reputation", "err", err)
+	}
+}
+
+// lowestScoringPeer returns the connected peer with the lowest negative
+// reputation score. Trusted and static peers are never selected.
+func lowestScoringPeer(peers map[discover.NodeID]*Peer) *Peer {
+	var worst *Peer
+	for _, p := range peers {
+		if p.rw.is(trustedConn | staticDialedConn) {
+			continue
+		}
+		if score := p.Score(); score < 0 && (worst == nil || score < worst.Score()) {
+			worst = p
+		}
+	}
+	return worst
+}
+
+// inboundNetAllowed checks whether the inbound connection would exceed the
+// number of peers allowed from the same IP address or subnet.
+func (srv *Server) inboundNetAllowed(peers map[discover.NodeID]*Peer, c *conn) bool {
+	if srv.MaxInboundPerIP == 0 && srv.MaxInboundPerSubnet == 0 {
+		return true
+	}
+	ip := c.remoteIP()
+	if ip == nil {
+		return true
+	}
+	var sameIP, sameNet int
+	for _, p := range peers {
+		if !p.rw.is(inboundConn) {
+			continue
+		}
+		pip := p.rw.remoteIP()
+		if pip == nil {
+			continue
+		}
+		if pip.Equal(ip) {
+			sameIP++
+		}
+		if sameSubnet(pip, ip) {
+			sameNet++
+		}
+	}
+	if srv.MaxInboundPerIP > 0 && sameIP >= srv.MaxInboundPerIP {
+		rejectedIPLimitMeter.Mark(1)
+		return false
+	}
+	if srv.MaxInboundPerSubnet > 0 && sameNet >= srv.MaxInboundPerSubnet {
+		rejectedSubnetLimitMeter.Mark(1)
+		return false
+	}
+	return true
+}
+
+// sameSubnet reports whether two addresses are within the same /24 (IPv4) or
+// /64 (IPv6) network.
+func sameSubnet(ip, other net.IP) bool {
+	if ip.To4() != nil {
+		return netutil.SameNet(24, ip, other)
+	}
+	return netutil.SameNet(64, ip, other)
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
@@ -828,14 +968,15 @@ func (srv *Server) listenLoop() {
 			break
 		}
 
-		// Reject connections that do not match NetRestrict.
-		if srv.NetRestrict != nil {
-			if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok && !srv.NetRestrict.Contains(tcp.IP) {
-				srv.log.Debug("Rejected conn (not whitelisted in NetRestrict)", "addr", fd.RemoteAddr())
-				fd.Close()
-				slots <- struct{}{}
-				continue
-			}
+		var remoteIP net.IP
+		if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
+			remoteIP = tcp.IP
+		}
+		if err := srv.checkInboundConn(remoteIP); err != nil {
+			srv.log.Debug("Rejected inbound connection", "addr", fd.RemoteAddr(), "err", err)
+			fd.Close()
+			slots <- struct{}{}
+			continue
 		}
 
 		fd = newMeteredConn(fd, true)
@@ -847,6 +988,28 @@ func (srv *Server) listenLoop() {
 	}
 }
 
+// checkInboundConn verifies whether an inbound connection from the given
+// address may proceed to the handshakes.
+func (srv *Server) checkInboundConn(remoteIP net.IP) error {
+	if remoteIP == nil {
+		return nil
+	}
+	// Reject connections that do not match NetRestrict.
+	if srv.NetRestrict != nil && !srv.NetRestrict.Contains(remoteIP) {
+		rejectedRestrictMeter.Mark(1)
+		return errors.New("not whitelisted in NetRestrict")
+	}
+	// Reject Internet peers that try too often.
+	now := mclock.Now()
+	srv.inboundHistory.expire(now)
+	if !netutil.IsLAN(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
+		rejectedThrottleMeter.Mark(1)
+		return errors.New("too many attempts")
+	}
+	srv.inboundHistory.add(remoteIP.String(), now.Add(inboundThrottleTime))
+	return nil
+}
+
 // SetupConn runs the handshakes and attempts to add the connection
 // as a peer. It returns when the connection has been added as a peer
 // or the handshakes have failed.
@@ -29,6 +29,7 @@ import (
 	"github.com/go-ethereum-analysis/crypto/sha3"
 	"github.com/go-ethereum-analysis/log"
 	"github.com/go-ethereum-analysis/p2p/discover"
+	"github.com/go-ethereum-analysis/p2p/netutil"
 )
 
 func init() {
@@ -472,6 +473,91 @@ func TestServerPeerLimits(t *testing.T) {
 	conn.Close()
 }
 
+func TestLowestScoringPeer(t *testing.T) {
+	var (
+		good    = NewPeer(randomID(), "good", nil)
+		bad     = NewPeer(randomID(), "bad", nil)
+		worse   = NewPeer(randomID(), "worse", nil)
+		trusted = NewPeer(randomID(), "trusted", nil)
+		peers   = make(map[discover.NodeID]*Peer)
+	)
+	if p := lowestScoringPeer(peers); p != nil {
+		t.Fatalf("evicted peer from empty set: %v", p)
+	}
+	good.AdjustScore(10)
+	peers[good.ID()] = good
+	if p := lowestScoringPeer(peers); p != nil {
+		t.Fatalf("evicted peer with positive score: %v", p)
+	}
+	bad.AdjustScore(-10)
+	worse.AdjustScore(-20)
+	trusted.AdjustScore(-30)
+	trusted.rw.set(trustedConn, true)
+	for _, p := range []*Peer{bad, worse, trusted} {
+		peers[p.ID()] = p
+	}
+	if p := lowestScoringPeer(peers); p != worse {
+		t.Errorf("wrong peer selected for eviction: got %v, want %v", p, worse)
+	}
+}
+
+func TestServerInboundThrottle(t *testing.T) {
+	srv := &Server{log: log.New()}
+
+	internet, lan := net.ParseIP("1.2.3.4"), net.ParseIP("192.168.0.1")
+	if err := srv.checkInboundConn(internet); err != nil {
+		t.Fatalf("first connection rejected: %v", err)
+	}
+	if err := srv.checkInboundConn(internet); err == nil {
+		t.Errorf("repeated connection not throttled")
+	}
+	for i := 0; i < 2; i++ {
+		if err := srv.checkInboundConn(lan); err != nil {
+			t.Errorf("LAN connection %d throttled: %v", i, err)
+		}
+	}
+	srv.NetRestrict = new(netutil.Netlist)
+	srv.NetRestrict.Add("10.0.0.0/8")
+	if err := srv.checkInboundConn(net.ParseIP("5.6.7.8")); err == nil {
+		t.Errorf("connection outside NetRestrict not rejected")
+	}
+}
+
+func TestServerInboundNetLimits(t *testing.T) {
+	srv := &Server{Config: Config{MaxInboundPerIP: 1, MaxInboundPerSubnet: 2}, log: log.New()}
+
+	newInbound := func(ip string) *conn {
+		fd := &fakeAddrConn{remoteAddr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 30303}}
+		return &conn{fd: fd, flags: inboundConn, id: randomID()}
+	}
+	peers := make(map[discover.NodeID]*Peer)
+	addPeer := func(c *conn) {
+		peers[c.id] = newPeer(c, nil)
+	}
+	addPeer(newInbound("1.2.3.4"))
+
+	if srv.inboundNetAllowed(peers, newInbound("1.2.3.4")) {
+		t.Errorf("second peer from the same IP accepted")
+	}
+	if !srv.inboundNetAllowed(peers, newInbound("1.2.3.5")) {
+		t.Errorf("first peer from different IP in the same subnet rejected")
+	}
+	addPeer(newInbound("1.2.3.5"))
+	if srv.inboundNetAllowed(peers, newInbound("1.2.3.6")) {
+		t.Errorf("third peer from the same subnet accepted")
+	}
+	if !srv.inboundNetAllowed(peers, newInbound("1.2.4.6")) {
+		t.Errorf("peer from different subnet rejected")
+	}
+}
+
+type fakeAddrConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *fakeAddrConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
 func TestServerSetupConn(t *testing.T) {
 	id := randomID()
 	srvkey := newkey()
@@ -125,6 +125,14 @@ type ChainConfig struct {
 	Clique *CliqueConfig `json:"clique,omitempty"`
 }
 
+// CheckpointOracleConfig is the configuration of the on-chain checkpoint oracle
+// contract used by light clients to retrieve trusted checkpoints.
+type CheckpointOracleConfig struct {
+	Address   common.Address   `json:"address"`   // Address of the checkpoint oracle contract
+	Signers   []common.Address `json:"signers"`   // Trusted signers allowed to register checkpoints
+	Threshold uint64           `json:"threshold"` // Number of signatures required to approve a checkpoint
+}
+
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
 type EthashConfig struct{}
 
@@ -0,0 +1,117 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// jwtExpiryTimeout is the maximum difference between the issuance time of a
+// token and the local time, in either direction.
+const jwtExpiryTimeout = 60 * time.Second
+
+// HTTPAuth adds authentication headers to outgoing HTTP requests and websocket
+// handshakes. It is invoked for every request, so it may issue short lived
+// credentials.
+type HTTPAuth func(h http.Header) error
+
+// NewJWTAuth returns an HTTPAuth that authenticates requests with a fresh HS256
+// JSON Web Token signed with the given secret.
+func NewJWTAuth(secret []byte) HTTPAuth {
+	return func(h http.Header) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			IssuedAt: time.Now().Unix(),
+		})
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			return fmt.Errorf("failed to sign JWT token: %v", err)
+		}
+		h.Set("Authorization", "Bearer "+signed)
+		return nil
+	}
+}
+
+// jwtHandler is a handler which only passes on requests carrying a valid HS256
+// JSON Web Token, issued within jwtExpiryTimeout of the local time.
+type jwtHandler struct {
+	keyFunc func(token *jwt.Token) (interface{}, error)
+	next    http.Handler
+}
+
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{
+		keyFunc: func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		},
+		next: next,
+	}
+}
+
+// ServeHTTP implements http.Handler, rejecting unauthenticated requests.
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.validate(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+func (h *jwtHandler) validate(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return errors.New("missing token")
+	}
+	var (
+		claims jwt.StandardClaims
+		parser = &jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	)
+	token, err := parser.ParseWithClaims(strings.TrimPrefix(auth, "Bearer "), &claims, h.keyFunc)
+	switch {
+	case err != nil:
+		return err
+	case !token.Valid:
+		return errors.New("invalid token")
+	case claims.IssuedAt == 0:
+		return errors.New("missing issued-at")
+	}
+	issued := time.Unix(claims.IssuedAt, 0)
+	if time.Since(issued) > jwtExpiryTimeout {
+		return errors.New("stale token")
+	}
+	if time.Until(issued) > jwtExpiryTimeout {
+		return errors.New("future token")
+	}
+	return nil
+}
+
+// NewAuthHTTPServer creates an HTTP server serving both JSON-RPC over HTTP and
+// websocket connections, requiring every request to present a JSON Web Token
+// signed with the given secret. As the tokens can't be sent by browsers, no
+// CORS or origin checks are done.
+func NewAuthHTTPServer(vhosts []string, secret []byte, timeouts HTTPTimeouts, srv *Server) *http.Server {
+	handler := newWebsocketUpgradeHandler(srv, srv.WebsocketHandler([]string{"*"}))
+	handler = newJWTHandler(secret, handler)
+	handler = newVHostHandler(vhosts, handler)
+
+	return newHTTPServer(handler, timeouts)
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Tests that the authenticated endpoint serves HTTP and websocket requests
+// carrying a valid token, and rejects all others.
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	hs := httptest.NewServer(NewAuthHTTPServer([]string{"*"}, secret, DefaultHTTPTimeouts, server).Handler)
+	defer hs.Close()
+
+	// issue creates tokens with a custom signing secret and issuance time
+	issue := func(secret []byte, iat time.Time) HTTPAuth {
+		return func(h http.Header) error {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{IssuedAt: iat.Unix()})
+			signed, err := token.SignedString(secret)
+			if err != nil {
+				return err
+			}
+			h.Set("Authorization", "Bearer "+signed)
+			return nil
+		}
+	}
+	tests := []struct {
+		name string
+		auth HTTPAuth
+		ok   bool
+	}{
+		{"valid", NewJWTAuth(secret), true},
+		{"unauthenticated", nil, false},
+		{"wrong secret", NewJWTAuth([]byte("fedcba9876543210fedcba9876543210")), false},
+		{"stale", issue(secret, time.Now().Add(-2*jwtExpiryTimeout)), false},
+		{"future", issue(secret, time.Now().Add(2*jwtExpiryTimeout)), false},
+	}
+	for _, transport := range []string{"http", "ws"} {
+		url := strings.Replace(hs.URL, "http", transport, 1)
+		for _, tt := range tests {
+			var options []ClientOption
+			if tt.auth != nil {
+				options = append(options, WithHTTPAuth(tt.auth))
+			}
+			client, err := DialOptions(context.Background(), url, options...)
+			if err == nil {
+				var resp Result
+				err = client.Call(&resp, "service_echo", "hello", 10, &Args{"world"})
+				client.Close()
+			}
+			if tt.ok && err != nil {
+				t.Errorf("%s/%s: request failed: %v", transport, tt.name, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("%s/%s: request succeeded", transport, tt.name)
+			}
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"reflect"
@@ -33,6 +34,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/go-ethereum-analysis/event"
 	"github.com/go-ethereum-analysis/log"
 )
 
@@ -163,15 +165,28 @@ func Dial(rawurl string) (*Client, error) {
 // The context is used to cancel or time out the initial connection establishment. It does
 // not affect subsequent interactions with the client.
 func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	return DialOptions(ctx, rawurl)
+}
+
+// DialOptions creates a new RPC client for the given URL, just like DialContext,
+// configuring the transport with the given options.
+func DialOptions(ctx context.Context, rawurl string, options ...ClientOption) (*Client, error) {
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, err
 	}
+	cfg := new(clientConfig)
+	for _, opt := range options {
+		opt(cfg)
+	}
 	switch u.Scheme {
 	case "http", "https":
-		return DialHTTP(rawurl)
+		if cfg.httpClient == nil {
+			cfg.httpClient = new(http.Client)
+		}
+		return dialHTTP(rawurl, cfg)
 	case "ws", "wss":
-		return DialWebsocket(ctx, rawurl, "")
+		return dialWebsocket(ctx, rawurl, cfg.wsOrigin, cfg.httpAuth)
 	case "stdio":
 		return DialStdIO(ctx)
 	case "":
@@ -446,6 +461,38 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 	return op.sub, nil
 }
 
+// SubscribeResilient is like Subscribe, but keeps the subscription alive across
+// connection failures. Once established, a failed subscription is re-established
+// on a new connection, retrying with a backoff of at most backoffMax between the
+// attempts. Notifications sent while the subscription is down are lost.
+//
+// Errors of the initial subscription attempt are returned, failures afterwards
+// are not reported on the Err channel. It is closed when the subscription ends
+// because the client is closed or Unsubscribe is called.
+func (c *Client) SubscribeResilient(ctx context.Context, namespace string, channel interface{}, backoffMax time.Duration, args ...interface{}) (event.Subscription, error) {
+	return Resubscribe(ctx, backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		return c.Subscribe(ctx, namespace, channel, args...)
+	})
+}
+
+// Resubscribe establishes a subscription with fn, returning the errors of the
+// initial attempt. Afterwards, the subscription is re-established with fn every
+// time it fails, applying backoff between the attempts as event.Resubscribe. This
+// allows wrapping subscriptions that need to do extra work after a reconnect.
+func Resubscribe(ctx context.Context, backoffMax time.Duration, fn event.ResubscribeFunc) (event.Subscription, error) {
+	first, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return event.Resubscribe(backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		if sub := first; sub != nil {
+			first = nil
+			return sub, nil
+		}
+		return fn(ctx)
+	}), nil
+}
+
 func (c *Client) newMessage(method string, paramsIn ...interface{}) (*jsonrpcMessage, error) {
 	params, err := json.Marshal(paramsIn)
 	if err != nil {
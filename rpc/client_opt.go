@@ -0,0 +1,65 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http"
+)
+
+// ClientOption is a configuration option for the RPC client.
+type ClientOption func(*clientConfig)
+
+// clientConfig collects the transport settings of the RPC client.
+type clientConfig struct {
+	httpClient *http.Client // HTTP client to send requests with (nil = default client)
+	httpAuth   HTTPAuth     // Authentication of HTTP requests and websocket handshakes
+	wsOrigin   string       // Origin of websocket handshakes (empty = local host name)
+
+	httpCompression bool // Whether to compress large HTTP request bodies
+}
+
+// WithHTTPClient configures the http.Client used by HTTP connections.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = c
+	}
+}
+
+// WithHTTPAuth configures the client to authenticate its HTTP requests and
+// websocket handshakes, e.g. with the tokens of NewJWTAuth. It has no effect on
+// IPC connections.
+func WithHTTPAuth(a HTTPAuth) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpAuth = a
+	}
+}
+
+// WithWebsocketOrigin configures the origin sent in websocket handshakes.
+func WithWebsocketOrigin(origin string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.wsOrigin = origin
+	}
+}
+
+// WithHTTPRequestCompression configures the client to gzip large HTTP request
+// bodies. The server must support compressed requests. Compressed responses are
+// negotiated regardless of this option.
+func WithHTTPRequestCompression() ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpCompression = true
+	}
+}
@@ -485,6 +485,92 @@ func TestClientReconnect(t *testing.T) {
 	}
 }
 
+type ResubscribeTestService struct{}
+
+// Counter sends increasing numbers until the subscription ends.
+func (s *ResubscribeTestService) Counter(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				if err := notifier.Notify(subscription.ID, i); err != nil {
+					return
+				}
+			case <-subscription.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+// Tests that resilient subscriptions are re-established after the server went
+// away, and that they end on unsubscribe.
+func TestClientSubscribeResilient(t *testing.T) {
+	startServer := func(addr string) (*Server, net.Listener) {
+		srv := newTestServer("resub", new(ResubscribeTestService))
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go http.Serve(l, srv.WebsocketHandler([]string{"*"}))
+		return srv, l
+	}
+	s1, l1 := startServer("127.0.0.1:0")
+	client, err := Dial("ws://" + l1.Addr().String())
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.SubscribeResilient(context.Background(), "resub", nc, 100*time.Millisecond, "counter")
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	waitNotification := func() {
+		select {
+		case <-nc:
+		case err := <-sub.Err():
+			t.Fatal("subscription failed:", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification within 5s")
+		}
+	}
+	waitNotification()
+
+	// Restart the server, notifications should resume on the new connection
+	l1.Close()
+	s1.Stop()
+	time.Sleep(2 * time.Second)
+
+	s2, l2 := startServer(l1.Addr().String())
+	defer l2.Close()
+	defer s2.Stop()
+
+	waitNotification()
+
+	sub.Unsubscribe()
+	select {
+	case err := <-sub.Err():
+		if err != nil {
+			t.Fatalf("Err returned a non-nil error after explicit unsubscribe: %q", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed within 1s after unsubscribe")
+	}
+}
+
 func newTestServer(serviceName string, service interface{}) *Server {
 	server := NewServer()
 	if err := server.RegisterName(serviceName, service); err != nil {
@@ -0,0 +1,162 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Content codings supported for HTTP request and response bodies. Deflate
+	// is the zlib format, as specified for HTTP.
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+
+	// requestCompressionThreshold is the body size from which the client
+	// compresses requests, if enabled. Smaller bodies don't benefit from it.
+	requestCompressionThreshold = 1024
+)
+
+// compressor is implemented by the gzip and zlib writers.
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// compressors holds reusable writers for the supported content codings.
+var compressors = map[string]*sync.Pool{
+	encodingGzip:    {New: func() interface{} { return gzip.NewWriter(nil) }},
+	encodingDeflate: {New: func() interface{} { return zlib.NewWriter(nil) }},
+}
+
+// supportedEncoding checks whether a Content-Encoding header denotes a coding
+// the server can decompress.
+func supportedEncoding(coding string) bool {
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "", "identity", encodingGzip, encodingDeflate:
+		return true
+	}
+	return false
+}
+
+// acceptedEncoding picks the coding to compress a response with from the
+// Accept-Encoding header of the request, preferring gzip over deflate. Codings
+// refused with a zero quality are skipped, an empty result means no compression.
+func acceptedEncoding(header string) string {
+	var gzip, deflate bool
+	for _, part := range strings.Split(header, ",") {
+		coding, params := part, ""
+		if i := strings.Index(part, ";"); i >= 0 {
+			coding, params = part[:i], part[i+1:]
+		}
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if q, err := strconv.ParseFloat(params[2:], 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case encodingGzip, "*":
+			gzip = true
+		case encodingDeflate:
+			deflate = true
+		}
+	}
+	switch {
+	case gzip:
+		return encodingGzip
+	case deflate:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// newResponseWriter returns the writer for the body of a response, compressing
+// it with the coding accepted by the client. The returned function finishes the
+// compressed stream and must be called once the response is written.
+func newResponseWriter(w http.ResponseWriter, r *http.Request) (io.Writer, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	coding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+	if coding == "" {
+		return w, func() {}
+	}
+	w.Header().Set("Content-Encoding", coding)
+
+	pool := compressors[coding]
+	cw := pool.Get().(compressor)
+	cw.Reset(w)
+	return cw, func() {
+		cw.Close()
+		cw.Reset(nil)
+		pool.Put(cw)
+	}
+}
+
+// newDecompressor returns a reader decompressing a body encoded with the given
+// content coding.
+func newDecompressor(body io.Reader, coding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "", "identity":
+		return ioutil.NopCloser(body), nil
+	case encodingGzip:
+		return gzip.NewReader(body)
+	case encodingDeflate:
+		return zlib.NewReader(body)
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", coding)
+}
+
+// compress encodes a request body with the given content coding.
+func compress(body []byte, coding string) ([]byte, error) {
+	pool := compressors[coding]
+	cw := pool.Get().(compressor)
+	defer func() {
+		cw.Reset(nil)
+		pool.Put(cw)
+	}()
+
+	buf := new(bytes.Buffer)
+	cw.Reset(buf)
+	if _, err := cw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressedBody is a response body read through a decompressor, closing
+// both on Close.
+type decompressedBody struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
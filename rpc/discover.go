@@ -0,0 +1,329 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	openRPCVersion = "1.2.6"
+
+	// discoverMethod is the name OpenRPC tooling requests the document with, it
+	// is served by rpc_discover.
+	discoverMethod = "rpc.discover"
+)
+
+var (
+	bigIntType          = reflect.TypeOf(big.Int{})
+	blockNumberType     = reflect.TypeOf(BlockNumber(0))
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// OpenRPCDocument describes the methods served by an RPC server, following the
+// OpenRPC specification. Subscriptions can't be expressed as OpenRPC methods and
+// are listed separately as an extension.
+type OpenRPCDocument struct {
+	OpenRPC       string                `json:"openrpc"`
+	Info          OpenRPCInfo           `json:"info"`
+	Methods       []OpenRPCMethod       `json:"methods"`
+	Subscriptions []OpenRPCSubscription `json:"x-subscriptions,omitempty"`
+	Components    OpenRPCComponents     `json:"components"`
+}
+
+// OpenRPCInfo contains the metadata of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a single method.
+type OpenRPCMethod struct {
+	Name   string                     `json:"name"`
+	Params []OpenRPCContentDescriptor `json:"params"`
+	Result OpenRPCContentDescriptor   `json:"result"`
+}
+
+// OpenRPCSubscription describes a subscription created by the subscribe method
+// of a namespace, e.g. newHeads created by eth_subscribe. The params follow the
+// subscription name in the subscribe request.
+type OpenRPCSubscription struct {
+	Name   string                     `json:"name"`
+	Method string                     `json:"method"`
+	Params []OpenRPCContentDescriptor `json:"params"`
+}
+
+// OpenRPCContentDescriptor describes a parameter or result.
+type OpenRPCContentDescriptor struct {
+	Name     string                 `json:"name"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the struct types referenced by the
+// parameters and results.
+type OpenRPCComponents struct {
+	Schemas map[string]interface{} `json:"schemas"`
+}
+
+// Discover returns an OpenRPC document describing the methods and subscriptions
+// exposed by the server.
+func (s *RPCService) Discover() *OpenRPCDocument {
+	var (
+		filter = s.server.MethodFilter()
+		gen    = newSchemaGenerator()
+		doc    = &OpenRPCDocument{
+			OpenRPC: openRPCVersion,
+			Info:    OpenRPCInfo{Title: "JSON-RPC API", Version: "1.0"},
+			Methods: []OpenRPCMethod{},
+		}
+	)
+	for name, svc := range s.server.services {
+		for method, callb := range svc.callbacks {
+			if !filter.Allowed(name + serviceMethodSeparator + method) {
+				continue
+			}
+			doc.Methods = append(doc.Methods, OpenRPCMethod{
+				Name:   name + serviceMethodSeparator + method,
+				Params: gen.params(callb.argTypes),
+				Result: gen.result(callb),
+			})
+		}
+		if !filter.Allowed(name + subscribeMethodSuffix) {
+			continue
+		}
+		for sub, callb := range svc.subscriptions {
+			doc.Subscriptions = append(doc.Subscriptions, OpenRPCSubscription{
+				Name:   sub,
+				Method: name + subscribeMethodSuffix,
+				Params: gen.params(callb.argTypes),
+			})
+		}
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool {
+		return doc.Methods[i].Name < doc.Methods[j].Name
+	})
+	sort.Slice(doc.Subscriptions, func(i, j int) bool {
+		if doc.Subscriptions[i].Method != doc.Subscriptions[j].Method {
+			return doc.Subscriptions[i].Method < doc.Subscriptions[j].Method
+		}
+		return doc.Subscriptions[i].Name < doc.Subscriptions[j].Name
+	})
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// schemaGenerator derives JSON schemas from Go types, collecting the schemas of
+// struct types as components to be referenced.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]interface{}),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// params describes the arguments of a method. As parameter names aren't known
+// at runtime, they are named by position. Trailing pointer arguments may be
+// omitted by the caller and are marked optional.
+func (g *schemaGenerator) params(types []reflect.Type) []OpenRPCContentDescriptor {
+	required := len(types)
+	for required > 0 && types[required-1].Kind() == reflect.Ptr {
+		required--
+	}
+	params := make([]OpenRPCContentDescriptor, len(types))
+	for i, typ := range types {
+		params[i] = OpenRPCContentDescriptor{
+			Name:     fmt.Sprintf("arg%d", i),
+			Required: i < required,
+			Schema:   g.schema(typ),
+		}
+	}
+	return params
+}
+
+// result describes the value returned by a method.
+func (g *schemaGenerator) result(callb *callback) OpenRPCContentDescriptor {
+	result := OpenRPCContentDescriptor{Name: "result", Schema: map[string]interface{}{"type": "null"}}
+
+	if mtype := callb.method.Type; mtype.NumOut() > 0 && callb.errPos != 0 {
+		result.Schema = g.schema(mtype.Out(0))
+		result.Required = mtype.Out(0).Kind() != reflect.Ptr
+	}
+	return result
+}
+
+// schema derives the JSON schema of values of the given type, as encoded by the
+// encoding/json package.
+func (g *schemaGenerator) schema(typ reflect.Type) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// Types with custom encodings first
+	switch {
+	case typ == bigIntType:
+		return map[string]interface{}{"type": "integer", "title": typ.String()}
+	case typ == blockNumberType:
+		return map[string]interface{}{
+			"title":       typ.String(),
+			"type":        "string",
+			"description": `Hex encoded block number or one of "earliest", "latest" and "pending"`,
+		}
+	case implements(typ, textMarshalerType):
+		schema := map[string]interface{}{"type": "string", "title": typ.String()}
+		if isHexType(typ) {
+			schema["pattern"] = "^0x[0-9a-fA-F]*$"
+		}
+		return schema
+	case implements(typ, jsonMarshalerType) || implements(typ, jsonUnmarshalerType):
+		// The encoding can't be derived, allow any value
+		return map[string]interface{}{"title": typ.String()}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(typ.Elem())}
+	case reflect.Struct:
+		return map[string]interface{}{"$ref": "#/components/schemas/" + g.structSchema(typ)}
+	default:
+		// Interfaces and anything else may hold any value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema registers the schema of a struct type as a component, returning
+// the name it can be referenced by.
+func (g *schemaGenerator) structSchema(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+	// Name the schema after the type, qualified by the package on collisions
+	name := typ.Name()
+	if name == "" {
+		name = "Anonymous"
+	}
+	if _, taken := g.schemas[name]; taken && typ.PkgPath() != "" {
+		name = path.Base(typ.PkgPath()) + "." + name
+	}
+	for base, i := name, 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	// Register the name before descending, the type may be recursive
+	g.names[typ] = name
+	g.schemas[name] = nil
+
+	var (
+		properties = make(map[string]interface{})
+		required   []string
+	)
+	g.structFields(typ, properties, &required)
+
+	schema := map[string]interface{}{
+		"title":      typ.String(),
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	g.schemas[name] = schema
+	return name
+}
+
+// structFields collects the JSON encoded fields of a struct, flattening embedded
+// structs the way encoding/json does.
+func (g *schemaGenerator) structFields(typ reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+		// Embedded structs without a name contribute their fields
+		ftype := field.Type
+		if field.Anonymous && name == "" {
+			if ftype.Kind() == reflect.Ptr {
+				ftype = ftype.Elem()
+			}
+			if ftype.Kind() == reflect.Struct {
+				g.structFields(ftype, properties, required)
+				continue
+			}
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := g.schema(ftype)
+		if strings.Contains(opts, "string") {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// implements checks whether a type or a pointer to it implements an interface.
+func implements(typ reflect.Type, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface)
+}
+
+// isHexType checks whether the type is one of the hex encoded types of the
+// common packages.
+func isHexType(typ reflect.Type) bool {
+	pkg := typ.PkgPath()
+	return strings.HasSuffix(pkg, "/common/hexutil") || strings.HasSuffix(pkg, "/common")
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-ethereum-analysis/common/hexutil"
+)
+
+type DiscoverService struct{}
+
+type DiscoverArgs struct {
+	From  string          `json:"from"`
+	Value *hexutil.Big    `json:"value,omitempty"`
+	Data  hexutil.Bytes   `json:"data"`
+	Next  *DiscoverArgs   `json:"next,omitempty"`
+	Tags  map[string]bool `json:"-"`
+}
+
+func (s *DiscoverService) Call(args DiscoverArgs, number BlockNumber, full *bool) (hexutil.Uint64, error) {
+	return 0, nil
+}
+
+func (s *DiscoverService) Notify() {}
+
+func (s *DiscoverService) Updates(ctx context.Context, filter *DiscoverArgs) (*Subscription, error) {
+	return nil, nil
+}
+
+// Tests that the discovery document describes the methods, their parameter and
+// result types and the subscriptions of the server.
+func TestDiscover(t *testing.T) {
+	server := newTestServer("test", new(DiscoverService))
+	defer server.Stop()
+
+	filter, err := NewMethodFilter([]string{"!test_notify"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMethodFilter(filter)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Request the document with the OpenRPC method name
+	var doc OpenRPCDocument
+	if err := client.Call(&doc, discoverMethod); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	names := make([]string, len(doc.Methods))
+	for i, method := range doc.Methods {
+		names[i] = method.Name
+	}
+	if want := []string{"rpc_discover", "rpc_modules", "test_call"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("methods mismatch: have %v, want %v", names, want)
+	}
+	call := doc.Methods[2]
+	if len(call.Params) != 3 {
+		t.Fatalf("params count mismatch: have %d, want 3", len(call.Params))
+	}
+	for i, required := range []bool{true, true, false} {
+		if call.Params[i].Required != required {
+			t.Errorf("param %d: required mismatch: have %v, want %v", i, call.Params[i].Required, required)
+		}
+	}
+	if ref := call.Params[0].Schema["$ref"]; ref != "#/components/schemas/DiscoverArgs" {
+		t.Errorf("struct param reference mismatch: have %v", ref)
+	}
+	if typ := call.Params[1].Schema["type"]; typ != "string" {
+		t.Errorf("block number param type mismatch: have %v, want string", typ)
+	}
+	if typ := call.Params[2].Schema["type"]; typ != "boolean" {
+		t.Errorf("optional param type mismatch: have %v, want boolean", typ)
+	}
+	if pattern := call.Result.Schema["pattern"]; pattern != "^0x[0-9a-fA-F]*$" {
+		t.Errorf("hex result pattern mismatch: have %v", pattern)
+	}
+	// Check the struct schema, including the recursive reference
+	blob, _ := json.Marshal(doc.Components.Schemas["DiscoverArgs"])
+	var args struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+		Required   []string                          `json:"required"`
+	}
+	if err := json.Unmarshal(blob, &args); err != nil {
+		t.Fatal(err)
+	}
+	if len(args.Properties) != 4 {
+		t.Errorf("property count mismatch: have %d, want 4", len(args.Properties))
+	}
+	if ref := args.Properties["next"]["$ref"]; ref != "#/components/schemas/DiscoverArgs" {
+		t.Errorf("recursive reference mismatch: have %v", ref)
+	}
+	if want := []string{"data", "from"}; !reflect.DeepEqual(args.Required, want) {
+		t.Errorf("required fields mismatch: have %v, want %v", args.Required, want)
+	}
+	// Check the subscriptions
+	if len(doc.Subscriptions) != 1 {
+		t.Fatalf("subscription count mismatch: have %d, want 1", len(doc.Subscriptions))
+	}
+	if sub := doc.Subscriptions[0]; sub.Name != "updates" || sub.Method != "test_subscribe" || len(sub.Params) != 1 {
+		t.Errorf("subscription mismatch: have %+v", sub)
+	}
+}
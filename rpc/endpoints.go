@@ -23,7 +23,8 @@ import (
 )
 
 // StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
+// and an optional method filter.
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, filter *MethodFilter, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -31,6 +32,7 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	}
 	// Register all the APIs exposed by the services
 	handler := NewServer()
+	handler.SetMethodFilter(filter)
 	for _, api := range apis {
 		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -52,7 +54,7 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 }
 
 // StartWSEndpoint starts a websocket endpoint
-func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []string, exposeAll bool) (net.Listener, *Server, error) {
+func StartWSEndpoint(endpoint string, apis []API, modules []string, filter *MethodFilter, wsOrigins []string, exposeAll bool) (net.Listener, *Server, error) {
 
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
@@ -61,6 +63,7 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 	}
 	// Register all the APIs exposed by the services
 	handler := NewServer()
+	handler.SetMethodFilter(filter)
 	for _, api := range apis {
 		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
@@ -82,6 +85,74 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 
 }
 
+// StartHTTPWSEndpoint starts an HTTP and a websocket endpoint sharing a single
+// listener. Websocket upgrade requests are served by a websocket server and all
+// others by an HTTP server, each exposing its own set of modules.
+func StartHTTPWSEndpoint(endpoint string, apis []API, httpModules []string, httpFilter *MethodFilter, cors []string, vhosts []string, timeouts HTTPTimeouts, wsModules []string, wsFilter *MethodFilter, wsOrigins []string, exposeAll bool) (net.Listener, *Server, *Server, error) {
+	// Generate the whitelists based on the allowed modules
+	httpWhitelist := make(map[string]bool)
+	for _, module := range httpModules {
+		httpWhitelist[module] = true
+	}
+	wsWhitelist := make(map[string]bool)
+	for _, module := range wsModules {
+		wsWhitelist[module] = true
+	}
+	// Register all the APIs exposed by the services
+	httpHandler, wsHandler := NewServer(), NewServer()
+	httpHandler.SetMethodFilter(httpFilter)
+	wsHandler.SetMethodFilter(wsFilter)
+	for _, api := range apis {
+		if httpWhitelist[api.Namespace] || (len(httpWhitelist) == 0 && api.Public) {
+			if err := httpHandler.RegisterName(api.Namespace, api.Service); err != nil {
+				return nil, nil, nil, err
+			}
+			log.Debug("HTTP registered", "namespace", api.Namespace)
+		}
+		if exposeAll || wsWhitelist[api.Namespace] || (len(wsWhitelist) == 0 && api.Public) {
+			if err := wsHandler.RegisterName(api.Namespace, api.Service); err != nil {
+				return nil, nil, nil, err
+			}
+			log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
+		}
+	}
+	// All APIs registered, start the shared listener
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	go NewHTTPWSServer(cors, vhosts, timeouts, httpHandler, wsOrigins, wsHandler).Serve(listener)
+	return listener, httpHandler, wsHandler, nil
+}
+
+// StartAuthEndpoint starts an HTTP and websocket endpoint on a single listener,
+// only serving requests authenticated with a JSON Web Token signed with secret.
+func StartAuthEndpoint(endpoint string, apis []API, modules []string, filter *MethodFilter, vhosts []string, secret []byte, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
+	// Generate the whitelist based on the allowed modules
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	// Register all the APIs exposed by the services
+	handler := NewServer()
+	handler.SetMethodFilter(filter)
+	for _, api := range apis {
+		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				return nil, nil, err
+			}
+			log.Debug("Authenticated RPC registered", "namespace", api.Namespace)
+		}
+	}
+	// All APIs registered, start the HTTP listener
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	go NewAuthHTTPServer(vhosts, secret, timeouts, handler).Serve(listener)
+	return listener, handler, nil
+}
+
 // StartIPCEndpoint starts an IPC endpoint.
 func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, error) {
 	// Register all the APIs exposed by the services.
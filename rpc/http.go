@@ -45,6 +45,8 @@ var nullAddr, _ = net.ResolveTCPAddr("tcp", "127.0.0.1:0")
 type httpConn struct {
 	client    *http.Client
 	req       *http.Request
+	auth      HTTPAuth
+	compress  bool // whether to compress large request bodies
 	closeOnce sync.Once
 	closed    chan struct{}
 }
@@ -102,16 +104,27 @@ var DefaultHTTPTimeouts = HTTPTimeouts{
 // DialHTTPWithClient creates a new RPC client that connects to an RPC server over HTTP
 // using the provided HTTP Client.
 func DialHTTPWithClient(endpoint string, client *http.Client) (*Client, error) {
+	return dialHTTP(endpoint, &clientConfig{httpClient: client})
+}
+
+func dialHTTP(endpoint string, cfg *clientConfig) (*Client, error) {
 	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", contentType)
+	req.Header.Set("Accept-Encoding", encodingGzip+", "+encodingDeflate)
 
 	initctx := context.Background()
 	return newClient(initctx, func(context.Context) (net.Conn, error) {
-		return &httpConn{client: client, req: req, closed: make(chan struct{})}, nil
+		return &httpConn{
+			client:   cfg.httpClient,
+			req:      req,
+			auth:     cfg.httpAuth,
+			compress: cfg.httpCompression,
+			closed:   make(chan struct{}),
+		}, nil
 	})
 }
 
@@ -166,18 +179,49 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	if err != nil {
 		return nil, err
 	}
+	compressed := hc.compress && len(body) >= requestCompressionThreshold
+	if compressed {
+		if body, err = compress(body, encodingGzip); err != nil {
+			return nil, err
+		}
+	}
 	req := hc.req.WithContext(ctx)
 	req.Body = ioutil.NopCloser(bytes.NewReader(body))
 	req.ContentLength = int64(len(body))
 
+	// Authenticate every request separately, the headers are shared otherwise
+	if hc.auth != nil || compressed {
+		req.Header = make(http.Header, len(hc.req.Header)+1)
+		for key, values := range hc.req.Header {
+			req.Header[key] = values
+		}
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", encodingGzip)
+	}
+	if hc.auth != nil {
+		if err := hc.auth(req.Header); err != nil {
+			return nil, err
+		}
+	}
 	resp, err := hc.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	// Responses are compressed if the server supports it
+	respBody := resp.Body
+	if coding := resp.Header.Get("Content-Encoding"); coding != "" {
+		reader, err := newDecompressor(resp.Body, coding)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		respBody = &decompressedBody{reader, resp.Body}
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return resp.Body, errors.New(resp.Status)
+		return respBody, errors.New(resp.Status)
 	}
-	return resp.Body, nil
+	return respBody, nil
 }
 
 // httpReadWriteNopCloser wraps a io.Reader and io.Writer with a NOP Close method.
@@ -199,6 +243,23 @@ func NewHTTPServer(cors []string, vhosts []string, timeouts HTTPTimeouts, srv *S
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
 
+	return newHTTPServer(handler, timeouts)
+}
+
+// NewHTTPWSServer creates an HTTP server serving JSON-RPC over HTTP from httpSrv
+// and websocket connections from wsSrv on the same listener. The cors and vhosts
+// rules only apply to HTTP requests, websocket handshakes are checked against the
+// allowed origins instead.
+func NewHTTPWSServer(cors []string, vhosts []string, timeouts HTTPTimeouts, httpSrv *Server, wsOrigins []string, wsSrv *Server) *http.Server {
+	handler := newCorsHandler(httpSrv, cors)
+	handler = newVHostHandler(vhosts, handler)
+	handler = newWebsocketUpgradeHandler(handler, wsSrv.WebsocketHandler(wsOrigins))
+
+	return newHTTPServer(handler, timeouts)
+}
+
+// newHTTPServer bundles the handler into an HTTP server with sanitized timeouts.
+func newHTTPServer(handler http.Handler, timeouts HTTPTimeouts) *http.Server {
 	// Make sure timeout values are meaningful
 	if timeouts.ReadTimeout < time.Second {
 		log.Warn("Sanitizing invalid HTTP read timeout", "provided", timeouts.ReadTimeout, "updated", DefaultHTTPTimeouts.ReadTimeout)
@@ -239,11 +300,22 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
 
-	body := io.LimitReader(r.Body, maxRequestContentLength)
-	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
-	defer codec.Close()
+	// Compressed requests are limited in their decompressed size
+	decoder, err := newDecompressor(r.Body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer decoder.Close()
+	body := io.LimitReader(decoder, maxRequestContentLength)
 
 	w.Header().Set("content-type", contentType)
+	out, finish := newResponseWriter(w, r)
+	defer finish()
+
+	codec := NewJSONCodec(&httpReadWriteNopCloser{body, out})
+	defer codec.Close()
+
 	srv.ServeSingleRequest(ctx, codec, OptionMethodInvocation)
 }
 
@@ -257,6 +329,9 @@ func validateRequest(r *http.Request) (int, error) {
 		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
 		return http.StatusRequestEntityTooLarge, err
 	}
+	if coding := r.Header.Get("Content-Encoding"); !supportedEncoding(coding) {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %q", coding)
+	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
 	if r.Method != http.MethodOptions && (err != nil || mt != contentType) {
 		err := fmt.Errorf("invalid content type, only %s is supported", contentType)
@@ -325,3 +400,29 @@ func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
 	}
 	return &virtualHostHandler{vhostMap, next}
 }
+
+// websocketUpgradeHandler routes websocket upgrade requests to a websocket
+// handler and everything else to the HTTP handler.
+type websocketUpgradeHandler struct {
+	http http.Handler
+	ws   http.Handler
+}
+
+func newWebsocketUpgradeHandler(http http.Handler, ws http.Handler) http.Handler {
+	return &websocketUpgradeHandler{http: http, ws: ws}
+}
+
+// ServeHTTP implements http.Handler, dispatching on the Upgrade header.
+func (h *websocketUpgradeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isWebsocket(r) {
+		h.ws.ServeHTTP(w, r)
+		return
+	}
+	h.http.ServeHTTP(w, r)
+}
+
+// isWebsocket checks whether the request asks for a websocket upgrade.
+func isWebsocket(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
@@ -17,6 +17,11 @@
 package rpc
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -52,3 +57,134 @@ func testHTTPErrorResponse(t *testing.T, method, contentType, body string, expec
 		t.Fatalf("response code should be %d not %d", expected, code)
 	}
 }
+
+// Tests that HTTP and websocket servers sharing a listener expose their own
+// modules and apply their own access rules.
+func TestHTTPWSServer(t *testing.T) {
+	httpSrv := newTestServer("http", new(Service))
+	defer httpSrv.Stop()
+	wsSrv := newTestServer("ws", new(Service))
+	defer wsSrv.Stop()
+
+	hs := httptest.NewServer(NewHTTPWSServer(nil, []string{"localhost"}, DefaultHTTPTimeouts, httpSrv, []string{"http://allowed"}, wsSrv).Handler)
+	defer hs.Close()
+
+	// HTTP requests are served by the HTTP server only
+	httpClient, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatalf("failed to dial HTTP: %v", err)
+	}
+	defer httpClient.Close()
+
+	var resp Result
+	if err := httpClient.Call(&resp, "http_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Errorf("HTTP call failed: %v", err)
+	}
+	if err := httpClient.Call(&resp, "ws_echo", "hello", 10, &Args{"world"}); err == nil {
+		t.Error("websocket module reachable over HTTP")
+	}
+	// Websocket connections are served by the websocket server only
+	wsURL := "ws" + strings.TrimPrefix(hs.URL, "http")
+	wsClient, err := DialWebsocket(context.Background(), wsURL, "http://allowed")
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer wsClient.Close()
+
+	if err := wsClient.Call(&resp, "ws_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Errorf("websocket call failed: %v", err)
+	}
+	if err := wsClient.Call(&resp, "http_echo", "hello", 10, &Args{"world"}); err == nil {
+		t.Error("HTTP module reachable over websocket")
+	}
+	// Origin rules apply to websocket handshakes, vhost rules to HTTP requests
+	if _, err := DialWebsocket(context.Background(), wsURL, "http://denied"); err == nil {
+		t.Error("websocket handshake from denied origin succeeded")
+	}
+	req, _ := http.NewRequest(http.MethodPost, hs.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"http_echo","params":[]}`))
+	req.Host = "denied"
+	req.Header.Set("Content-Type", contentType)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("HTTP request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("HTTP request to denied vhost: have status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+}
+
+// Tests that the HTTP server compresses responses with the coding accepted by
+// the client and accepts compressed requests.
+func TestHTTPCompression(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	var encodings []string // content codings of the received requests
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		server.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"service_echo","params":["hello",10,{"S":"world"}]}`
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"br", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, hs.URL, strings.NewReader(request))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("accept %q: request failed: %v", tt.accept, err)
+		}
+		if have := resp.Header.Get("Content-Encoding"); have != tt.want {
+			t.Errorf("accept %q: content encoding mismatch: have %q, want %q", tt.accept, have, tt.want)
+		}
+		var body io.Reader = resp.Body
+		switch tt.want {
+		case "gzip":
+			body, err = gzip.NewReader(resp.Body)
+		case "deflate":
+			body, err = zlib.NewReader(resp.Body)
+		}
+		if err != nil {
+			t.Fatalf("accept %q: invalid compressed body: %v", tt.accept, err)
+		}
+		response := jsonSuccessResponse{Result: new(Result)}
+		if err := json.NewDecoder(body).Decode(&response); err != nil {
+			t.Errorf("accept %q: invalid response: %v", tt.accept, err)
+		} else if result := response.Result.(*Result); result.String != "hello" {
+			t.Errorf("accept %q: result mismatch: have %+v", tt.accept, result)
+		}
+		resp.Body.Close()
+	}
+	// The client compresses large requests if enabled, and decompresses responses
+	client, err := DialOptions(context.Background(), hs.URL, WithHTTPRequestCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	encodings = nil
+	for _, str := range []string{"short", strings.Repeat("long", requestCompressionThreshold)} {
+		var resp Result
+		if err := client.Call(&resp, "service_echo", str, 10, &Args{"world"}); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		if resp.String != str {
+			t.Errorf("result mismatch: have %q, want %q", resp.String, str)
+		}
+	}
+	if len(encodings) != 2 || encodings[0] != "" || encodings[1] != "gzip" {
+		t.Errorf("request encodings mismatch: have %q, want [\"\" \"gzip\"]", encodings)
+	}
+}
@@ -82,6 +82,7 @@ type jsonCodec struct {
 	decode func(v interface{}) error // decoder to allow multiple transports
 	encMu  sync.Mutex                // guards the encoder
 	encode func(v interface{}) error // encoder to allow multiple transports
+	stream io.Writer                 // writer for streamed responses, nil if not supported
 	rw     io.ReadWriteCloser        // connection
 }
 
@@ -117,6 +118,7 @@ func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 		closed: make(chan interface{}),
 		encode: enc.Encode,
 		decode: dec.Decode,
+		stream: rwc,
 		rw:     rwc,
 	}
 }
@@ -202,6 +204,9 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 			method: in.Method, params: in.Payload}}, false, nil
 	}
 
+	if in.Method == discoverMethod {
+		in.Method = MetadataApi + serviceMethodSeparator + "discover"
+	}
 	elems := strings.Split(in.Method, serviceMethodSeparator)
 	if len(elems) != 2 {
 		return nil, false, &methodNotFoundError{in.Method, ""}
@@ -260,6 +265,9 @@ func parseBatchRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error)
 		} else {
 			requests[i] = rpcRequest{id: id, params: r.Payload}
 		}
+		if r.Method == discoverMethod {
+			r.Method = MetadataApi + serviceMethodSeparator + "discover"
+		}
 		if elem := strings.Split(r.Method, serviceMethodSeparator); len(elem) == 2 {
 			requests[i].service, requests[i].method = elem[0], elem[1]
 		} else {
@@ -346,6 +354,9 @@ func (c *jsonCodec) Write(res interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
+	if c.stream != nil && streamable(res) {
+		return writeStream(c.stream, res)
+	}
 	return c.encode(res)
 }
 
@@ -0,0 +1,97 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MethodFilter restricts the methods a server exposes with glob patterns such as
+// "eth_*" (allow) and "!eth_sign*" (deny). A method is allowed if it matches no
+// deny pattern and either matches an allow pattern or there are none. Methods of
+// the metadata namespace (rpc_modules) are only hidden by explicit deny patterns,
+// so clients can always discover the effective set of modules.
+//
+// Subscriptions are matched by the name of their subscribe method, e.g.
+// eth_subscribe.
+type MethodFilter struct {
+	allow []string
+	deny  []string
+}
+
+// NewMethodFilter creates a method filter from a list of allow and deny patterns,
+// returning an error if any of them is malformed.
+func NewMethodFilter(patterns []string) (*MethodFilter, error) {
+	filter := new(MethodFilter)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		deny := strings.HasPrefix(pattern, "!")
+		if deny {
+			pattern = pattern[1:]
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid method pattern %q: %v", pattern, err)
+		}
+		if deny {
+			filter.deny = append(filter.deny, pattern)
+		} else {
+			filter.allow = append(filter.allow, pattern)
+		}
+	}
+	return filter, nil
+}
+
+// Allowed returns whether the given method passes the filter. A nil filter allows
+// all methods.
+func (f *MethodFilter) Allowed(method string) bool {
+	if f == nil {
+		return true
+	}
+	if matchMethod(f.deny, method) {
+		return false
+	}
+	if len(f.allow) == 0 || strings.HasPrefix(method, MetadataApi+serviceMethodSeparator) {
+		return true
+	}
+	return matchMethod(f.allow, method)
+}
+
+// matchMethod checks whether the method matches any of the patterns.
+func matchMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exposed returns whether any method or subscription of the service passes the
+// filter.
+func (s *service) exposed(filter *MethodFilter) bool {
+	for name := range s.callbacks {
+		if filter.Allowed(s.name + serviceMethodSeparator + name) {
+			return true
+		}
+	}
+	return len(s.subscriptions) > 0 && filter.Allowed(s.name+subscribeMethodSuffix)
+}
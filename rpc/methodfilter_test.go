@@ -0,0 +1,105 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMethodFilter(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		allowed  []string
+		denied   []string
+	}{
+		{
+			patterns: nil,
+			allowed:  []string{"eth_call", "eth_sign", "admin_peers"},
+		},
+		{
+			patterns: []string{"eth_*", "net_version"},
+			allowed:  []string{"eth_call", "eth_sign", "net_version", "rpc_modules"},
+			denied:   []string{"net_peerCount", "admin_peers"},
+		},
+		{
+			patterns: []string{"!eth_sign*", "!eth_accounts"},
+			allowed:  []string{"eth_call", "admin_peers"},
+			denied:   []string{"eth_sign", "eth_signTransaction", "eth_accounts"},
+		},
+		{
+			patterns: []string{"eth_*", "!eth_sendTransaction", "!rpc_*"},
+			allowed:  []string{"eth_call", "eth_getLogs"},
+			denied:   []string{"eth_sendTransaction", "rpc_modules", "web3_clientVersion"},
+		},
+	}
+	for i, tt := range tests {
+		filter, err := NewMethodFilter(tt.patterns)
+		if err != nil {
+			t.Fatalf("test %d: failed to create filter: %v", i, err)
+		}
+		for _, method := range tt.allowed {
+			if !filter.Allowed(method) {
+				t.Errorf("test %d: method %s denied", i, method)
+			}
+		}
+		for _, method := range tt.denied {
+			if filter.Allowed(method) {
+				t.Errorf("test %d: method %s allowed", i, method)
+			}
+		}
+	}
+	if _, err := NewMethodFilter([]string{"eth_[call"}); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+}
+
+// Tests that the server refuses filtered methods and omits modules without any
+// allowed method from rpc_modules.
+func TestServerMethodFilter(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	if err := server.RegisterName("hidden", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewMethodFilter([]string{"service_*", "!service_rets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetMethodFilter(filter)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "service_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Errorf("allowed method failed: %v", err)
+	}
+	for _, method := range []string{"service_rets", "hidden_echo"} {
+		if err, ok := client.Call(nil, method).(*jsonError); !ok || err.Code != -32601 {
+			t.Errorf("filtered method %s: have error %v, want method not found", method, err)
+		}
+	}
+	modules, err := client.SupportedModules()
+	if err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	want := map[string]string{"rpc": "1.0", "service": "1.0"}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules mismatch: have %v, want %v", modules, want)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the RPC server.
+
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/go-ethereum-analysis/metrics"
+)
+
+var (
+	rpcRequestMeter = metrics.NewRegisteredMeter("rpc/requests", nil)
+	rpcSuccessMeter = metrics.NewRegisteredMeter("rpc/success", nil)
+	rpcFailureMeter = metrics.NewRegisteredMeter("rpc/failure", nil)
+)
+
+// rpcServingTimer retrieves the timer measuring the successful or failed calls
+// of a method. Timers are only created for registered methods, so the number of
+// metrics can't be inflated by requests for arbitrary method names.
+func rpcServingTimer(method string, success bool) metrics.Timer {
+	flag := "success"
+	if !success {
+		flag = "failure"
+	}
+	return metrics.GetOrRegisterTimer(fmt.Sprintf("rpc/duration/%s/%s", method, flag), nil)
+}
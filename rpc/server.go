@@ -18,12 +18,14 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-ethereum-analysis/log"
@@ -64,11 +66,16 @@ type RPCService struct {
 	server *Server
 }
 
-// Modules returns the list of RPC services with their version number
+// Modules returns the list of RPC services with their version number. Services
+// whose methods are all hidden by the method filter of the server are omitted.
 func (s *RPCService) Modules() map[string]string {
+	filter := s.server.MethodFilter()
+
 	modules := make(map[string]string)
-	for name := range s.server.services {
-		modules[name] = "1.0"
+	for name, svc := range s.server.services {
+		if svc.exposed(filter) {
+			modules[name] = "1.0"
+		}
 	}
 	return modules
 }
@@ -116,6 +123,43 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
+// SetMethodFilter configures the methods served from now on, nil allows all.
+func (s *Server) SetMethodFilter(filter *MethodFilter) {
+	s.methods.Store(filter)
+}
+
+// MethodFilter returns the filter of the methods served, nil if all are allowed.
+func (s *Server) MethodFilter() *MethodFilter {
+	filter, _ := s.methods.Load().(*MethodFilter)
+	return filter
+}
+
+// SetLimits configures the resource limits of requests served from now on.
+func (s *Server) SetLimits(limits Limits) {
+	s.limits.Store(limits)
+}
+
+// Limits returns the resource limits of the requests served.
+func (s *Server) Limits() Limits {
+	limits, _ := s.limits.Load().(Limits)
+	return limits
+}
+
+// responseBudget returns the number of bytes a response may be, nil if the size
+// of responses is unlimited.
+func (s *Server) responseBudget() *int {
+	if limit := s.Limits().ResponseBytes; limit > 0 {
+		return &limit
+	}
+	return nil
+}
+
+// SetSlowRequestThreshold configures the server to log method calls taking at
+// least the given time, zero disables slow request logging.
+func (s *Server) SetSlowRequestThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&s.slowThreshold, int64(threshold))
+}
+
 // serveRequest will reads requests from the codec, calls the RPC callback and
 // writes the response to the given codec.
 //
@@ -184,6 +228,17 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 			}
 			return nil
 		}
+		// Reject batches exceeding the configured limit as a whole
+		if limit := s.Limits().BatchItems; batch && limit > 0 && len(reqs) > limit {
+			rpcRequestMeter.Mark(int64(len(reqs)))
+			rpcFailureMeter.Mark(int64(len(reqs)))
+
+			codec.Write(codec.CreateErrorResponse(nil, &invalidRequestError{fmt.Sprintf("batch too large, limit is %d items", limit)}))
+			if singleShot {
+				return nil
+			}
+			continue
+		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
 			if batch {
@@ -251,8 +306,10 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(*Subscription).ID, nil
 }
 
-// handle executes a request and returns the response from the callback.
-func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+// handle executes a request and returns the response from the callback. If budget
+// is not nil, it is the number of bytes the result may occupy and is decreased by
+// the size of the result.
+func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest, budget *int) (interface{}, func()) {
 	if req.err != nil {
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
@@ -275,7 +332,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	if req.callb.isSubscribe {
+		start := time.Now()
 		subid, err := s.createSubscription(ctx, codec, req)
+		s.trackCall(req, start, err)
 		if err != nil {
 			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
 		}
@@ -297,6 +356,46 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	// execute RPC method and return result
+	limits := s.Limits()
+	start := time.Now()
+	reply, err := s.call(ctx, req, limits.ExecutionTimeout)
+	if err == nil && req.callb.errPos >= 0 && !reply[req.callb.errPos].IsNil() { // test if method returned an error
+		err = reply[req.callb.errPos].Interface().(error)
+	}
+	if err == context.DeadlineExceeded {
+		err = &timeoutError{}
+	}
+	s.trackCall(req, start, err)
+
+	if err, ok := err.(*timeoutError); ok {
+		return codec.CreateErrorResponse(&req.id, err), nil
+	}
+	if err != nil {
+		return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+	}
+	if len(reply) == 0 {
+		return codec.CreateResponse(req.id, nil), nil
+	}
+	return s.createResponse(codec, req, reply[0].Interface(), budget), nil
+}
+
+// call invokes the method of a request. Methods accepting a context get one that
+// is cancelled as soon as the call returns, so any work they started in the
+// background stops with it.
+//
+// If timeout is set, the method is run in the background and abandoned once it
+// expires. The context of the method is cancelled then, but methods not
+// accepting one keep running until they return.
+func (s *Server) call(ctx context.Context, req *serverRequest, timeout time.Duration) ([]reflect.Value, error) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -304,30 +403,83 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if len(req.args) > 0 {
 		arguments = append(arguments, req.args...)
 	}
+	if timeout == 0 {
+		return req.callb.method.Func.Call(arguments), nil
+	}
+	done := make(chan []reflect.Value, 1)
+	go func() {
+		done <- req.callb.method.Func.Call(arguments)
+	}()
+	select {
+	case reply := <-done:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	// execute RPC method and return result
-	reply := req.callb.method.Func.Call(arguments)
-	if len(reply) == 0 {
-		return codec.CreateResponse(req.id, nil), nil
+// createResponse creates the response of a successful call. If the server limits
+// the size of responses, the size of the result is deducted from the remaining
+// budget of the (batch) response. Small results are encoded up front, large
+// arrays are only measured element by element, giving up as soon as the budget
+// is exceeded, and streamed later on.
+func (s *Server) createResponse(codec ServerCodec, req *serverRequest, result interface{}, budget *int) interface{} {
+	if budget == nil {
+		return codec.CreateResponse(req.id, result)
 	}
-	if req.callb.errPos >= 0 { // test if method returned an error
-		if !reply[req.callb.errPos].IsNil() {
-			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+	var (
+		reply interface{}
+		size  int
+		err   error
+	)
+	if v, ok := largeArray(result); ok {
+		if size, err = arraySize(v, *budget); err == nil {
+			reply = result
+		}
+	} else {
+		var blob []byte
+		if blob, err = json.Marshal(result); err == nil {
+			reply, size = json.RawMessage(blob), len(blob)
 		}
 	}
-	return codec.CreateResponse(req.id, reply[0].Interface()), nil
+	if err == errArrayTooLarge || (err == nil && size > *budget) {
+		*budget = 0
+		return codec.CreateErrorResponse(&req.id, &responseTooLargeError{})
+	}
+	if err != nil {
+		return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()})
+	}
+	*budget -= size
+	return codec.CreateResponse(req.id, reply)
+}
+
+// trackCall updates the metrics of a finished method call and logs it if it
+// took longer than the slow request threshold.
+func (s *Server) trackCall(req *serverRequest, start time.Time, err error) {
+	elapsed := time.Since(start)
+
+	rpcServingTimer(req.method, err == nil).Update(elapsed)
+	if err == nil {
+		rpcSuccessMeter.Mark(1)
+	} else {
+		rpcFailureMeter.Mark(1)
+	}
+	if threshold := time.Duration(atomic.LoadInt64(&s.slowThreshold)); threshold > 0 && elapsed >= threshold {
+		log.Warn("Slow RPC request", "method", req.method, "params", req.paramsSize, "elapsed", elapsed, "err", err)
+	}
 }
 
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
 	var response interface{}
 	var callback func()
+
+	rpcRequestMeter.Mark(1)
 	if req.err != nil {
+		rpcFailureMeter.Mark(1)
 		response = codec.CreateErrorResponse(&req.id, req.err)
 	} else {
-		response, callback = s.handle(ctx, codec, req)
+		response, callback = s.handle(ctx, codec, req, s.responseBudget())
 	}
 
 	if err := codec.Write(response); err != nil {
@@ -346,12 +498,16 @@ func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest
 func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
+	rpcRequestMeter.Mark(int64(len(requests)))
+
+	budget := s.responseBudget() // shared by all responses of the batch
 	for i, req := range requests {
 		if req.err != nil {
+			rpcFailureMeter.Mark(1)
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
 		} else {
 			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
+			if responses[i], callback = s.handle(ctx, codec, req, budget); callback != nil {
 				callbacks = append(callbacks, callback)
 			}
 		}
@@ -404,10 +560,11 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 			continue
 		}
+		filter := s.MethodFilter()
 
 		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
-			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			if callb, ok := svc.subscriptions[r.method]; ok && filter.Allowed(r.service+subscribeMethodSuffix) {
+				requests[i] = &serverRequest{id: r.id, method: r.service + subscribeMethodSuffix + "/" + r.method, paramsSize: paramsSize(r.params), svcname: svc.name, callb: callb}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -423,8 +580,8 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 			continue
 		}
 
-		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+		if callb, ok := svc.callbacks[r.method]; ok && filter.Allowed(r.service+serviceMethodSeparator+r.method) { // lookup RPC method
+			requests[i] = &serverRequest{id: r.id, method: r.service + serviceMethodSeparator + r.method, paramsSize: paramsSize(r.params), svcname: svc.name, callb: callb}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
@@ -440,3 +597,11 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 	return requests, batch, nil
 }
+
+// paramsSize returns the size of the raw parameters of a request, if known.
+func paramsSize(params interface{}) int {
+	if raw, ok := params.(json.RawMessage); ok {
+		return len(raw)
+	}
+	return 0
+}
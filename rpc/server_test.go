@@ -17,12 +17,16 @@
 package rpc
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-ethereum-analysis/metrics"
 )
 
 type Service struct{}
@@ -160,3 +164,198 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+// Tests that method calls are tracked by per-method timers, and that requests
+// for unknown methods don't register any.
+func TestServerMethodMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	// Use a dedicated namespace, timers created by other tests while metrics
+	// were disabled are no-ops.
+	server := newTestServer("metrics", new(Service))
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		var resp Result
+		if err := client.Call(&resp, "metrics_echo", "hello", 10, &Args{"world"}); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	if err := client.Call(nil, "metrics_missing"); err == nil {
+		t.Fatal("unknown method call succeeded")
+	}
+	if count := rpcServingTimer("metrics_echo", true).Count(); count != 3 {
+		t.Errorf("successful call count mismatch: have %d, want 3", count)
+	}
+	if count := rpcServingTimer("metrics_echo", false).Count(); count != 0 {
+		t.Errorf("failed call count mismatch: have %d, want 0", count)
+	}
+	if metrics.DefaultRegistry.Get("rpc/duration/metrics_missing/failure") != nil {
+		t.Error("timer registered for unknown method")
+	}
+}
+
+type LimitsService struct {
+	aborted chan struct{} // Notified when a call to Work is cancelled
+}
+
+func (s *LimitsService) Block(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+func (s *LimitsService) Work(ctx context.Context, duration time.Duration) {
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+		s.aborted <- struct{}{}
+	}
+}
+
+func (s *LimitsService) Blob(size int) string {
+	return strings.Repeat("x", size)
+}
+
+func (s *LimitsService) Results(n int) []Result {
+	results := make([]Result, n)
+	for i := range results {
+		results[i] = Result{String: "<result>", Int: i, Args: &Args{"args"}}
+	}
+	return results
+}
+
+// Tests that the server rejects oversized batches, oversized responses and calls
+// running past the execution timeout.
+func TestServerLimits(t *testing.T) {
+	service := &LimitsService{aborted: make(chan struct{}, 1)}
+	server := newTestServer("limits", service)
+	defer server.Stop()
+
+	server.SetLimits(Limits{BatchItems: 2, ResponseBytes: 100, ExecutionTimeout: 100 * time.Millisecond})
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Calls within and beyond the execution timeout
+	if err := client.Call(nil, "limits_block", 0); err != nil {
+		t.Errorf("quick call failed: %v", err)
+	}
+	if err, ok := client.Call(nil, "limits_block", time.Second).(*jsonError); !ok || err.Code != -32002 {
+		t.Errorf("slow call error mismatch: have %v, want timeout", err)
+	}
+	// Calls past the execution timeout must have their work cancelled
+	if err, ok := client.Call(nil, "limits_work", time.Minute).(*jsonError); !ok || err.Code != -32002 {
+		t.Errorf("slow work error mismatch: have %v, want timeout", err)
+	}
+	select {
+	case <-service.aborted:
+	case <-time.After(time.Second):
+		t.Errorf("timed out call not cancelled")
+	}
+	// Responses within and beyond the size limit, for single and batch requests
+	var blob string
+	if err := client.Call(&blob, "limits_blob", 50); err != nil {
+		t.Errorf("small response failed: %v", err)
+	}
+	if err, ok := client.Call(&blob, "limits_blob", 200).(*jsonError); !ok || err.Code != -32003 {
+		t.Errorf("large response error mismatch: have %v, want response too large", err)
+	}
+	batch := []BatchElem{
+		{Method: "limits_blob", Args: []interface{}{60}, Result: new(string)},
+		{Method: "limits_blob", Args: []interface{}{60}, Result: new(string)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch call failed: %v", err)
+	}
+	if batch[0].Error != nil {
+		t.Errorf("first batch response failed: %v", batch[0].Error)
+	}
+	if err, ok := batch[1].Error.(*jsonError); !ok || err.Code != -32003 {
+		t.Errorf("second batch response error mismatch: have %v, want response too large", batch[1].Error)
+	}
+	// Batches beyond the item limit are rejected as a whole
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	if _, err := clientConn.Write([]byte(`[{"jsonrpc":"2.0","id":1,"method":"limits_blob","params":[1]},{"jsonrpc":"2.0","id":2,"method":"limits_blob","params":[1]},{"jsonrpc":"2.0","id":3,"method":"limits_blob","params":[1]}]`)); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonErrResponse
+	if err := json.NewDecoder(clientConn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error.Code != -32600 {
+		t.Errorf("oversized batch error mismatch: have %v, want invalid request", response.Error)
+	}
+}
+
+// Tests that large array results are streamed with the same encoding as any
+// other result, with and without response size limits.
+func TestServerStreamedResponse(t *testing.T) {
+	server := newTestServer("limits", new(LimitsService))
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+	in := bufio.NewReader(clientConn)
+
+	results, _ := json.Marshal(new(LimitsService).Results(1000))
+	expected := `{"jsonrpc":"2.0","id":1,"result":` + string(results) + "}\n"
+
+	for _, limit := range []int{0, 2 * len(results)} {
+		server.SetLimits(Limits{ResponseBytes: limit})
+
+		// Single requests
+		if _, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"limits_results","params":[1000]}`)); err != nil {
+			t.Fatal(err)
+		}
+		response, err := in.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response != expected {
+			t.Errorf("limit %d: response mismatch:\nhave %.200s...\nwant %.200s...", limit, response, expected)
+		}
+		// Batches mixing streamed and regular results
+		if _, err := clientConn.Write([]byte(`[{"jsonrpc":"2.0","id":1,"method":"limits_results","params":[1000]},{"jsonrpc":"2.0","id":2,"method":"limits_results","params":[1]}]`)); err != nil {
+			t.Fatal(err)
+		}
+		if response, err = in.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+		var batch []jsonSuccessResponse
+		if err := json.Unmarshal([]byte(response), &batch); err != nil {
+			t.Fatalf("limit %d: invalid batch response: %v", limit, err)
+		}
+		if len(batch) != 2 || len(batch[0].Result.([]interface{})) != 1000 || len(batch[1].Result.([]interface{})) != 1 {
+			t.Errorf("limit %d: batch response mismatch: %.200s", limit, response)
+		}
+	}
+	// Results exactly at the limit are accepted, beyond it rejected
+	server.SetLimits(Limits{ResponseBytes: len(results)})
+	if _, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"limits_results","params":[1000]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if response, err := in.ReadString('\n'); err != nil || response != expected {
+		t.Errorf("result at limit mismatch: have %.200s..., %v", response, err)
+	}
+	server.SetLimits(Limits{ResponseBytes: len(results) - 1})
+	if _, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"limits_results","params":[1000]}`)); err != nil {
+		t.Fatal(err)
+	}
+	var response jsonErrResponse
+	if err := json.NewDecoder(in).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error.Code != -32003 {
+		t.Errorf("oversized result error mismatch: have %v, want response too large", response.Error)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 The github.com/go-ethereum-analysis Authors
+// This file is part of the github.com/go-ethereum-analysis library.
+//
+// The github.com/go-ethereum-analysis library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The github.com/go-ethereum-analysis library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the github.com/go-ethereum-analysis library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+const (
+	// streamThreshold is the number of elements from which array results are
+	// encoded element by element, instead of buffering their whole encoding.
+	streamThreshold = 64
+
+	// streamBufferSize is the size of the write buffer used while streaming.
+	streamBufferSize = 32 * 1024
+)
+
+// errArrayTooLarge is returned by arraySize when the encoding exceeds its limit.
+var errArrayTooLarge = errors.New("array encoding too large")
+
+// countingWriter discards everything written to it, only counting the bytes. It
+// fails as soon as more than limit bytes were written.
+type countingWriter struct {
+	size  int
+	limit int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.size += len(p); w.size > w.limit {
+		return 0, errArrayTooLarge
+	}
+	return len(p), nil
+}
+
+// largeArray returns the value of an array or slice result long enough to be
+// streamed. Byte slices and types with custom encodings are never streamed.
+func largeArray(result interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(result)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Value{}, false // encoded as null
+		}
+	case reflect.Array:
+	default:
+		return reflect.Value{}, false
+	}
+	typ := v.Type()
+	if typ.Elem().Kind() == reflect.Uint8 || v.Len() < streamThreshold {
+		return reflect.Value{}, false
+	}
+	if implements(typ, jsonMarshalerType) || implements(typ, textMarshalerType) {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// arraySize measures the encoding of a large array without holding it in memory,
+// encoding its elements one by one into a counting writer. It aborts with
+// errArrayTooLarge as soon as the encoding exceeds limit bytes.
+func arraySize(v reflect.Value, limit int) (int, error) {
+	// The encoder terminates every element with a newline, which stands in for
+	// the separator or the closing bracket. Large arrays are never empty.
+	w := &countingWriter{size: 1, limit: limit}
+	enc := json.NewEncoder(w)
+	for i := 0; i < v.Len(); i++ {
+		if err := enc.Encode(arrayElem(v, i)); err != nil {
+			return 0, err
+		}
+	}
+	if w.size > limit {
+		return 0, errArrayTooLarge
+	}
+	return w.size, nil
+}
+
+// arrayElem returns an element of an array or slice for encoding. Addressable
+// elements are encoded through a pointer, like encoding/json does, so methods
+// with pointer receivers apply.
+func arrayElem(v reflect.Value, i int) interface{} {
+	if elem := v.Index(i); elem.CanAddr() {
+		return elem.Addr().Interface()
+	}
+	return v.Index(i).Interface()
+}
+
+// streamable reports whether a response, or batch of responses, carries a
+// result worth streaming.
+func streamable(res interface{}) bool {
+	if batch, ok := res.([]interface{}); ok {
+		for _, res := range batch {
+			if streamable(res) {
+				return true
+			}
+		}
+		return false
+	}
+	if resp, ok := res.(*jsonSuccessResponse); ok {
+		_, ok := largeArray(resp.Result)
+		return ok
+	}
+	return false
+}
+
+// writeStream encodes a response, or batch of responses, to w like json.Encoder
+// does. Large array results are written element by element, so their encoding
+// is never held in memory as a whole.
+func writeStream(w io.Writer, res interface{}) error {
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+
+	var err error
+	if batch, ok := res.([]interface{}); ok {
+		bw.WriteByte('[')
+		for i := 0; i < len(batch) && err == nil; i++ {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			err = writeStreamResponse(bw, batch[i])
+		}
+		bw.WriteByte(']')
+	} else {
+		err = writeStreamResponse(bw, res)
+	}
+	if err != nil {
+		return err
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+// writeStreamResponse encodes a single response, streaming its result if large.
+func writeStreamResponse(w *bufio.Writer, res interface{}) error {
+	if !streamable(res) {
+		return writeJSON(w, res)
+	}
+	resp := res.(*jsonSuccessResponse)
+
+	w.WriteString(`{"jsonrpc":`)
+	if err := writeJSON(w, resp.Version); err != nil {
+		return err
+	}
+	if resp.Id != nil {
+		w.WriteString(`,"id":`)
+		if err := writeJSON(w, resp.Id); err != nil {
+			return err
+		}
+	}
+	w.WriteString(`,"result":[`)
+	v, _ := largeArray(resp.Result)
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		if err := writeJSON(w, arrayElem(v, i)); err != nil {
+			return err
+		}
+	}
+	_, err := w.WriteString("]}")
+	return err
+}
+
+// writeJSON writes the encoding of a single value.
+func writeJSON(w *bufio.Writer, v interface{}) error {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(blob)
+	return err
+}
@@ -22,6 +22,8 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-ethereum-analysis/common/hexutil"
@@ -56,6 +58,8 @@ type service struct {
 // serverRequest is an incoming request
 type serverRequest struct {
 	id            interface{}
+	method        string // Full name of the requested method, e.g. eth_call
+	paramsSize    int    // Size of the raw request parameters, for slow request logs
 	svcname       string
 	callb         *callback
 	args          []reflect.Value
@@ -74,6 +78,18 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   mapset.Set
+
+	slowThreshold int64        // Duration after which calls are logged as slow, 0 = disabled (atomic)
+	limits        atomic.Value // Resource limits of the served requests (Limits)
+	methods       atomic.Value // Filter of the methods served (*MethodFilter)
+}
+
+// Limits bounds the resources the requests of a server may consume. Zero values
+// leave the corresponding resource unlimited.
+type Limits struct {
+	BatchItems       int           // Maximum number of requests in a batch
+	ResponseBytes    int           // Maximum size of the results of a (batch) response
+	ExecutionTimeout time.Duration // Maximum execution time of a method call
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -62,6 +62,9 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			// Create a custom encode/decode pair to enforce payload size and number encoding
 			conn.MaxPayloadBytes = maxRequestContentLength
 
+			// Drop any deadlines inherited from an HTTP server sharing the listener
+			conn.SetDeadline(time.Time{})
+
 			encoder := func(v interface{}) error {
 				return websocketJSONCodec.Send(conn, v)
 			}
@@ -124,6 +127,10 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, nil)
+}
+
+func dialWebsocket(ctx context.Context, endpoint, origin string, auth HTTPAuth) (*Client, error) {
 	if origin == "" {
 		var err error
 		if origin, err = os.Hostname(); err != nil {
@@ -141,6 +148,15 @@ func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error
 	}
 
 	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+		// Authenticate every handshake, reconnects need fresh credentials
+		if auth != nil {
+			config := *config
+			config.Header = make(http.Header)
+			if err := auth(config.Header); err != nil {
+				return nil, err
+			}
+			return wsDialContext(ctx, &config)
+		}
 		return wsDialContext(ctx, config)
 	})
 }
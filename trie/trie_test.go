@@ -519,7 +519,8 @@ const benchElemCount = 20000
 func benchGet(b *testing.B, commit bool) {
 	trie := new(Trie)
 	if commit {
-		_, tmpdb := tempDB()
+		tmpdir, tmpdb := tempDB()
+		defer os.RemoveAll(tmpdir)
 		trie, _ = New(common.Hash{}, tmpdb)
 	}
 	k := make([]byte, 32)
@@ -539,9 +540,7 @@ func benchGet(b *testing.B, commit bool) {
 	b.StopTimer()
 
 	if commit {
-		ldb := trie.db.diskdb.(*ethdb.LDBDatabase)
-		ldb.Close()
-		os.RemoveAll(ldb.Path())
+		trie.db.diskdb.Close()
 	}
 }
 